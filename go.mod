@@ -6,10 +6,20 @@ require (
 	github.com/Masterminds/squirrel v1.5.2
 	github.com/biter777/countries v1.3.4
 	github.com/caarlos0/env v3.5.0+incompatible
+	github.com/go-sql-driver/mysql v1.5.0
+	github.com/golang-jwt/jwt/v4 v4.5.2
 	github.com/gorilla/mux v1.8.0
 	github.com/jinzhu/gorm v1.9.16
 	github.com/joho/godotenv v1.4.0
-	github.com/lib/pq v1.2.0 // indirect
-	github.com/mattn/go-sqlite3 v1.14.6 // indirect
-	github.com/stretchr/testify v1.7.0 // indirect
+	github.com/lib/pq v1.2.0
+	github.com/mattn/go-sqlite3 v1.14.6
+	github.com/nyaruka/phonenumbers v1.0.70
+	github.com/prometheus/client_golang v1.12.2
+	github.com/stretchr/testify v1.7.1
+	go.opentelemetry.io/otel v1.7.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.7.0
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.7.0
+	go.opentelemetry.io/otel/sdk v1.7.0
+	go.opentelemetry.io/otel/trace v1.7.0
+	golang.org/x/net v0.0.0-20210525063256-abc453219eb5
 )
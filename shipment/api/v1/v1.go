@@ -0,0 +1,101 @@
+// Package v1 defines JSON shapes of API v1, the ones the API had before it
+// was versioned, and adapts internal models to them. Shapes of entities not
+// listed here are the ones of models
+package v1
+
+import (
+	"sendify_test/shipment/models"
+	"sendify_test/shipment/validation"
+	"strings"
+	"time"
+)
+
+// Shipment is shipment of a single parcel, price is amount in
+// models.PriceCurrency
+type Shipment struct {
+	ID        int             `json:"id,omitempty"`
+	Weight    int             `json:"weight"`
+	Price     int             `json:"price,omitempty"`
+	From      models.Customer `json:"from"`
+	To        models.Customer `json:"to"`
+	CreatedAt time.Time       `json:"created_at,omitempty"`
+}
+
+// NewShipment returns v1 shape of shipment, weight of shipment of several
+// parcels is their total weight
+func NewShipment(shipment models.Shipment) Shipment {
+	return Shipment{
+		ID:        shipment.ID,
+		Weight:    shipment.Weight,
+		Price:     shipment.Price.Amount,
+		From:      shipment.From,
+		To:        shipment.To,
+		CreatedAt: shipment.CreatedAt,
+	}
+}
+
+func NewShipments(shipments models.Shipments) []Shipment {
+	v1Shipments := make([]Shipment, 0, len(shipments))
+	for _, shipment := range shipments {
+		v1Shipments = append(v1Shipments, NewShipment(shipment))
+	}
+	return v1Shipments
+}
+
+// Model returns requested shipment of a single parcel
+func (s Shipment) Model() models.Shipment {
+	return models.Shipment{
+		Parcels: models.Parcels{{Weight: s.Weight}},
+		From:    s.From,
+		To:      s.To,
+	}
+}
+
+// CustomerShipment is shipment of the customer without details of the
+// other party
+type CustomerShipment struct {
+	ID        int       `json:"id"`
+	Weight    int       `json:"weight"`
+	Price     int       `json:"price"`
+	Roles     []string  `json:"roles"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CustomerDataExport contains all personal data of the customer
+type CustomerDataExport struct {
+	Customer   models.Customer        `json:"customer"`
+	Shipments  []CustomerShipment     `json:"shipments"`
+	Merges     []models.CustomerMerge `json:"merges"`
+	ExportedAt time.Time              `json:"exported_at"`
+}
+
+func NewCustomerDataExport(export models.CustomerDataExport) CustomerDataExport {
+	shipments := make([]CustomerShipment, 0, len(export.Shipments))
+	for _, shipment := range export.Shipments {
+		shipments = append(shipments, CustomerShipment{
+			ID:        shipment.ID,
+			Weight:    shipment.Weight,
+			Price:     shipment.Price.Amount,
+			Roles:     shipment.Roles,
+			CreatedAt: shipment.CreatedAt,
+		})
+	}
+
+	return CustomerDataExport{
+		Customer:   export.Customer,
+		Shipments:  shipments,
+		Merges:     export.Merges,
+		ExportedAt: export.ExportedAt,
+	}
+}
+
+// ShipmentErrors points validation errors of parcels of shipment to its
+// weight
+func ShipmentErrors(err error) error {
+	return validation.MapPointers(err, func(pointer string) string {
+		if pointer == "/parcels" || strings.HasPrefix(pointer, "/parcels/") {
+			return "/weight"
+		}
+		return pointer
+	})
+}
@@ -0,0 +1,260 @@
+// Package v2 defines JSON shapes of API v2 and adapts internal models to
+// them. Unlike v1, country is a part of address, shipments consist of
+// parcels and prices have currency
+package v2
+
+import (
+	"sendify_test/shipment/models"
+	"sendify_test/shipment/validation"
+	"strings"
+	"time"
+)
+
+// Address is structured postal address with its country
+type Address struct {
+	StreetLines models.StreetLines `json:"street_lines"`
+	HouseNumber string             `json:"house_number,omitempty"`
+	PostalCode  string             `json:"postal_code,omitempty"`
+	City        string             `json:"city"`
+	Region      string             `json:"region,omitempty"`
+	CountryCode string             `json:"country_code"`
+}
+
+func NewAddress(address models.PostalAddress, countryCode string) Address {
+	return Address{
+		StreetLines: address.StreetLines,
+		HouseNumber: address.HouseNumber,
+		PostalCode:  address.PostalCode,
+		City:        address.City,
+		Region:      address.Region,
+		CountryCode: countryCode,
+	}
+}
+
+// Model returns postal address and its country code
+func (a Address) Model() (models.PostalAddress, string) {
+	return models.PostalAddress{
+		StreetLines: a.StreetLines,
+		HouseNumber: a.HouseNumber,
+		PostalCode:  a.PostalCode,
+		City:        a.City,
+		Region:      a.Region,
+	}, a.CountryCode
+}
+
+type Customer struct {
+	ID        int        `json:"id,omitempty"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	Phone     string     `json:"phone,omitempty"`
+	Address   Address    `json:"address"`
+	CreatedAt time.Time  `json:"created_at,omitempty"`
+	ErasedAt  *time.Time `json:"erased_at,omitempty"`
+
+	// AddressID refers to saved address from address book, customer details
+	// are taken from it when specified
+	AddressID int `json:"address_id,omitempty"`
+}
+
+func NewCustomer(customer models.Customer) Customer {
+	return Customer{
+		ID:        customer.ID,
+		Name:      customer.Name,
+		Email:     customer.Email,
+		Phone:     customer.Phone,
+		Address:   NewAddress(customer.Address, customer.CountryCode),
+		CreatedAt: customer.CreatedAt,
+		ErasedAt:  customer.ErasedAt,
+	}
+}
+
+func (c Customer) Model() models.Customer {
+	address, countryCode := c.Address.Model()
+	return models.Customer{
+		Name:        c.Name,
+		Email:       c.Email,
+		Phone:       c.Phone,
+		CountryCode: countryCode,
+		Address:     address,
+		AddressID:   c.AddressID,
+	}
+}
+
+// Parcel is a package of shipment, weight is in kilograms
+type Parcel struct {
+	Weight int `json:"weight"`
+}
+
+// Price is amount in whole units of currency, ISO 4217 code
+type Price struct {
+	Amount   int    `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+type Shipment struct {
+	ID        int       `json:"id,omitempty"`
+	Parcels   []Parcel  `json:"parcels"`
+	Price     Price     `json:"price"`
+	From      Customer  `json:"from"`
+	To        Customer  `json:"to"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+func NewShipment(shipment models.Shipment) Shipment {
+	return Shipment{
+		ID:        shipment.ID,
+		Parcels:   newParcels(shipment.Parcels),
+		Price:     Price{Amount: shipment.Price.Amount, Currency: shipment.Price.Currency},
+		From:      NewCustomer(shipment.From),
+		To:        NewCustomer(shipment.To),
+		CreatedAt: shipment.CreatedAt,
+	}
+}
+
+func NewShipments(shipments models.Shipments) []Shipment {
+	v2Shipments := make([]Shipment, 0, len(shipments))
+	for _, shipment := range shipments {
+		v2Shipments = append(v2Shipments, NewShipment(shipment))
+	}
+	return v2Shipments
+}
+
+// Model returns requested shipment, its price is formed by the service
+func (s Shipment) Model() models.Shipment {
+	var parcels models.Parcels
+	for _, parcel := range s.Parcels {
+		parcels = append(parcels, models.Parcel{Weight: parcel.Weight})
+	}
+
+	return models.Shipment{
+		Parcels: parcels,
+		From:    s.From.Model(),
+		To:      s.To.Model(),
+	}
+}
+
+func newParcels(parcels models.Parcels) []Parcel {
+	v2Parcels := make([]Parcel, 0, len(parcels))
+	for _, parcel := range parcels {
+		v2Parcels = append(v2Parcels, Parcel{Weight: parcel.Weight})
+	}
+	return v2Parcels
+}
+
+// SavedAddress is a named sender/recipient profile from account's address book
+type SavedAddress struct {
+	ID        int       `json:"id,omitempty"`
+	AccountID int       `json:"account_id"`
+	Label     string    `json:"label"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Phone     string    `json:"phone,omitempty"`
+	Address   Address   `json:"address"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+func NewSavedAddress(address models.SavedAddress) SavedAddress {
+	return SavedAddress{
+		ID:        address.ID,
+		AccountID: address.AccountID,
+		Label:     address.Label,
+		Name:      address.Name,
+		Email:     address.Email,
+		Phone:     address.Phone,
+		Address:   NewAddress(address.Address, address.CountryCode),
+		CreatedAt: address.CreatedAt,
+		UpdatedAt: address.UpdatedAt,
+	}
+}
+
+func NewSavedAddresses(addresses models.SavedAddresses) []SavedAddress {
+	v2Addresses := make([]SavedAddress, 0, len(addresses))
+	for _, address := range addresses {
+		v2Addresses = append(v2Addresses, NewSavedAddress(address))
+	}
+	return v2Addresses
+}
+
+func (a SavedAddress) Model() models.SavedAddress {
+	address, countryCode := a.Address.Model()
+	return models.SavedAddress{
+		Label:       a.Label,
+		Name:        a.Name,
+		Email:       a.Email,
+		Phone:       a.Phone,
+		CountryCode: countryCode,
+		Address:     address,
+	}
+}
+
+// DuplicateCandidate is pair of customers which are likely the same person
+type DuplicateCandidate struct {
+	First   Customer `json:"first"`
+	Second  Customer `json:"second"`
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+func NewDuplicateCandidates(candidates models.DuplicateCandidates) []DuplicateCandidate {
+	v2Candidates := make([]DuplicateCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		v2Candidates = append(v2Candidates, DuplicateCandidate{
+			First:   NewCustomer(candidate.First),
+			Second:  NewCustomer(candidate.Second),
+			Score:   candidate.Score,
+			Reasons: candidate.Reasons,
+		})
+	}
+	return v2Candidates
+}
+
+// CustomerShipment is shipment of the customer without details of the
+// other party
+type CustomerShipment struct {
+	ID        int       `json:"id"`
+	Parcels   []Parcel  `json:"parcels"`
+	Price     Price     `json:"price"`
+	Roles     []string  `json:"roles"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CustomerDataExport contains all personal data of the customer
+type CustomerDataExport struct {
+	Customer   Customer               `json:"customer"`
+	Shipments  []CustomerShipment     `json:"shipments"`
+	Merges     []models.CustomerMerge `json:"merges"`
+	ExportedAt time.Time              `json:"exported_at"`
+}
+
+func NewCustomerDataExport(export models.CustomerDataExport) CustomerDataExport {
+	shipments := make([]CustomerShipment, 0, len(export.Shipments))
+	for _, shipment := range export.Shipments {
+		shipments = append(shipments, CustomerShipment{
+			ID:        shipment.ID,
+			Parcels:   newParcels(shipment.Parcels),
+			Price:     Price{Amount: shipment.Price.Amount, Currency: shipment.Price.Currency},
+			Roles:     shipment.Roles,
+			CreatedAt: shipment.CreatedAt,
+		})
+	}
+
+	return CustomerDataExport{
+		Customer:   NewCustomer(export.Customer),
+		Shipments:  shipments,
+		Merges:     export.Merges,
+		ExportedAt: export.ExportedAt,
+	}
+}
+
+// Errors points validation errors of country codes of customers and saved
+// addresses to their addresses, e.g. "/from/country_code" to
+// "/from/address/country_code"
+func Errors(err error) error {
+	return validation.MapPointers(err, func(pointer string) string {
+		if strings.HasSuffix(pointer, "/country_code") {
+			return strings.TrimSuffix(pointer, "/country_code") + "/address/country_code"
+		}
+		return pointer
+	})
+}
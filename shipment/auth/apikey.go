@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	// apiKeyPrefix marks API keys, e.g. to be found by secret scanners
+	apiKeyPrefix = "sk_"
+	// displayPrefixLength is number of key characters stored in plain to tell
+	// keys apart
+	displayPrefixLength = len(apiKeyPrefix) + 8
+)
+
+// GenerateAPIKey returns new random API key, its display prefix and hash
+// to be stored instead of the key
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", "", err
+	}
+
+	key = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return key, key[:displayPrefixLength], HashAPIKey(key), nil
+}
+
+// HashAPIKey returns hash API keys are looked up by, keys are random, so
+// plain SHA-256 is enough (no salt or key stretching)
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(key)))
+	return hex.EncodeToString(sum[:])
+}
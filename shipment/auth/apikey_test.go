@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, hash, err := GenerateAPIKey()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.True(t, strings.HasPrefix(key, "sk_"))
+	assert.True(t, strings.HasPrefix(key, prefix))
+	assert.Len(t, prefix, 11)
+	assert.Equal(t, HashAPIKey(key), hash)
+	assert.NotContains(t, hash, key)
+
+	other, _, otherHash, err := GenerateAPIKey()
+	if assert.NoError(t, err) {
+		assert.NotEqual(t, key, other)
+		assert.NotEqual(t, hash, otherHash)
+	}
+}
+
+func TestHashAPIKey(t *testing.T) {
+	assert.Equal(t,
+		"12b2820cf1639904311da5771de1e5bb65c77073fdc7c555df395942df42896b",
+		HashAPIKey("sk_test"),
+	)
+	assert.Equal(t, HashAPIKey("sk_test"), HashAPIKey(" sk_test\n"))
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// Caller is authenticated API caller, either machine using API key or
+// dashboard user with bearer token
+type Caller struct {
+	AccountID int
+	Role      Role
+	// APIKeyID is ID of the API key used by caller
+	APIKeyID int
+	// Subject identifies user of the bearer token
+	Subject string
+}
+
+// Can reports whether caller has the permission
+func (c Caller) Can(permission Permission) bool {
+	return c.Role.Can(permission)
+}
+
+type callerKey struct{}
+
+// NewContext returns context carrying the caller
+func NewContext(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// FromContext returns caller stored in context, reports whether there is one
+func FromContext(ctx context.Context) (Caller, bool) {
+	caller, ok := ctx.Value(callerKey{}).(Caller)
+	return caller, ok
+}
+
+// String identifies caller, e.g. to limit requests per caller
+func (c Caller) String() string {
+	if c.APIKeyID != 0 {
+		return "apikey:" + strconv.Itoa(c.APIKeyID)
+	}
+	return "user:" + strconv.Itoa(c.AccountID) + ":" + c.Subject
+}
+
+// CallerKey returns identity of the caller of authenticated request, reports
+// false for requests without caller
+func CallerKey(r *http.Request) (string, bool) {
+	caller, ok := FromContext(r.Context())
+	if !ok {
+		return "", false
+	}
+	return caller.String(), true
+}
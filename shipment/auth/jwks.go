@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksRefreshInterval limits how often JWKS is fetched again when token is
+// signed by unknown key, e.g. after key rotation by identity provider
+const jwksRefreshInterval = time.Minute
+
+// JWKS is set of public keys tokens are signed by, loaded from URL of
+// identity provider or from local file
+type JWKS struct {
+	source string
+	client *http.Client
+
+	mu        sync.RWMutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+type jsonWebKey struct {
+	KeyID string `json:"kid"`
+	Type  string `json:"kty"`
+	Use   string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Curve string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+}
+
+// LoadJWKS loads keys from http(s) URL or file path
+func LoadJWKS(source string) (*JWKS, error) {
+	jwks := &JWKS{
+		source: source,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+	if err := jwks.refresh(); err != nil {
+		return nil, err
+	}
+	return jwks, nil
+}
+
+// Key returns public key by its ID, keys are fetched again if key is unknown
+func (j *JWKS) Key(keyID string) (interface{}, error) {
+	j.mu.RLock()
+	key, ok := j.keys[keyID]
+	fetchedAt := j.fetchedAt
+	j.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	if time.Since(fetchedAt) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", keyID)
+	}
+	if err := j.refresh(); err != nil {
+		return nil, err
+	}
+
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if key, ok := j.keys[keyID]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", keyID)
+}
+
+func (j *JWKS) refresh() error {
+	data, err := j.read()
+	if err != nil {
+		return fmt.Errorf("failed to read JWKS from %s: %w", j.source, err)
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("invalid JWKS from %s: %w", j.source, err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.keys = keys
+	j.fetchedAt = time.Now()
+	return nil
+}
+
+func (j *JWKS) read() ([]byte, error) {
+	if !strings.HasPrefix(j.source, "http://") && !strings.HasPrefix(j.source, "https://") {
+		return ioutil.ReadFile(j.source)
+	}
+
+	resp, err := j.client.Get(j.source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// parseJWKS returns RSA and EC signing keys by their IDs, other keys are
+// skipped
+func parseJWKS(data []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]interface{}{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		var (
+			key interface{}
+			err error
+		)
+		switch jwk.Type {
+		case "RSA":
+			key, err = jwk.rsaKey()
+		case "EC":
+			key, err = jwk.ecKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", jwk.KeyID, err)
+		}
+		keys[jwk.KeyID] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys")
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) rsaKey() (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := decodeBigInt(k.E)
+	if err != nil {
+		return nil, err
+	}
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid RSA exponent")
+	}
+
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (k jsonWebKey) ecKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Curve {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+	}
+
+	x, err := decodeBigInt(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodeBigInt(k.Y)
+	if err != nil {
+		return nil, err
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("point is not on curve")
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty key parameter")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
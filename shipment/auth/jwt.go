@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is returned for bearer tokens which are malformed, expired,
+// not signed by known key or lacking required claims
+var ErrInvalidToken = errors.New("invalid bearer token")
+
+// signingMethods are accepted token algorithms, symmetric ones and "none"
+// are never accepted as keys come from JWKS
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// TokenConfig describes tokens issued by OIDC identity provider
+type TokenConfig struct {
+	Issuer   string
+	Audience string
+	// RolesClaim is name of claim with list of roles, nested claims are
+	// separated by dots, e.g. "realm_access.roles"
+	RolesClaim string
+	// AccountClaim is name of claim with account ID of the user
+	AccountClaim string
+}
+
+// TokenVerifier verifies JWT bearer tokens against JWKS
+type TokenVerifier struct {
+	jwks   *JWKS
+	config TokenConfig
+	parser *jwt.Parser
+}
+
+func NewTokenVerifier(jwks *JWKS, config TokenConfig) *TokenVerifier {
+	if config.RolesClaim == "" {
+		config.RolesClaim = "roles"
+	}
+	if config.AccountClaim == "" {
+		config.AccountClaim = "account_id"
+	}
+
+	return &TokenVerifier{
+		jwks:   jwks,
+		config: config,
+		parser: jwt.NewParser(jwt.WithValidMethods(signingMethods), jwt.WithJSONNumber()),
+	}
+}
+
+// IsToken reports whether credential looks like JWT rather than API key
+func IsToken(credential string) bool {
+	return strings.Count(credential, ".") == 2
+}
+
+// Verify checks token signature and claims, returns caller of the token
+func (v *TokenVerifier) Verify(token string) (Caller, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		keyID, _ := token.Header["kid"].(string)
+		return v.jwks.Key(keyID)
+	})
+	if err != nil {
+		return Caller{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return Caller{}, fmt.Errorf("%w: missing expiration time", ErrInvalidToken)
+	}
+	if v.config.Issuer != "" && !claims.VerifyIssuer(v.config.Issuer, true) {
+		return Caller{}, fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if v.config.Audience != "" && !claims.VerifyAudience(v.config.Audience, true) {
+		return Caller{}, fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return Caller{}, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+
+	accountID, err := claimInt(claims, v.config.AccountClaim)
+	if err != nil {
+		return Caller{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	role, ok := HighestRole(claimStrings(claims, v.config.RolesClaim))
+	if !ok {
+		return Caller{}, fmt.Errorf("%w: no known role in %s claim", ErrInvalidToken, v.config.RolesClaim)
+	}
+
+	return Caller{
+		AccountID: accountID,
+		Role:      role,
+		Subject:   subject,
+	}, nil
+}
+
+// claimValue returns value of claim by its dotted path
+func claimValue(claims jwt.MapClaims, path string) interface{} {
+	var value interface{} = map[string]interface{}(claims)
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	return value
+}
+
+// claimStrings returns claim which is either list of strings or string with
+// space separated values
+func claimStrings(claims jwt.MapClaims, path string) []string {
+	switch value := claimValue(claims, path).(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		var values []string
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// claimInt returns positive integer claim, which may be a number or a string
+func claimInt(claims jwt.MapClaims, path string) (int, error) {
+	var (
+		id  int64
+		err error
+	)
+	switch value := claimValue(claims, path).(type) {
+	case json.Number:
+		id, err = value.Int64()
+	case string:
+		id, err = strconv.ParseInt(value, 10, 0)
+	default:
+		return 0, fmt.Errorf("missing %s claim", path)
+	}
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid %s claim", path)
+	}
+
+	return int(id), nil
+}
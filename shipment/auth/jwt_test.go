@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func encodeBigInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+// writeJWKS writes public keys to local JWKS file and returns its path
+func writeJWKS(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) string {
+	set := map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kid": "rsa-1",
+				"kty": "RSA",
+				"use": "sig",
+				"n":   encodeBigInt(rsaKey.N),
+				"e":   encodeBigInt(big.NewInt(int64(rsaKey.E))),
+			},
+			{
+				"kid": "ec-1",
+				"kty": "EC",
+				"crv": "P-256",
+				"x":   encodeBigInt(ecKey.X),
+				"y":   encodeBigInt(ecKey.Y),
+			},
+			{
+				"kid": "enc-1",
+				"kty": "RSA",
+				"use": "enc",
+				"n":   encodeBigInt(rsaKey.N),
+				"e":   encodeBigInt(big.NewInt(int64(rsaKey.E))),
+			},
+		},
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, keyID string, key interface{}, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = keyID
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestTokenVerifier_Verify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, err := LoadJWKS(writeJWKS(t, rsaKey, ecKey))
+	if !assert.NoError(t, err) {
+		return
+	}
+	verifier := NewTokenVerifier(jwks, TokenConfig{
+		Issuer:     "https://id.sendify.se",
+		Audience:   "shipment",
+		RolesClaim: "realm_access.roles",
+	})
+
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":          "https://id.sendify.se",
+			"aud":          []string{"shipment", "dashboard"},
+			"sub":          "user-1",
+			"exp":          time.Now().Add(time.Hour).Unix(),
+			"account_id":   7,
+			"realm_access": map[string]interface{}{"roles": []string{"offline_access", "viewer", "booker"}},
+		}
+	}
+	withClaim := func(name string, value interface{}) jwt.MapClaims {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		want    Caller
+		wantErr bool
+	}{
+		{
+			name:  "RSA signed",
+			token: signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, validClaims()),
+			want:  Caller{AccountID: 7, Role: RoleBooker, Subject: "user-1"},
+		},
+		{
+			name:  "EC signed, account ID as string",
+			token: signToken(t, jwt.SigningMethodES256, "ec-1", ecKey, withClaim("account_id", "7")),
+			want:  Caller{AccountID: 7, Role: RoleBooker, Subject: "user-1"},
+		},
+		{
+			name:    "Signed by unknown key",
+			token:   signToken(t, jwt.SigningMethodRS256, "rsa-1", otherKey, validClaims()),
+			wantErr: true,
+		},
+		{
+			name:    "Encryption key",
+			token:   signToken(t, jwt.SigningMethodRS256, "enc-1", rsaKey, validClaims()),
+			wantErr: true,
+		},
+		{
+			name:    "Symmetric algorithm",
+			token:   signToken(t, jwt.SigningMethodHS256, "rsa-1", []byte("secret"), validClaims()),
+			wantErr: true,
+		},
+		{
+			name:    "Expired",
+			token:   signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, withClaim("exp", time.Now().Add(-time.Minute).Unix())),
+			wantErr: true,
+		},
+		{
+			name:    "Without expiration",
+			token:   signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, withClaim("exp", nil)),
+			wantErr: true,
+		},
+		{
+			name:    "Other issuer",
+			token:   signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, withClaim("iss", "https://evil.example")),
+			wantErr: true,
+		},
+		{
+			name:    "Other audience",
+			token:   signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, withClaim("aud", "billing")),
+			wantErr: true,
+		},
+		{
+			name:    "Without account",
+			token:   signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, withClaim("account_id", nil)),
+			wantErr: true,
+		},
+		{
+			name: "Without known role",
+			token: signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey,
+				withClaim("realm_access", map[string]interface{}{"roles": []string{"offline_access"}})),
+			wantErr: true,
+		},
+		{
+			name:    "Malformed",
+			token:   "a.b.c",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := verifier.Verify(tt.token)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidToken)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRole_Can(t *testing.T) {
+	assert.True(t, RoleViewer.Can(PermShipmentsRead))
+	assert.False(t, RoleViewer.Can(PermShipmentsCreate))
+	assert.True(t, RoleBooker.Can(PermShipmentsCreate))
+	assert.False(t, RoleBooker.Can(PermShipmentsCancel))
+	assert.False(t, RoleBooker.Can(PermShipmentsReprice))
+	assert.True(t, RoleAdmin.Can(PermShipmentsCancel))
+	assert.True(t, RoleAdmin.Can(PermShipmentsReprice))
+	assert.True(t, RoleAdmin.Can(PermAuditRead))
+	assert.False(t, RoleBooker.Can(PermAuditRead))
+	assert.False(t, Role("").Can(PermShipmentsRead))
+
+	role, ok := HighestRole([]string{"viewer", "unknown", "admin", "booker"})
+	assert.True(t, ok)
+	assert.Equal(t, RoleAdmin, role)
+
+	_, ok = HighestRole([]string{"unknown"})
+	assert.False(t, ok)
+}
@@ -0,0 +1,94 @@
+package auth
+
+// Role of the caller, each role includes permissions of the previous one
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleBooker Role = "booker"
+	RoleAdmin  Role = "admin"
+)
+
+// Permission allows caller to perform operation
+type Permission string
+
+const (
+	PermShipmentsRead    Permission = "shipments:read"
+	PermShipmentsCreate  Permission = "shipments:create"
+	PermShipmentsCancel  Permission = "shipments:cancel"
+	PermShipmentsReprice Permission = "shipments:reprice"
+
+	PermAddressBookRead  Permission = "address_book:read"
+	PermAddressBookWrite Permission = "address_book:write"
+
+	PermCustomersRead   Permission = "customers:read"
+	PermCustomersMerge  Permission = "customers:merge"
+	PermCustomersExport Permission = "customers:export"
+	PermCustomersErase  Permission = "customers:erase"
+
+	PermAuditRead Permission = "audit:read"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleViewer: {
+		PermShipmentsRead,
+		PermAddressBookRead,
+		PermCustomersRead,
+	},
+	RoleBooker: {
+		PermShipmentsRead,
+		PermShipmentsCreate,
+		PermAddressBookRead,
+		PermAddressBookWrite,
+		PermCustomersRead,
+	},
+	RoleAdmin: {
+		PermShipmentsRead,
+		PermShipmentsCreate,
+		PermShipmentsCancel,
+		PermShipmentsReprice,
+		PermAddressBookRead,
+		PermAddressBookWrite,
+		PermCustomersRead,
+		PermCustomersMerge,
+		PermCustomersExport,
+		PermCustomersErase,
+		PermAuditRead,
+	},
+}
+
+// roleRanks orders roles, the highest one is used when caller has several
+var roleRanks = map[Role]int{
+	RoleViewer: 1,
+	RoleBooker: 2,
+	RoleAdmin:  3,
+}
+
+// ParseRole returns role by its name, reports whether role is known
+func ParseRole(name string) (Role, bool) {
+	role := Role(name)
+	_, ok := rolePermissions[role]
+	return role, ok
+}
+
+// HighestRole returns the highest of known roles, reports whether there is
+// any known role
+func HighestRole(names []string) (Role, bool) {
+	var highest Role
+	for _, name := range names {
+		if role, ok := ParseRole(name); ok && roleRanks[role] > roleRanks[highest] {
+			highest = role
+		}
+	}
+	return highest, highest != ""
+}
+
+// Can reports whether role has the permission
+func (r Role) Can(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"github.com/gorilla/mux"
+	"net/http"
+	"sendify_test/shipment/logging"
+	"sendify_test/shipment/models"
+	"strconv"
+)
+
+// GetAddressBook responds with all saved addresses of caller's account
+func (c controller) GetAddressBook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	addresses, err := c.processingSvc.GetAddressBook(ctx, callerAccountID(r))
+	if err != nil {
+		printError(ctx, w, r, "Failed to get address book", err)
+		return
+	}
+
+	models.PrintHTTPResult(w, http.StatusOK, c.representation.savedAddresses(addresses))
+}
+
+// CreateSavedAddress adds new address to the address book of caller's account
+func (c controller) CreateSavedAddress(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	ctx := r.Context()
+
+	address, err := c.representation.decodeSavedAddress(r.Body)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to parse body", "error", err)
+		models.PrintHTTPResult(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	address.ID = 0
+	address.AccountID = callerAccountID(r)
+
+	address.Normalize()
+
+	if err := address.Validate(); err != nil {
+		logging.FromContext(ctx).Warn("Request body validation failed", "error", err)
+		printValidationProblem(w, r, c.representation.savedAddressErrors(err))
+		return
+	}
+
+	address, err = c.processingSvc.CreateSavedAddress(ctx, requestActor(r), address)
+	if err != nil {
+		printError(ctx, w, r, "Failed to save address", err)
+		return
+	}
+
+	models.PrintHTTPResult(w, http.StatusCreated, c.representation.savedAddress(address))
+}
+
+// GetSavedAddress retrieves saved address by id specified in request
+func (c controller) GetSavedAddress(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	addressID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to convert ID", "error", err)
+		models.PrintHTTPResult(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx = logging.With(ctx, "address_id", addressID)
+
+	address, err := c.processingSvc.GetSavedAddress(ctx, callerAccountID(r), addressID)
+	if err != nil {
+		printError(ctx, w, r, "Failed to get saved address", err)
+		return
+	}
+
+	models.PrintHTTPResult(w, http.StatusOK, c.representation.savedAddress(address))
+}
+
+// UpdateSavedAddress replaces saved address by id specified in request
+func (c controller) UpdateSavedAddress(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	ctx := r.Context()
+
+	addressID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to convert ID", "error", err)
+		models.PrintHTTPResult(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx = logging.With(ctx, "address_id", addressID)
+
+	address, err := c.representation.decodeSavedAddress(r.Body)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to parse body", "error", err)
+		models.PrintHTTPResult(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	address.ID = addressID
+	address.AccountID = callerAccountID(r)
+
+	address.Normalize()
+
+	if err := address.Validate(); err != nil {
+		logging.FromContext(ctx).Warn("Request body validation failed", "error", err)
+		printValidationProblem(w, r, c.representation.savedAddressErrors(err))
+		return
+	}
+
+	address, err = c.processingSvc.UpdateSavedAddress(ctx, requestActor(r), address)
+	if err != nil {
+		printError(ctx, w, r, "Failed to update saved address", err)
+		return
+	}
+
+	models.PrintHTTPResult(w, http.StatusOK, c.representation.savedAddress(address))
+}
+
+// DeleteSavedAddress removes saved address by id specified in request
+func (c controller) DeleteSavedAddress(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	addressID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to convert ID", "error", err)
+		models.PrintHTTPResult(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx = logging.With(ctx, "address_id", addressID)
+
+	err = c.processingSvc.DeleteSavedAddress(ctx, requestActor(r), callerAccountID(r), addressID)
+	if err != nil {
+		printError(ctx, w, r, "Failed to delete saved address", err)
+		return
+	}
+
+	models.PrintHTTPResult(w, http.StatusOK, map[string]interface{}{"status": "Deleted"})
+}
@@ -1,10 +1,10 @@
 package controller
 
 import (
-	"encoding/json"
 	"github.com/gorilla/mux"
-	"log"
 	"net/http"
+	"sendify_test/shipment/auth"
+	"sendify_test/shipment/logging"
 	"sendify_test/shipment/models"
 	"sendify_test/shipment/processing"
 	"strconv"
@@ -12,58 +12,101 @@ import (
 
 type controller struct {
 	processingSvc processing.Service
+	// tokenVerifier verifies bearer tokens of dashboard users, tokens are
+	// not accepted if nil
+	tokenVerifier *auth.TokenVerifier
+	// representation converts models from and to JSON shapes of API
+	// version served by the controller
+	representation representation
 }
 
 type Controller interface {
+	// WithVersion returns controller serving the version of the API
+	WithVersion(version Version) Controller
+
+	Authenticate(next http.Handler) http.Handler
+	Authorize(permission auth.Permission, handler http.HandlerFunc) http.Handler
+
 	GetAllShipments(w http.ResponseWriter, r *http.Request)
 	CreateNewShipment(w http.ResponseWriter, r *http.Request)
 	GetShipmentByID(w http.ResponseWriter, r *http.Request)
+
+	GetAddressBook(w http.ResponseWriter, r *http.Request)
+	CreateSavedAddress(w http.ResponseWriter, r *http.Request)
+	GetSavedAddress(w http.ResponseWriter, r *http.Request)
+	UpdateSavedAddress(w http.ResponseWriter, r *http.Request)
+	DeleteSavedAddress(w http.ResponseWriter, r *http.Request)
+
+	GetDuplicateCustomers(w http.ResponseWriter, r *http.Request)
+	MergeCustomers(w http.ResponseWriter, r *http.Request)
+	ExportCustomerData(w http.ResponseWriter, r *http.Request)
+	EraseCustomer(w http.ResponseWriter, r *http.Request)
+
+	GetAuditLog(w http.ResponseWriter, r *http.Request)
+
+	Healthz(w http.ResponseWriter, r *http.Request)
+	Readyz(w http.ResponseWriter, r *http.Request)
 }
 
-func NewApiController(processingService processing.Service) Controller {
+func NewApiController(processingService processing.Service, tokenVerifier *auth.TokenVerifier) Controller {
 	return &controller{
-		processingSvc: processingService,
+		processingSvc:  processingService,
+		tokenVerifier:  tokenVerifier,
+		representation: representationOf(V1),
 	}
 }
 
-// GetAllShipments responds with all shipments in DB
-func (c controller) GetAllShipments(w http.ResponseWriter, _ *http.Request) {
-	shipments, err := c.processingSvc.GetAllShipments()
+func (c controller) WithVersion(version Version) Controller {
+	c.representation = representationOf(version)
+	return &c
+}
+
+// GetAllShipments responds with all shipments of caller's account
+func (c controller) GetAllShipments(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	shipments, err := c.processingSvc.GetAllShipments(ctx, callerAccountID(r))
 	if err != nil {
-		log.Println("Failed to get shipments, error:", err.Error())
-		models.PrintHTTPResult(w, http.StatusInternalServerError, err.Error())
+		printError(ctx, w, r, "Failed to get shipments", err)
 		return
 	}
 
-	models.PrintHTTPResult(w, http.StatusOK, shipments)
+	models.PrintHTTPResult(w, http.StatusOK, c.representation.shipments(shipments))
 }
 
 // CreateNewShipment creates new shipment
 func (c controller) CreateNewShipment(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
-	shipment := models.Shipment{
-		From: models.Customer{},
-		To:   models.Customer{},
-	}
+	ctx := r.Context()
 
-	if err := json.NewDecoder(r.Body).Decode(&shipment); err != nil {
-		log.Println("Failed to parse body, e:", err.Error())
+	shipment, err := c.representation.decodeShipment(r.Body)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to parse body", "error", err)
 		models.PrintHTTPResult(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	err := shipment.Validate()
+	shipment.AccountID = callerAccountID(r)
+
+	shipment, err = c.processingSvc.ResolveSavedAddresses(ctx, shipment)
 	if err != nil {
-		log.Println("Request body validation failed: ", err.Error())
-		models.PrintHTTPResult(w, http.StatusBadRequest, err.Error())
+		printError(ctx, w, r, "Failed to resolve saved address", err)
 		return
 	}
 
-	err = c.processingSvc.CreateNewShipment(shipment)
+	shipment.Normalize()
+
+	err = shipment.Validate()
 	if err != nil {
-		log.Println("Failed to save shipment details, error:", err.Error())
-		models.PrintHTTPResult(w, http.StatusInternalServerError, err.Error())
+		logging.FromContext(ctx).Warn("Request body validation failed", "error", err)
+		printValidationProblem(w, r, c.representation.shipmentErrors(err))
+		return
+	}
+
+	err = c.processingSvc.CreateNewShipment(ctx, requestActor(r), shipment)
+	if err != nil {
+		printError(ctx, w, r, "Failed to save shipment details", err)
 		return
 	}
 
@@ -72,17 +115,22 @@ func (c controller) CreateNewShipment(w http.ResponseWriter, r *http.Request) {
 
 // GetShipmentByID retrieves shipment by id specified in request
 func (c controller) GetShipmentByID(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
 	shipmentID, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		log.Println("Failed to convert ID, error:", err.Error())
+		logging.FromContext(ctx).Warn("Failed to convert ID", "error", err)
 		models.PrintHTTPResult(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	shipment, err := c.processingSvc.GetShipmentDetailsByID(shipmentID)
+	ctx = logging.With(ctx, "shipment_id", shipmentID)
+
+	shipment, err := c.processingSvc.GetShipmentDetailsByID(ctx, callerAccountID(r), shipmentID)
 	if err != nil {
-		log.Println("Failed to get shipment details, error:", err.Error())
-		models.PrintHTTPResult(w, http.StatusInternalServerError, err.Error())
+		printError(ctx, w, r, "Failed to get shipment details", err)
+		return
 	}
 
-	models.PrintHTTPResult(w, http.StatusOK, shipment)
+	models.PrintHTTPResult(w, http.StatusOK, c.representation.shipment(shipment))
 }
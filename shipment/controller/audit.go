@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"net/http"
+	"sendify_test/shipment/models"
+	"strconv"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+var auditedEntities = map[string]bool{
+	models.EntityShipment:     true,
+	models.EntityCustomer:     true,
+	models.EntitySavedAddress: true,
+}
+
+// GetAuditLog responds with the latest audit log entries about entities of
+// the kind specified by "entity" query parameter, optionally filtered by
+// "id" of the entity
+func (c controller) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	query := r.URL.Query()
+
+	entity := query.Get("entity")
+	if !auditedEntities[entity] {
+		models.PrintHTTPResult(w, http.StatusBadRequest, "entity must be one of shipment, customer, saved_address")
+		return
+	}
+
+	entityID := 0
+	if value := query.Get("id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			models.PrintHTTPResult(w, http.StatusBadRequest, "id must be positive integer")
+			return
+		}
+		entityID = id
+	}
+
+	limit := defaultAuditLimit
+	if value := query.Get("limit"); value != "" {
+		l, err := strconv.Atoi(value)
+		if err != nil || l <= 0 || l > maxAuditLimit {
+			models.PrintHTTPResult(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = l
+	}
+
+	entries, err := c.processingSvc.GetAuditLog(ctx, callerAccountID(r), entity, entityID, limit)
+	if err != nil {
+		printError(ctx, w, r, "Failed to get audit log", err)
+		return
+	}
+
+	if entries == nil {
+		entries = models.AuditEntries{}
+	}
+
+	models.PrintHTTPResult(w, http.StatusOK, entries)
+}
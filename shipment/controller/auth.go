@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"sendify_test/shipment/auth"
+	"sendify_test/shipment/logging"
+	"sendify_test/shipment/models"
+	"sendify_test/shipment/processing"
+	"strings"
+)
+
+// apiKeyHeader is alternative to "Authorization: Bearer <key>" header
+const apiKeyHeader = "X-API-Key"
+
+// Authenticate is middleware passing requests with valid API key or bearer
+// token (JWT) only, caller is stored in request context
+func (c controller) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		caller, err := c.authenticate(r)
+		if err == processing.ErrInvalidAPIKey || errors.Is(err, auth.ErrInvalidToken) {
+			logging.FromContext(ctx).Warn("Authentication failed", "error", err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="shipment"`)
+			models.PrintHTTPResult(w, http.StatusUnauthorized, "invalid credentials")
+			return
+		} else if err != nil {
+			printError(ctx, w, r, "Failed to authenticate caller", err)
+			return
+		}
+
+		ctx = logging.With(ctx, "caller", caller.String(), "account_id", caller.AccountID)
+		next.ServeHTTP(w, r.WithContext(auth.NewContext(ctx, caller)))
+	})
+}
+
+func (c controller) authenticate(r *http.Request) (auth.Caller, error) {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		return c.processingSvc.AuthenticateAPIKey(r.Context(), key)
+	}
+
+	credential := bearerCredential(r)
+	if c.tokenVerifier != nil && auth.IsToken(credential) {
+		return c.tokenVerifier.Verify(credential)
+	}
+	return c.processingSvc.AuthenticateAPIKey(r.Context(), credential)
+}
+
+func bearerCredential(r *http.Request) string {
+	authorization := r.Header.Get("Authorization")
+	if len(authorization) > len("Bearer ") && strings.EqualFold(authorization[:len("Bearer ")], "Bearer ") {
+		return strings.TrimSpace(authorization[len("Bearer "):])
+	}
+	return ""
+}
+
+// Authorize wraps handler to be called only by callers having the permission,
+// others get 403 Forbidden
+func (c controller) Authorize(permission auth.Permission, handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller, _ := auth.FromContext(r.Context())
+		if !caller.Can(permission) {
+			models.PrintHTTPResult(w, http.StatusForbidden, "permission "+string(permission)+" is required")
+			return
+		}
+
+		handler(w, r)
+	})
+}
+
+// callerAccountID returns account of authenticated caller, requests reach
+// handlers through Authenticate only
+func callerAccountID(r *http.Request) int {
+	caller, _ := auth.FromContext(r.Context())
+	return caller.AccountID
+}
+
+// requestActor returns authenticated caller as actor of audited operations
+func requestActor(r *http.Request) models.Actor {
+	caller, _ := auth.FromContext(r.Context())
+	return models.Actor{
+		ID:        caller.String(),
+		RequestID: logging.RequestID(r.Context()),
+	}
+}
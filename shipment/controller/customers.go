@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+	"sendify_test/shipment/logging"
+	"sendify_test/shipment/models"
+	"strconv"
+)
+
+// GetDuplicateCustomers responds with pairs of customers which are likely
+// the same person
+func (c controller) GetDuplicateCustomers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	candidates, err := c.processingSvc.FindDuplicateCustomers(ctx, callerAccountID(r))
+	if err != nil {
+		printError(ctx, w, r, "Failed to find duplicate customers", err)
+		return
+	}
+
+	models.PrintHTTPResult(w, http.StatusOK, c.representation.duplicateCandidates(candidates))
+}
+
+// MergeCustomers merges duplicate customers into surviving one
+func (c controller) MergeCustomers(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	ctx := r.Context()
+
+	var request models.MergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		logging.FromContext(ctx).Warn("Failed to parse body", "error", err)
+		models.PrintHTTPResult(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		logging.FromContext(ctx).Warn("Request body validation failed", "error", err)
+		printValidationProblem(w, r, err)
+		return
+	}
+
+	ctx = logging.With(ctx, "customer_id", request.SurvivorID)
+
+	merges, err := c.processingSvc.MergeCustomers(ctx, requestActor(r), callerAccountID(r), request)
+	if err != nil {
+		printError(ctx, w, r, "Failed to merge customers", err)
+		return
+	}
+
+	models.PrintHTTPResult(w, http.StatusOK, map[string]interface{}{
+		"survivor_id": request.SurvivorID,
+		"merges":      merges,
+	})
+}
+
+// ExportCustomerData responds with all personal data of the customer and
+// the customer's shipments
+func (c controller) ExportCustomerData(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	customerID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to convert ID", "error", err)
+		models.PrintHTTPResult(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx = logging.With(ctx, "customer_id", customerID)
+
+	export, err := c.processingSvc.ExportCustomerData(ctx, callerAccountID(r), customerID)
+	if err != nil {
+		printError(ctx, w, r, "Failed to export customer data", err)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="customer-%d.json"`, customerID))
+	models.PrintHTTPResult(w, http.StatusOK, c.representation.customerDataExport(export))
+}
+
+// EraseCustomer pseudonymises personal data of the customer
+func (c controller) EraseCustomer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	customerID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to convert ID", "error", err)
+		models.PrintHTTPResult(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx = logging.With(ctx, "customer_id", customerID)
+
+	customer, err := c.processingSvc.EraseCustomer(ctx, requestActor(r), callerAccountID(r), customerID)
+	if err != nil {
+		printError(ctx, w, r, "Failed to erase customer", err)
+		return
+	}
+
+	models.PrintHTTPResult(w, http.StatusOK, c.representation.customer(customer))
+}
@@ -0,0 +1,285 @@
+//go:build cgo
+// +build cgo
+
+package controller_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"sendify_test/shipment/auth"
+	"sendify_test/shipment/controller"
+	repo "sendify_test/shipment/db"
+	"sendify_test/shipment/encryption"
+	"sendify_test/shipment/logging"
+	"sendify_test/shipment/openapi"
+	"sendify_test/shipment/processing"
+	"sendify_test/shipment/ratelimit"
+	"strings"
+	"testing"
+	"time"
+)
+
+// update rewrites golden files by actual responses, e.g.
+// go test ./controller -run TestAPI -update
+var update = flag.Bool("update", false, "update golden files")
+
+// timestamps are replaced in responses, so golden files don't depend on time
+var timestamps = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`)
+
+// newServer starts the full stack of the service against SQLite database in
+// temporary file, returns API keys of admin and viewer of the same account
+func newServer(t *testing.T) (server *httptest.Server, adminKey, viewerKey string) {
+	ctx := context.Background()
+
+	db, err := gorm.Open(repo.DialectSQLite, filepath.Join(t.TempDir(), "shipment.db")+"?_foreign_keys=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.LogMode(false)
+	db.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := repo.NewMigrator(db.DB(), repo.DialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	keyring, err := encryption.NewKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}, bytes.Repeat([]byte{2}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	processingService := processing.NewService(
+		repo.NewTransactor(db, keyring),
+		repo.NewShipmentsRepo(db),
+		repo.NewCustomersRepo(db, keyring),
+		repo.NewAddressBookRepo(db, keyring),
+		repo.NewAccountsRepo(db),
+		repo.NewAuditRepo(db),
+		repo.NewHealthRepo(db),
+	)
+
+	account, _, adminKey, err := processingService.CreateAccount(ctx, "E2E")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, viewerKey, err = processingService.CreateAPIKey(ctx, account.ID, "viewer", auth.RoleViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limit := ratelimit.Limit{Count: 1000, Period: time.Minute}
+	router := controller.NewRouter(controller.NewApiController(processingService, nil), controller.RouterConfig{
+		Logger:        logging.New(ioutil.Discard, logging.LevelError),
+		IPLimiter:     ratelimit.NewLimiter(ratelimit.NewMemoryStore(), limit, nil),
+		CallerLimiter: ratelimit.NewLimiter(ratelimit.NewMemoryStore(), limit, nil),
+
+		UnversionedSunset: time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC),
+	})
+
+	server = httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, adminKey, viewerKey
+}
+
+const (
+	noKey = iota
+	adminKey
+	viewerKey
+)
+
+const shipmentBody = `{
+	"weight": 10,
+	"from": {"name": "Daniel Svensson", "email": "daniel@sendify.se", "phone": "+46701234567", "country_code": "SE",
+		"address": {"street_lines": ["Volrat Thamsgatan"], "house_number": "4", "postal_code": "41260", "city": "Göteborg"}},
+	"to": {"name": "Anna Svensson", "email": "anna@sendify.se", "country_code": "DK",
+		"address": {"street_lines": ["Vesterbrogade"], "house_number": "1", "postal_code": "1620", "city": "København"}}
+}`
+
+const shipmentBodyV2 = `{
+	"parcels": [{"weight": 10}, {"weight": 5}],
+	"from": {"name": "Daniel Svensson", "email": "daniel@sendify.se", "phone": "+46701234567",
+		"address": {"street_lines": ["Volrat Thamsgatan"], "house_number": "4", "postal_code": "41260", "city": "Göteborg", "country_code": "SE"}},
+	"to": {"name": "Anna Svensson", "email": "anna@sendify.se",
+		"address": {"street_lines": ["Vesterbrogade"], "house_number": "1", "postal_code": "1620", "city": "København", "country_code": "DK"}}
+}`
+
+// TestAPI runs requests to every route in order against a single server,
+// responses are compared with golden files in testdata/e2e and validated
+// against OpenAPI document
+func TestAPI(t *testing.T) {
+	server, admin, viewer := newServer(t)
+	document, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		key        int
+		body       string
+		wantStatus int
+	}{
+		{"healthz", http.MethodGet, "/healthz", noKey, "", http.StatusOK},
+		{"readyz", http.MethodGet, "/readyz", noKey, "", http.StatusOK},
+		{"unknown_route", http.MethodGet, "/unknown", adminKey, "", http.StatusNotFound},
+		{"unauthenticated", http.MethodGet, "/shipment/list", noKey, "", http.StatusUnauthorized},
+
+		{"list_shipments_empty", http.MethodGet, "/shipment/list", adminKey, "", http.StatusOK},
+		{"create_shipment", http.MethodPost, "/shipment", adminKey, shipmentBody, http.StatusCreated},
+		{"create_shipment_forbidden", http.MethodPost, "/shipment", viewerKey, shipmentBody, http.StatusForbidden},
+		{"create_shipment_malformed", http.MethodPost, "/shipment", adminKey, `{"weight":`, http.StatusBadRequest},
+		{"create_shipment_invalid", http.MethodPost, "/shipment", adminKey,
+			strings.Replace(shipmentBody, `"weight": 10`, `"weight": 0`, 1), http.StatusUnprocessableEntity},
+		{"get_shipment", http.MethodGet, "/shipment/1", viewerKey, "", http.StatusOK},
+		{"get_shipment_missing", http.MethodGet, "/shipment/100", adminKey, "", http.StatusNotFound},
+		{"list_shipments", http.MethodGet, "/shipment/list", adminKey, "", http.StatusOK},
+
+		{"create_address", http.MethodPost, "/address", adminKey, `{"label": "Warehouse", "name": "Daniel  Svenson",
+			"email": "Daniel@sendify.se", "country_code": "SE",
+			"address": {"street_lines": ["Volrat Thamsgatan"], "house_number": "4", "postal_code": "41260", "city": "Göteborg"}}`,
+			http.StatusCreated},
+		{"create_address_invalid", http.MethodPost, "/address", adminKey, `{"country_code": "SE"}`, http.StatusUnprocessableEntity},
+		{"get_address_book", http.MethodGet, "/address", viewerKey, "", http.StatusOK},
+		{"get_address", http.MethodGet, "/address/1", viewerKey, "", http.StatusOK},
+		{"get_address_missing", http.MethodGet, "/address/100", adminKey, "", http.StatusNotFound},
+		{"update_address", http.MethodPut, "/address/1", adminKey, `{"label": "Main warehouse", "name": "Daniel  Svenson",
+			"email": "Daniel@sendify.se", "phone": "+46701234567", "country_code": "SE",
+			"address": {"street_lines": ["Volrat Thamsgatan"], "house_number": "4", "postal_code": "41260", "city": "Göteborg"}}`,
+			http.StatusOK},
+		{"create_shipment_from_address", http.MethodPost, "/shipment", adminKey,
+			`{"weight": 30, "from": {"address_id": 1}, "to": {"name": "Anna Svensson", "email": "anna@sendify.se", "country_code": "DK",
+			"address": {"street_lines": ["Vesterbrogade"], "house_number": "1", "postal_code": "1620", "city": "København"}}}`,
+			http.StatusCreated},
+		{"create_shipment_missing_address", http.MethodPost, "/shipment", adminKey,
+			`{"weight": 30, "from": {"address_id": 100}, "to": {"address_id": 1}}`, http.StatusUnprocessableEntity},
+		{"delete_address", http.MethodDelete, "/address/1", adminKey, "", http.StatusOK},
+		{"delete_address_missing", http.MethodDelete, "/address/1", adminKey, "", http.StatusNotFound},
+
+		{"get_duplicates", http.MethodGet, "/customer/duplicates", viewerKey, "", http.StatusOK},
+		{"merge_customers", http.MethodPost, "/customer/merge", adminKey, `{"survivor_id": 1, "duplicate_ids": [3]}`, http.StatusOK},
+		{"merge_customers_missing", http.MethodPost, "/customer/merge", adminKey, `{"survivor_id": 1, "duplicate_ids": [3]}`, http.StatusNotFound},
+		{"merge_customers_invalid", http.MethodPost, "/customer/merge", adminKey, `{"survivor_id": 1, "duplicate_ids": [1]}`, http.StatusUnprocessableEntity},
+		{"export_customer", http.MethodGet, "/customer/1/gdpr-export", adminKey, "", http.StatusOK},
+		{"export_customer_forbidden", http.MethodGet, "/customer/1/gdpr-export", viewerKey, "", http.StatusForbidden},
+		{"erase_customer", http.MethodPost, "/customer/1/erase", adminKey, "", http.StatusOK},
+		{"erase_customer_missing", http.MethodPost, "/customer/100/erase", adminKey, "", http.StatusNotFound},
+		{"get_erased_shipment", http.MethodGet, "/shipment/1", adminKey, "", http.StatusOK},
+
+		{"get_audit_log", http.MethodGet, "/audit?entity=customer&limit=3", adminKey, "", http.StatusOK},
+		{"get_audit_log_of_shipment", http.MethodGet, "/audit?entity=shipment&id=1", adminKey, "", http.StatusOK},
+		{"get_audit_log_invalid", http.MethodGet, "/audit?entity=account", adminKey, "", http.StatusBadRequest},
+
+		{"v1_create_shipment", http.MethodPost, "/v1/shipment", adminKey, shipmentBody, http.StatusCreated},
+		{"v1_create_shipment_invalid", http.MethodPost, "/v1/shipment", adminKey,
+			strings.Replace(shipmentBody, `"weight": 10`, `"weight": 0`, 1), http.StatusUnprocessableEntity},
+		{"v2_create_shipment", http.MethodPost, "/v2/shipment", adminKey, shipmentBodyV2, http.StatusCreated},
+		{"v2_create_shipment_invalid", http.MethodPost, "/v2/shipment", adminKey,
+			strings.Replace(strings.Replace(shipmentBodyV2, `{"weight": 5}`, `{"weight": 0}`, 1), `"country_code": "SE"`, `"country_code": "QQ"`, 1),
+			http.StatusUnprocessableEntity},
+		{"v2_create_shipment_without_parcels", http.MethodPost, "/v2/shipment", adminKey,
+			strings.Replace(shipmentBodyV2, `[{"weight": 10}, {"weight": 5}]`, `[]`, 1), http.StatusUnprocessableEntity},
+		{"v1_get_shipment", http.MethodGet, "/v1/shipment/4", viewerKey, "", http.StatusOK},
+		{"v2_get_shipment", http.MethodGet, "/v2/shipment/4", viewerKey, "", http.StatusOK},
+		{"v2_get_erased_shipment", http.MethodGet, "/v2/shipment/1", viewerKey, "", http.StatusOK},
+		{"v2_list_shipments", http.MethodGet, "/v2/shipment/list", adminKey, "", http.StatusOK},
+
+		{"v2_create_address", http.MethodPost, "/v2/address", adminKey, `{"label": "Office", "name": "Anna Svensson",
+			"email": "anna@sendify.se",
+			"address": {"street_lines": ["Vesterbrogade"], "house_number": "1", "postal_code": "1620", "city": "København", "country_code": "DK"}}`,
+			http.StatusCreated},
+		{"v2_create_address_invalid", http.MethodPost, "/v2/address", adminKey, `{"address": {"country_code": "QQ"}}`, http.StatusUnprocessableEntity},
+		{"v2_update_address", http.MethodPut, "/v2/address/2", adminKey, `{"label": "Main office", "name": "Anna Svensson",
+			"email": "anna@sendify.se", "phone": "+4532123456",
+			"address": {"street_lines": ["Vesterbrogade"], "house_number": "1", "postal_code": "1620", "city": "København", "country_code": "DK"}}`,
+			http.StatusOK},
+		{"v2_get_address_book", http.MethodGet, "/v2/address", viewerKey, "", http.StatusOK},
+		{"v1_get_address", http.MethodGet, "/v1/address/2", viewerKey, "", http.StatusOK},
+
+		{"v2_get_duplicates", http.MethodGet, "/v2/customer/duplicates", viewerKey, "", http.StatusOK},
+		{"v2_export_customer", http.MethodGet, "/v2/customer/2/gdpr-export", adminKey, "", http.StatusOK},
+		{"v1_export_customer", http.MethodGet, "/v1/customer/2/gdpr-export", adminKey, "", http.StatusOK},
+		{"v2_erase_customer", http.MethodPost, "/v2/customer/2/erase", adminKey, "", http.StatusOK},
+		{"v2_get_audit_log", http.MethodGet, "/v2/audit?entity=shipment&id=4", adminKey, "", http.StatusOK},
+	}
+
+	keys := map[int]string{adminKey: admin, viewerKey: viewer}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, server.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set(logging.RequestIDHeader, "e2e-"+tt.name)
+			if key, ok := keys[tt.key]; ok {
+				req.Header.Set("X-API-Key", key)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode, string(body))
+			if operation, _, ok := document.Find(tt.method, req.URL.Path); ok {
+				err := document.ValidateResponse(tt.method, req.URL.Path, resp.StatusCode, resp.Header.Get("Content-Type"), body)
+				assert.NoError(t, err, "response doesn't match OpenAPI document")
+
+				// unversioned routes are deprecated aliases of v1
+				if operation.Deprecated {
+					assert.Equal(t, "@1792368000", resp.Header.Get("Deprecation"))
+					assert.Equal(t, "Mon, 19 Apr 2027 00:00:00 GMT", resp.Header.Get("Sunset"))
+					assert.Equal(t, `</v1`+req.URL.Path+`>; rel="successor-version"`, resp.Header.Get("Link"))
+				} else {
+					assert.Empty(t, resp.Header.Get("Deprecation"))
+				}
+			}
+			assertGolden(t, filepath.Join("testdata", "e2e", tt.name+".golden"), normalize(body))
+		})
+	}
+}
+
+// normalize indents JSON body and replaces timestamps in it
+func normalize(body []byte) []byte {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, body, "", "  "); err == nil {
+		body = indented.Bytes()
+	}
+	body = timestamps.ReplaceAll(body, []byte("<time>"))
+	return append(bytes.TrimSpace(body), '\n')
+}
+
+func assertGolden(t *testing.T, path string, actual []byte) {
+	if *update {
+		if err := ioutil.WriteFile(path, actual, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("golden file %s is missing, run tests with -update to create it: %v", path, err)
+	}
+	assert.Equal(t, string(expected), string(actual), "response differs from %s", path)
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sendify_test/shipment/errs"
+	"sendify_test/shipment/logging"
+	"sendify_test/shipment/models"
+	"sendify_test/shipment/processing"
+)
+
+// errorStatuses are HTTP statuses of domain error kinds, other errors are
+// responded with 500 Internal Server Error
+var errorStatuses = map[errs.Kind]int{
+	errs.NotFound:    http.StatusNotFound,
+	errs.Conflict:    http.StatusConflict,
+	errs.Validation:  http.StatusUnprocessableEntity,
+	errs.Unavailable: http.StatusServiceUnavailable,
+}
+
+// printError logs err and responds with HTTP status of its kind, see
+// errorStatuses, or with 504 Gateway Timeout if query exceeded its deadline.
+// Only messages of domain errors are responded, details of other errors
+// aren't exposed to clients. Validation errors are responded as problem
+// details, as invalid request bodies are. Nothing is responded to client
+// which has gone away
+func printError(ctx context.Context, w http.ResponseWriter, r *http.Request, message string, err error) {
+	logger := logging.FromContext(ctx)
+
+	var timeout *processing.TimeoutError
+	if ctx.Err() == context.Canceled {
+		logger.Warn(message+", request cancelled by client", "error", err)
+		return
+	} else if errors.As(err, &timeout) {
+		logger.Error(message, "error", err, "operation", timeout.Operation)
+		models.PrintHTTPProblem(w, models.Problem{
+			Type:     models.TimeoutProblemType,
+			Title:    "Query timed out",
+			Status:   http.StatusGatewayTimeout,
+			Detail:   "query " + timeout.Operation + " exceeded its deadline",
+			Instance: r.URL.Path,
+		})
+		return
+	}
+
+	kind := errs.KindOf(err)
+	status, ok := errorStatuses[kind]
+	if !ok {
+		logger.Error(message, "error", err)
+		models.PrintHTTPResult(w, http.StatusInternalServerError, nil)
+		return
+	}
+
+	if kind == errs.Unavailable {
+		logger.Error(message, "error", err)
+	} else {
+		logger.Warn(message, "error", err, "kind", kind.String())
+	}
+	if kind == errs.Validation {
+		printValidationProblem(w, r, err)
+		return
+	}
+	models.PrintHTTPResult(w, status, errs.Message(err))
+}
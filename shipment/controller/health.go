@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"sendify_test/shipment/errs"
+	"sendify_test/shipment/logging"
+	"sendify_test/shipment/models"
+	"time"
+)
+
+// readinessTimeout limits time of readiness checks, so probes of orchestrator
+// don't pile up while DB is slow
+const readinessTimeout = 2 * time.Second
+
+// Healthz responds while process is able to serve requests (liveness probe)
+func (c controller) Healthz(w http.ResponseWriter, _ *http.Request) {
+	models.PrintHTTPResult(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz responds with 503 Service Unavailable while DB is unreachable or
+// its schema is not migrated (readiness probe)
+func (c controller) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	if err := c.processingSvc.CheckReadiness(ctx); err != nil {
+		logging.FromContext(ctx).Warn("Service is not ready", "error", err)
+		// causes are logged only, they may contain details of DB
+		models.PrintHTTPResult(w, http.StatusServiceUnavailable, errs.Message(err))
+		return
+	}
+
+	models.PrintHTTPResult(w, http.StatusOK, map[string]string{"status": "ready"})
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"github.com/gorilla/mux"
+	"net/http"
+	"sendify_test/shipment/auth"
+	"sendify_test/shipment/logging"
+	"sendify_test/shipment/metrics"
+	"sendify_test/shipment/openapi"
+	"sendify_test/shipment/ratelimit"
+	"sendify_test/shipment/tracing"
+	"time"
+)
+
+// RouterConfig configures middlewares of API routes
+type RouterConfig struct {
+	Logger *logging.Logger
+
+	// IPLimiter limits requests per client IP before authentication,
+	// CallerLimiter limits requests per caller after it
+	IPLimiter         *ratelimit.Limiter
+	CallerLimiter     *ratelimit.Limiter
+	TrustForwardedFor bool
+
+	// OpenAPI validates requests of authenticated callers against the
+	// document when set
+	OpenAPI *openapi.Document
+
+	// UnversionedSunset is the date after which deprecated unversioned
+	// routes may be removed, it is not announced if zero
+	UnversionedSunset time.Time
+}
+
+// NewRouter returns router serving all routes of the API by the controller
+func NewRouter(c Controller, cfg RouterConfig) *mux.Router {
+	// probes of orchestrator, metrics scraping and API documentation are
+	// neither authenticated nor rate limited
+	root := mux.NewRouter()
+	root.Use(logging.Middleware(cfg.Logger))
+	root.Use(tracing.Middleware)
+	root.Use(metrics.Middleware)
+	root.HandleFunc("/healthz", c.Healthz).Methods(http.MethodGet)
+	root.HandleFunc("/readyz", c.Readyz).Methods(http.MethodGet)
+	root.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
+	root.Handle("/openapi.json", openapi.Handler()).Methods(http.MethodGet)
+	root.Handle("/docs", openapi.UIHandler()).Methods(http.MethodGet)
+
+	// each version of the API is served under its prefix, unversioned
+	// routes are deprecated aliases of v1
+	for _, version := range Versions {
+		router := root.PathPrefix("/" + string(version)).Subrouter()
+		useAPIMiddlewares(router, c, cfg)
+		handleAPI(router, c.WithVersion(version))
+	}
+
+	router := root.NewRoute().Subrouter()
+	router.Use(deprecated(cfg.UnversionedSunset))
+	useAPIMiddlewares(router, c, cfg)
+	handleAPI(router, c.WithVersion(V1))
+
+	return root
+}
+
+// useAPIMiddlewares authenticates and rate limits requests to API routes of
+// the router
+func useAPIMiddlewares(router *mux.Router, c Controller, cfg RouterConfig) {
+	// all endpoints require API key or bearer token and serve data of
+	// caller's account only, permissions of caller's role are checked per route.
+	// Requests are limited per client IP before authentication to slow down
+	// guessing of keys, and per caller after it
+	router.Use(cfg.IPLimiter.Middleware(ratelimit.ClientIP(cfg.TrustForwardedFor)))
+	router.Use(c.Authenticate)
+	router.Use(cfg.CallerLimiter.Middleware(auth.CallerKey))
+	if cfg.OpenAPI != nil {
+		router.Use(validateRequests(cfg.OpenAPI))
+	}
+}
+
+// handleAPI adds API routes served by the controller to the router
+func handleAPI(router *mux.Router, c Controller) {
+	shipmentEndpoint := router.PathPrefix("/shipment").Subrouter()
+
+	shipmentEndpoint.Handle("/list", c.Authorize(auth.PermShipmentsRead, c.GetAllShipments)).Methods(http.MethodGet)
+	shipmentEndpoint.Handle("", c.Authorize(auth.PermShipmentsCreate, c.CreateNewShipment)).Methods(http.MethodPost)
+	shipmentEndpoint.Handle("/{id:[0-9]+}", c.Authorize(auth.PermShipmentsRead, c.GetShipmentByID)).Methods(http.MethodGet)
+
+	addressBookEndpoint := router.PathPrefix("/address").Subrouter()
+
+	addressBookEndpoint.Handle("", c.Authorize(auth.PermAddressBookRead, c.GetAddressBook)).Methods(http.MethodGet)
+	addressBookEndpoint.Handle("", c.Authorize(auth.PermAddressBookWrite, c.CreateSavedAddress)).Methods(http.MethodPost)
+	addressBookEndpoint.Handle("/{id:[0-9]+}", c.Authorize(auth.PermAddressBookRead, c.GetSavedAddress)).Methods(http.MethodGet)
+	addressBookEndpoint.Handle("/{id:[0-9]+}", c.Authorize(auth.PermAddressBookWrite, c.UpdateSavedAddress)).Methods(http.MethodPut)
+	addressBookEndpoint.Handle("/{id:[0-9]+}", c.Authorize(auth.PermAddressBookWrite, c.DeleteSavedAddress)).Methods(http.MethodDelete)
+
+	customerEndpoint := router.PathPrefix("/customer").Subrouter()
+
+	customerEndpoint.Handle("/duplicates", c.Authorize(auth.PermCustomersRead, c.GetDuplicateCustomers)).Methods(http.MethodGet)
+	customerEndpoint.Handle("/merge", c.Authorize(auth.PermCustomersMerge, c.MergeCustomers)).Methods(http.MethodPost)
+	customerEndpoint.Handle("/{id:[0-9]+}/gdpr-export", c.Authorize(auth.PermCustomersExport, c.ExportCustomerData)).Methods(http.MethodGet)
+	customerEndpoint.Handle("/{id:[0-9]+}/erase", c.Authorize(auth.PermCustomersErase, c.EraseCustomer)).Methods(http.MethodPost)
+
+	router.Handle("/audit", c.Authorize(auth.PermAuditRead, c.GetAuditLog)).Methods(http.MethodGet)
+}
@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"errors"
+	"github.com/gorilla/mux"
+	"net/http"
+	"sendify_test/shipment/metrics"
+	"sendify_test/shipment/models"
+	"sendify_test/shipment/openapi"
+	"sendify_test/shipment/validation"
+)
+
+// printValidationProblem responds with validation errors of request body and
+// counts them by reason
+func printValidationProblem(w http.ResponseWriter, r *http.Request, err error) {
+	var fieldErrors validation.Errors
+	if errors.As(err, &fieldErrors) {
+		for _, fieldErr := range fieldErrors {
+			metrics.ValidationFailed(fieldErr.Code)
+		}
+	} else {
+		metrics.ValidationFailed("invalid")
+	}
+
+	models.PrintValidationProblem(w, r, err)
+}
+
+// validateRequests responds with validation errors of parameters and body
+// of requests not matching OpenAPI document, before they reach handlers
+func validateRequests(document *openapi.Document) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := document.ValidateRequest(r); err != nil {
+				printValidationProblem(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"io"
+	"net/http"
+	v1 "sendify_test/shipment/api/v1"
+	v2 "sendify_test/shipment/api/v2"
+	"sendify_test/shipment/models"
+	"time"
+)
+
+// Version is version of the API, it defines JSON shapes of requests and
+// responses while handlers are shared by all versions
+type Version string
+
+const (
+	V1 Version = "v1"
+	V2 Version = "v2"
+)
+
+// Versions are all versions of the API, in order of their release
+var Versions = []Version{V1, V2}
+
+// representation converts internal models from and to JSON shapes of a
+// version of the API
+type representation interface {
+	decodeShipment(body io.Reader) (models.Shipment, error)
+	shipment(shipment models.Shipment) interface{}
+	shipments(shipments models.Shipments) interface{}
+	// shipmentErrors points validation errors of shipment to fields of the
+	// version
+	shipmentErrors(err error) error
+
+	decodeSavedAddress(body io.Reader) (models.SavedAddress, error)
+	savedAddress(address models.SavedAddress) interface{}
+	savedAddresses(addresses models.SavedAddresses) interface{}
+	savedAddressErrors(err error) error
+
+	customer(customer models.Customer) interface{}
+	duplicateCandidates(candidates models.DuplicateCandidates) interface{}
+	customerDataExport(export models.CustomerDataExport) interface{}
+}
+
+func representationOf(version Version) representation {
+	if version == V2 {
+		return v2Representation{}
+	}
+	return v1Representation{}
+}
+
+// v1Representation keeps JSON shapes the API had before it was versioned
+type v1Representation struct{}
+
+func (v1Representation) decodeShipment(body io.Reader) (models.Shipment, error) {
+	var shipment v1.Shipment
+	err := json.NewDecoder(body).Decode(&shipment)
+	return shipment.Model(), err
+}
+
+func (v1Representation) shipment(shipment models.Shipment) interface{} {
+	return v1.NewShipment(shipment)
+}
+
+func (v1Representation) shipments(shipments models.Shipments) interface{} {
+	return v1.NewShipments(shipments)
+}
+
+func (v1Representation) shipmentErrors(err error) error {
+	return v1.ShipmentErrors(err)
+}
+
+func (v1Representation) decodeSavedAddress(body io.Reader) (models.SavedAddress, error) {
+	var address models.SavedAddress
+	err := json.NewDecoder(body).Decode(&address)
+	return address, err
+}
+
+func (v1Representation) savedAddress(address models.SavedAddress) interface{} {
+	return address
+}
+
+func (v1Representation) savedAddresses(addresses models.SavedAddresses) interface{} {
+	if addresses == nil {
+		return models.SavedAddresses{}
+	}
+	return addresses
+}
+
+func (v1Representation) savedAddressErrors(err error) error {
+	return err
+}
+
+func (v1Representation) customer(customer models.Customer) interface{} {
+	return customer
+}
+
+func (v1Representation) duplicateCandidates(candidates models.DuplicateCandidates) interface{} {
+	if candidates == nil {
+		return models.DuplicateCandidates{}
+	}
+	return candidates
+}
+
+func (v1Representation) customerDataExport(export models.CustomerDataExport) interface{} {
+	return v1.NewCustomerDataExport(export)
+}
+
+// v2Representation has structured addresses with country, shipments of
+// several parcels and prices with currency
+type v2Representation struct{}
+
+func (v2Representation) decodeShipment(body io.Reader) (models.Shipment, error) {
+	var shipment v2.Shipment
+	err := json.NewDecoder(body).Decode(&shipment)
+	return shipment.Model(), err
+}
+
+func (v2Representation) shipment(shipment models.Shipment) interface{} {
+	return v2.NewShipment(shipment)
+}
+
+func (v2Representation) shipments(shipments models.Shipments) interface{} {
+	return v2.NewShipments(shipments)
+}
+
+func (v2Representation) shipmentErrors(err error) error {
+	return v2.Errors(err)
+}
+
+func (v2Representation) decodeSavedAddress(body io.Reader) (models.SavedAddress, error) {
+	var address v2.SavedAddress
+	err := json.NewDecoder(body).Decode(&address)
+	return address.Model(), err
+}
+
+func (v2Representation) savedAddress(address models.SavedAddress) interface{} {
+	return v2.NewSavedAddress(address)
+}
+
+func (v2Representation) savedAddresses(addresses models.SavedAddresses) interface{} {
+	return v2.NewSavedAddresses(addresses)
+}
+
+func (v2Representation) savedAddressErrors(err error) error {
+	return v2.Errors(err)
+}
+
+func (v2Representation) customer(customer models.Customer) interface{} {
+	return v2.NewCustomer(customer)
+}
+
+func (v2Representation) duplicateCandidates(candidates models.DuplicateCandidates) interface{} {
+	return v2.NewDuplicateCandidates(candidates)
+}
+
+func (v2Representation) customerDataExport(export models.CustomerDataExport) interface{} {
+	return v2.NewCustomerDataExport(export)
+}
+
+// deprecationDate is the date unversioned routes were deprecated in favour
+// of versioned ones
+var deprecationDate = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+
+// deprecated marks responses of unversioned routes as deprecated, RFC 9745,
+// with Sunset date after which they may be removed, RFC 8594, if it is not
+// zero, and links them to their successors in v1
+func deprecated(sunset time.Time) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", fmt.Sprintf("@%d", deprecationDate.Unix()))
+			if !sunset.IsZero() {
+				w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+			w.Header().Set("Link", fmt.Sprintf(`</%s%s>; rel="successor-version"`, V1, r.URL.Path))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
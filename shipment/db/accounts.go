@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	"sendify_test/shipment/errs"
+	"sendify_test/shipment/models"
+	"time"
+)
+
+type AccountsRepo struct {
+	db *gorm.DB
+}
+
+func NewAccountsRepo(db *gorm.DB) *AccountsRepo {
+	return &AccountsRepo{
+		db: db,
+	}
+}
+
+// GetAllAccounts retrieves all accounts from accounts table
+func (r AccountsRepo) GetAllAccounts(ctx context.Context) (models.Accounts, error) {
+	ctx, db, end := observe(ctx, r.db, "accounts", "GetAllAccounts")
+	defer end()
+
+	var accounts models.Accounts
+	err := db.
+		Table("accounts").
+		Order("accounts.id").
+		Find(&accounts).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+// InsertAccount inserts new account into accounts table and sets its ID
+func (r AccountsRepo) InsertAccount(ctx context.Context, account *models.Account) error {
+	ctx, db, end := observe(ctx, r.db, "accounts", "InsertAccount")
+	defer end()
+
+	now := time.Now()
+	insert := statement(db).
+		Insert("accounts").
+		Columns(
+			"name",
+			"created_at",
+		).
+		Values(
+			account.Name,
+			now,
+		)
+	id, err := insertReturningID(db, insert)
+	if err != nil {
+		return err
+	}
+
+	account.ID = id
+	account.CreatedAt = now
+	return nil
+}
+
+// GetActiveAPIKeyByHash retrieves not revoked API key by hash of the key
+func (r AccountsRepo) GetActiveAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	ctx, db, end := observe(ctx, r.db, "accounts", "GetActiveAPIKeyByHash")
+	defer end()
+
+	var key models.APIKey
+	err := db.
+		Table("api_keys").
+		Where("api_keys.hash = ? AND api_keys.revoked_at IS NULL", hash).
+		Take(&key).
+		Error
+	if err != nil {
+		return models.APIKey{}, notFound(err, "API key not found")
+	}
+
+	return key, nil
+}
+
+// InsertAPIKey inserts new API key into api_keys table and sets its ID,
+// account of the key must exist
+func (r AccountsRepo) InsertAPIKey(ctx context.Context, key *models.APIKey) error {
+	ctx, db, end := observe(ctx, r.db, "accounts", "InsertAPIKey")
+	defer end()
+
+	now := time.Now()
+	insert := statement(db).
+		Insert("api_keys").
+		Columns(
+			"account_id",
+			"name",
+			"role",
+			"prefix",
+			"hash",
+			"created_at",
+		).
+		Values(
+			key.AccountID,
+			key.Name,
+			key.Role,
+			key.Prefix,
+			key.Hash,
+			now,
+		)
+	id, err := insertReturningID(db, insert)
+	if err != nil {
+		return err
+	}
+
+	key.ID = id
+	key.CreatedAt = now
+	return nil
+}
+
+// RevokeAPIKey marks API key as revoked, returns errs.NotFound error if
+// there is no such active key
+func (r AccountsRepo) RevokeAPIKey(ctx context.Context, id int) error {
+	ctx, db, end := observe(ctx, r.db, "accounts", "RevokeAPIKey")
+	defer end()
+
+	result := db.
+		Table("api_keys").
+		Where("api_keys.id = ? AND api_keys.revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errs.New(errs.NotFound, "API key not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package db
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	"sendify_test/shipment/encryption"
+	"sendify_test/shipment/errs"
+	"sendify_test/shipment/models"
+	"time"
+)
+
+type AddressBookRepo struct {
+	db     *gorm.DB
+	cipher encryption.Cipher
+}
+
+func NewAddressBookRepo(db *gorm.DB, cipher encryption.Cipher) *AddressBookRepo {
+	return &AddressBookRepo{
+		db:     db,
+		cipher: cipher,
+	}
+}
+
+// GetAddressByID retrieves saved address from saved_addresses table by ID
+func (r AddressBookRepo) GetAddressByID(ctx context.Context, id int) (models.SavedAddress, error) {
+	ctx, db, end := observe(ctx, r.db, "address_book", "GetAddressByID")
+	defer end()
+
+	var address models.SavedAddress
+	err := db.
+		Table("saved_addresses").
+		Where("saved_addresses.id = ?", id).
+		Take(&address).
+		Error
+	if err != nil {
+		return models.SavedAddress{}, notFound(err, "saved address not found")
+	}
+
+	if err := savedAddressPII(&address).decrypt(r.cipher); err != nil {
+		return models.SavedAddress{}, err
+	}
+
+	return address, nil
+}
+
+// GetAddressesByAccountID retrieves all saved addresses owned by account
+func (r AddressBookRepo) GetAddressesByAccountID(ctx context.Context, accountID int) (models.SavedAddresses, error) {
+	ctx, db, end := observe(ctx, r.db, "address_book", "GetAddressesByAccountID")
+	defer end()
+
+	var addresses models.SavedAddresses
+	err := db.
+		Table("saved_addresses").
+		Where("saved_addresses.account_id = ?", accountID).
+		Order("saved_addresses.label").
+		Find(&addresses).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	if err := decryptAddresses(r.cipher, addresses); err != nil {
+		return nil, err
+	}
+
+	return addresses, nil
+}
+
+// InsertAddress inserts new saved address into saved_addresses table
+// and sets its ID
+func (r AddressBookRepo) InsertAddress(ctx context.Context, address *models.SavedAddress) error {
+	ctx, db, end := observe(ctx, r.db, "address_book", "InsertAddress")
+	defer end()
+
+	values, err := savedAddressPII(address).encrypt(r.cipher)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	values["account_id"] = address.AccountID
+	values["label"] = address.Label
+	values["country_code"] = address.CountryCode
+	values["created_at"] = now
+	values["updated_at"] = now
+
+	insert := statement(db).
+		Insert("saved_addresses").
+		SetMap(values)
+	id, err := insertReturningID(db, insert)
+	if err != nil {
+		return err
+	}
+
+	address.ID = id
+	address.CreatedAt = now
+	address.UpdatedAt = now
+	return nil
+}
+
+// UpdateAddress updates saved address owned by address.AccountID,
+// returns errs.NotFound error if there is no such address
+func (r AddressBookRepo) UpdateAddress(ctx context.Context, address models.SavedAddress) error {
+	ctx, db, end := observe(ctx, r.db, "address_book", "UpdateAddress")
+	defer end()
+
+	values, err := savedAddressPII(&address).encrypt(r.cipher)
+	if err != nil {
+		return err
+	}
+	values["label"] = address.Label
+	values["country_code"] = address.CountryCode
+	values["updated_at"] = time.Now()
+
+	result := db.
+		Table("saved_addresses").
+		Where("saved_addresses.id = ? AND saved_addresses.account_id = ?", address.ID, address.AccountID).
+		Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errs.New(errs.NotFound, "saved address not found")
+	}
+
+	return nil
+}
+
+// DeleteAddress deletes saved address owned by account,
+// returns errs.NotFound error if there is no such address
+func (r AddressBookRepo) DeleteAddress(ctx context.Context, accountID, id int) error {
+	ctx, db, end := observe(ctx, r.db, "address_book", "DeleteAddress")
+	defer end()
+
+	result := db.
+		Table("saved_addresses").
+		Where("saved_addresses.id = ? AND saved_addresses.account_id = ?", id, accountID).
+		Delete(models.SavedAddress{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errs.New(errs.NotFound, "saved address not found")
+	}
+
+	return nil
+}
+
+// ReencryptAddresses re-encrypts personal data of all saved addresses by
+// active key, returns number of updated addresses
+func (r AddressBookRepo) ReencryptAddresses(ctx context.Context) (int, error) {
+	ctx, db, end := observe(ctx, r.db, "address_book", "ReencryptAddresses")
+	defer end()
+
+	var addresses models.SavedAddresses
+	err := db.
+		Table("saved_addresses").
+		Order("saved_addresses.id").
+		Find(&addresses).
+		Error
+	if err != nil {
+		return 0, err
+	}
+
+	var updated int
+	for _, address := range addresses {
+		values, err := savedAddressPII(&address).reencrypt(r.cipher)
+		if err != nil {
+			return updated, err
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		err = db.
+			Table("saved_addresses").
+			Where("saved_addresses.id = ?", address.ID).
+			Updates(values).
+			Error
+		if err != nil {
+			return updated, err
+		}
+		updated++
+	}
+
+	return updated, nil
+}
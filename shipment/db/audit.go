@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	"sendify_test/shipment/models"
+)
+
+// AuditRepo appends entries to audit_log table, entries are never updated
+// or deleted
+type AuditRepo struct {
+	db *gorm.DB
+}
+
+func NewAuditRepo(db *gorm.DB) *AuditRepo {
+	return &AuditRepo{
+		db: db,
+	}
+}
+
+// InsertEntry appends entry to audit_log table, should be called in the
+// transaction of audited operation
+func (r AuditRepo) InsertEntry(ctx context.Context, entry models.AuditEntry) error {
+	ctx, db, end := observe(ctx, r.db, "audit", "InsertEntry")
+	defer end()
+
+	_, err := statement(db).
+		Insert("audit_log").
+		Columns(
+			"account_id",
+			"actor",
+			"action",
+			"entity",
+			"entity_id",
+			"changes",
+			"request_id",
+			"created_at",
+		).
+		Values(
+			entry.AccountID,
+			entry.Actor,
+			entry.Action,
+			entry.Entity,
+			entry.EntityID,
+			entry.Changes,
+			entry.RequestID,
+			entry.CreatedAt,
+		).
+		RunWith(db.CommonDB()).Exec()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetEntries retrieves the latest audit log entries of the account about
+// the entity, optionally of the entity with specified ID only
+func (r AuditRepo) GetEntries(ctx context.Context, accountID int, entity string, entityID int, limit int) (models.AuditEntries, error) {
+	ctx, db, end := observe(ctx, r.db, "audit", "GetEntries")
+	defer end()
+
+	query := db.
+		Table("audit_log").
+		Where("audit_log.account_id = ? AND audit_log.entity = ?", accountID, entity)
+	if entityID != 0 {
+		query = query.Where("audit_log.entity_id = ?", entityID)
+	}
+
+	var entries models.AuditEntries
+	err := query.
+		Order("audit_log.id DESC").
+		Limit(limit).
+		Find(&entries).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
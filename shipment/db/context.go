@@ -0,0 +1,196 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QueryTimeouts are deadlines of repo methods, zero stands for no deadline
+type QueryTimeouts struct {
+	Default time.Duration
+	// Operations override default deadline, keyed by "<repo>.<Method>",
+	// e.g. "shipments.GetAllShipments"
+	Operations map[string]time.Duration
+}
+
+// jobOperations scan whole tables in jobs, so they aren't limited by
+// default deadline unless configured explicitly
+var jobOperations = map[string]time.Duration{
+	"customers.ReencryptCustomers":    0,
+	"address_book.ReencryptAddresses": 0,
+}
+
+var (
+	timeoutsMu sync.RWMutex
+	timeouts   = QueryTimeouts{}
+)
+
+// SetQueryTimeouts sets deadlines of repo methods, queries aren't limited
+// until it's called
+func SetQueryTimeouts(t QueryTimeouts) {
+	operations := map[string]time.Duration{}
+	for operation, timeout := range jobOperations {
+		operations[operation] = timeout
+	}
+	for operation, timeout := range t.Operations {
+		operations[operation] = timeout
+	}
+	t.Operations = operations
+
+	timeoutsMu.Lock()
+	defer timeoutsMu.Unlock()
+	timeouts = t
+}
+
+// ParseQueryTimeouts parses deadlines of operations in format
+// "<repo>.<Method>=<duration>", e.g. "shipments.GetAllShipments=10s"
+func ParseQueryTimeouts(values []string) (map[string]time.Duration, error) {
+	operations := map[string]time.Duration{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || !strings.Contains(parts[0], ".") {
+			return nil, fmt.Errorf("invalid query timeout %q, expected <repo>.<Method>=<duration>", value)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid query timeout %q, expected <repo>.<Method>=<duration>", value)
+		}
+		operations[strings.TrimSpace(parts[0])] = timeout
+	}
+	return operations, nil
+}
+
+func queryTimeout(operation string) time.Duration {
+	timeoutsMu.RLock()
+	defer timeoutsMu.RUnlock()
+	if timeout, ok := timeouts.Operations[operation]; ok {
+		return timeout
+	}
+	return timeouts.Default
+}
+
+// withQueryTimeout returns context with deadline of the operation, the
+// cancel function should be deferred
+func withQueryTimeout(ctx context.Context, operation string) (context.Context, context.CancelFunc) {
+	if timeout := queryTimeout(operation); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// TimeoutError is returned when query of the operation exceeds its deadline
+type TimeoutError struct {
+	Operation string
+	Err       error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("query %s timed out: %v", e.Operation, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// withContext returns db running queries of the operation with context, so
+// they are cancelled with it. gorm v1 has no context support, so queries
+// are run via context-aware methods of database/sql by wrappers below
+func withContext(ctx context.Context, db *gorm.DB, operation string) *gorm.DB {
+	var conn gorm.SQLCommon
+	switch c := db.CommonDB().(type) {
+	case *sql.DB:
+		conn = contextDB{contextConn{ctx: ctx, conn: c, operation: operation}, c}
+	case *sql.Tx:
+		conn = contextConn{ctx: ctx, conn: c, operation: operation}
+	case contextDB:
+		conn = contextDB{contextConn{ctx: ctx, conn: c.db, operation: operation}, c.db}
+	case contextConn:
+		conn = contextConn{ctx: ctx, conn: c.conn, operation: operation}
+	default:
+		return db
+	}
+
+	bound, err := gorm.Open(db.Dialect().GetName(), conn)
+	if err != nil {
+		return db
+	}
+	// errors are returned to and logged by callers only
+	return bound.LogMode(false)
+}
+
+// conn is implemented by both *sql.DB and *sql.Tx
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// contextConn implements gorm.SQLCommon and squirrel runners by running
+// queries with bound context
+type contextConn struct {
+	ctx       context.Context
+	conn      conn
+	operation string
+}
+
+func (c contextConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	result, err := c.conn.ExecContext(c.ctx, query, args...)
+	return result, c.wrap(err)
+}
+
+func (c contextConn) Prepare(query string) (*sql.Stmt, error) {
+	stmt, err := c.conn.PrepareContext(c.ctx, query)
+	return stmt, c.wrap(err)
+}
+
+func (c contextConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := c.conn.QueryContext(c.ctx, query, args...)
+	return rows, c.wrap(err)
+}
+
+func (c contextConn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.conn.QueryRowContext(c.ctx, query, args...)
+}
+
+// wrap returns TimeoutError if err is caused by exceeded deadline, driver
+// may report it as broken connection, so context is checked as well. Other
+// errors are translated into domain errors
+func (c contextConn) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.DeadlineExceeded) || c.ctx.Err() == context.DeadlineExceeded {
+		return &TimeoutError{Operation: c.operation, Err: err}
+	}
+	if c.ctx.Err() != nil {
+		return err
+	}
+	return translate(err)
+}
+
+// contextDB additionally begins transactions with bound context
+type contextDB struct {
+	contextConn
+	db *sql.DB
+}
+
+func (c contextDB) Begin() (*sql.Tx, error) {
+	return c.BeginTx(c.ctx, nil)
+}
+
+func (c contextDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	tx, err := c.db.BeginTx(ctx, opts)
+	return tx, c.wrap(err)
+}
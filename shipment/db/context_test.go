@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/mysql"
+	"github.com/stretchr/testify/assert"
+	"sendify_test/shipment/encryption"
+	"sendify_test/shipment/models"
+	"testing"
+	"time"
+)
+
+// blockingDriver runs queries until their context is done
+type blockingDriver struct{}
+
+func (blockingDriver) Open(string) (driver.Conn, error) {
+	return blockingConn{}, nil
+}
+
+type blockingConn struct{}
+
+func (blockingConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (blockingConn) Close() error {
+	return nil
+}
+
+func (blockingConn) Begin() (driver.Tx, error) {
+	return blockingConn{}, nil
+}
+
+func (blockingConn) Commit() error {
+	return nil
+}
+
+func (blockingConn) Rollback() error {
+	return nil
+}
+
+func (blockingConn) QueryContext(ctx context.Context, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockingConn) ExecContext(ctx context.Context, _ string, _ []driver.NamedValue) (driver.Result, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func init() {
+	sql.Register("blocking", blockingDriver{})
+}
+
+func openBlockingDB(t *testing.T) *gorm.DB {
+	sqlDB, err := sql.Open("blocking", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open("mysql", sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db.LogMode(false)
+}
+
+func TestParseQueryTimeouts(t *testing.T) {
+	timeouts, err := ParseQueryTimeouts([]string{"shipments.GetAllShipments=10s", " customers.ReencryptCustomers = 0 ", ""})
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]time.Duration{
+			"shipments.GetAllShipments":    10 * time.Second,
+			"customers.ReencryptCustomers": 0,
+		}, timeouts)
+	}
+
+	for _, value := range []string{"GetAllShipments=10s", "shipments.GetAllShipments", "shipments.GetAllShipments=ten", "shipments.GetAllShipments=-1s"} {
+		_, err := ParseQueryTimeouts([]string{value})
+		assert.Error(t, err, value)
+	}
+}
+
+func TestQueryTimeouts(t *testing.T) {
+	SetQueryTimeouts(QueryTimeouts{
+		Default:    20 * time.Millisecond,
+		Operations: map[string]time.Duration{"shipments.GetShipmentByID": time.Minute},
+	})
+	defer SetQueryTimeouts(QueryTimeouts{})
+
+	assert.Equal(t, 20*time.Millisecond, queryTimeout("shipments.GetAllShipments"))
+	assert.Equal(t, time.Minute, queryTimeout("shipments.GetShipmentByID"))
+	assert.Equal(t, time.Duration(0), queryTimeout("customers.ReencryptCustomers"))
+
+	repo := NewShipmentsRepo(openBlockingDB(t))
+
+	_, err := repo.GetAllShipments(context.Background(), 1)
+	var timeout *TimeoutError
+	if assert.True(t, errors.As(err, &timeout), "%v", err) {
+		assert.Equal(t, "shipments.GetAllShipments", timeout.Operation)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+
+	// queries are cancelled with caller context
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	_, err = repo.GetShipmentByID(ctx, 1, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, errors.As(err, &timeout))
+}
+
+func TestTransactor_Transaction(t *testing.T) {
+	SetQueryTimeouts(QueryTimeouts{Default: 20 * time.Millisecond})
+	defer SetQueryTimeouts(QueryTimeouts{})
+
+	db := openBlockingDB(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := NewTransactor(db, encryption.Plaintext{}).Transaction(ctx, func(tx Repositories) error {
+		return tx.Audit.InsertEntry(ctx, models.AuditEntry{})
+	})
+	var timeout *TimeoutError
+	if assert.True(t, errors.As(err, &timeout), "%v", err) {
+		assert.Equal(t, "audit.InsertEntry", timeout.Operation)
+	}
+
+	// transaction is not started with cancelled context
+	cancel()
+	called := false
+	err = NewTransactor(db, encryption.Plaintext{}).Transaction(ctx, func(tx Repositories) error {
+		called = true
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, called)
+}
@@ -0,0 +1,392 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sendify_test/shipment/encryption"
+	"sendify_test/shipment/errs"
+	"sendify_test/shipment/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newRepositories returns empty repositories of the backend under test
+type newRepositories func(t *testing.T) (ShipmentRepository, CustomerRepository)
+
+// testRepositoryContract checks behaviour every backend of ShipmentRepository
+// and CustomerRepository must have, so in-memory ones may replace SQL ones in
+// tests
+func testRepositoryContract(t *testing.T, newRepos newRepositories) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, shipments ShipmentRepository, customers CustomerRepository)
+	}{
+		{"customer IDs are assigned", testCustomerIDs},
+		{"customers are identified by match key", testCustomerMatchKey},
+		{"customers are unique by match key", testCustomerUnique},
+		{"customers of other accounts are missing", testCustomerAccounts},
+		{"shipment IDs are assigned", testShipmentIDs},
+		{"shipments of other accounts are missing", testShipmentAccounts},
+		{"merge repoints shipments", testMergeCustomers},
+		{"merge of missing customer fails", testMergeMissingCustomer},
+		{"merge moves earlier merges to survivor", testChainedMerge},
+		{"erase pseudonymises customer", testEraseCustomer},
+		{"retention skips erased customers", testRetention},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shipments, customers := newRepos(t)
+			tt.test(t, shipments, customers)
+		})
+	}
+}
+
+func testKeyring(t *testing.T) *encryption.Keyring {
+	keyring, err := encryption.NewKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}, bytes.Repeat([]byte{2}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyring
+}
+
+func testCustomer(accountID int, name string) models.Customer {
+	return models.Customer{
+		AccountID:   accountID,
+		Name:        name,
+		Email:       name + "@example.com",
+		Phone:       "+46701234567",
+		CountryCode: "SE",
+		Address: models.PostalAddress{
+			StreetLines: models.StreetLines{"Drottninggatan 1"},
+			PostalCode:  "111 51",
+			City:        "Stockholm",
+		},
+	}
+}
+
+func insertCustomer(t *testing.T, customers CustomerRepository, customer models.Customer) models.Customer {
+	if err := customers.InsertAndReturnCustomer(context.Background(), &customer); err != nil {
+		t.Fatal(err)
+	}
+	return customer
+}
+
+func insertShipment(t *testing.T, shipments ShipmentRepository, shipment models.Shipment) models.Shipment {
+	if err := shipments.InsertShipment(context.Background(), &shipment); err != nil {
+		t.Fatal(err)
+	}
+	return shipment
+}
+
+func testCustomerIDs(t *testing.T, _ ShipmentRepository, customers CustomerRepository) {
+	ctx := context.Background()
+	anna := insertCustomer(t, customers, testCustomer(1, "anna"))
+	erik := insertCustomer(t, customers, testCustomer(1, "erik"))
+
+	assert.NotZero(t, anna.ID)
+	assert.Greater(t, erik.ID, anna.ID)
+	assert.False(t, anna.CreatedAt.IsZero())
+
+	found, err := customers.GetCustomerByID(ctx, 1, erik.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "erik", found.Name)
+		assert.Equal(t, "erik@example.com", found.Email)
+		assert.Equal(t, "+46701234567", found.Phone)
+		assert.Equal(t, models.StreetLines{"Drottninggatan 1"}, found.Address.StreetLines)
+		assert.Nil(t, found.ErasedAt)
+	}
+
+	all, err := customers.GetAllCustomers(ctx, 1)
+	if assert.NoError(t, err) && assert.Len(t, all, 2) {
+		assert.Equal(t, anna.ID, all[0].ID)
+		assert.Equal(t, erik.ID, all[1].ID)
+	}
+
+	byIDs, err := customers.GetCustomersByIDs(ctx, 1, []int{erik.ID, erik.ID + 100})
+	if assert.NoError(t, err) && assert.Len(t, byIDs, 1) {
+		assert.Equal(t, erik.ID, byIDs[0].ID)
+	}
+
+	if assert.NoError(t, customers.UpdateCustomerPhone(ctx, erik.ID, "+46709876543")) {
+		found, err := customers.GetCustomerByID(ctx, 1, erik.ID)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "+46709876543", found.Phone)
+		}
+	}
+}
+
+func testCustomerMatchKey(t *testing.T, _ ShipmentRepository, customers CustomerRepository) {
+	ctx := context.Background()
+
+	missing := testCustomer(1, "anna")
+	err := customers.CheckIfCustomerPresentAndReturn(ctx, &missing)
+	assert.True(t, errs.Is(err, errs.NotFound), "missing customer: %v", err)
+
+	anna := insertCustomer(t, customers, testCustomer(1, "anna"))
+
+	// match key ignores case, whitespaces and phone
+	same := testCustomer(1, "anna")
+	same.Email = " ANNA@example.com "
+	same.Phone = ""
+	if assert.NoError(t, customers.CheckIfCustomerPresentAndReturn(ctx, &same)) {
+		assert.Equal(t, anna.ID, same.ID)
+		assert.Equal(t, "anna@example.com", same.Email)
+	}
+
+	again := insertCustomer(t, customers, testCustomer(1, "anna"))
+	assert.Equal(t, anna.ID, again.ID, "present customer is returned")
+
+	other := insertCustomer(t, customers, testCustomer(2, "anna"))
+	assert.NotEqual(t, anna.ID, other.ID, "customers of accounts are distinct")
+}
+
+func testCustomerUnique(t *testing.T, _ ShipmentRepository, customers CustomerRepository) {
+	ctx := context.Background()
+
+	ids := make([]int, 5)
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			customer := testCustomer(1, "anna")
+			if assert.NoError(t, customers.InsertAndReturnCustomer(ctx, &customer)) {
+				ids[i] = customer.ID
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, id := range ids {
+		assert.Equal(t, ids[0], id, "concurrent inserts return the same customer")
+	}
+
+	all, err := customers.GetAllCustomers(ctx, 1)
+	if assert.NoError(t, err) {
+		assert.Len(t, all, 1)
+	}
+}
+
+func testCustomerAccounts(t *testing.T, _ ShipmentRepository, customers CustomerRepository) {
+	ctx := context.Background()
+	anna := insertCustomer(t, customers, testCustomer(1, "anna"))
+
+	_, err := customers.GetCustomerByID(ctx, 2, anna.ID)
+	assert.True(t, errs.Is(err, errs.NotFound), "customer of other account: %v", err)
+
+	all, err := customers.GetAllCustomers(ctx, 2)
+	if assert.NoError(t, err) {
+		assert.Empty(t, all)
+	}
+
+	byIDs, err := customers.GetCustomersByIDs(ctx, 2, []int{anna.ID})
+	if assert.NoError(t, err) {
+		assert.Empty(t, byIDs)
+	}
+}
+
+func testShipmentIDs(t *testing.T, shipments ShipmentRepository, customers CustomerRepository) {
+	ctx := context.Background()
+	anna := insertCustomer(t, customers, testCustomer(1, "anna"))
+	erik := insertCustomer(t, customers, testCustomer(1, "erik"))
+
+	first := insertShipment(t, shipments, models.Shipment{AccountID: 1, Weight: 10, Parcels: models.Parcels{{Weight: 4}, {Weight: 6}},
+		Price: models.Price{Amount: 100, Currency: "SEK"}, FromID: anna.ID, ToID: erik.ID})
+	second := insertShipment(t, shipments, models.Shipment{AccountID: 1, Weight: 20, Parcels: models.Parcels{{Weight: 20}},
+		Price: models.Price{Amount: 200, Currency: "SEK"}, FromID: erik.ID, ToID: erik.ID})
+
+	assert.NotZero(t, first.ID)
+	assert.Greater(t, second.ID, first.ID)
+	assert.False(t, first.CreatedAt.IsZero())
+	assert.NotZero(t, first.Parcels[0].ID)
+	assert.Greater(t, first.Parcels[1].ID, first.Parcels[0].ID)
+
+	found, err := shipments.GetShipmentByID(ctx, 1, first.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 10, found.Weight)
+		assert.Equal(t, first.Parcels, found.Parcels)
+		assert.Equal(t, models.Price{Amount: 100, Currency: "SEK"}, found.Price)
+		assert.Equal(t, anna.ID, found.FromID)
+		assert.Equal(t, erik.ID, found.ToID)
+	}
+
+	all, err := shipments.GetAllShipments(ctx, 1)
+	if assert.NoError(t, err) && assert.Len(t, all, 2) {
+		assert.Equal(t, first.Parcels, all[0].Parcels)
+		assert.Equal(t, second.Parcels, all[1].Parcels)
+	}
+
+	byCustomer, err := shipments.GetShipmentsByCustomerID(ctx, 1, anna.ID)
+	if assert.NoError(t, err) && assert.Len(t, byCustomer, 1) {
+		assert.Equal(t, first.ID, byCustomer[0].ID)
+		assert.Equal(t, first.Parcels, byCustomer[0].Parcels)
+	}
+}
+
+func testShipmentAccounts(t *testing.T, shipments ShipmentRepository, customers CustomerRepository) {
+	ctx := context.Background()
+	anna := insertCustomer(t, customers, testCustomer(1, "anna"))
+	shipment := insertShipment(t, shipments, models.Shipment{AccountID: 1, Weight: 10, FromID: anna.ID, ToID: anna.ID})
+
+	_, err := shipments.GetShipmentByID(ctx, 2, shipment.ID)
+	assert.True(t, errs.Is(err, errs.NotFound), "shipment of other account: %v", err)
+
+	_, err = shipments.GetShipmentByID(ctx, 1, shipment.ID+100)
+	assert.True(t, errs.Is(err, errs.NotFound), "missing shipment: %v", err)
+
+	all, err := shipments.GetAllShipments(ctx, 2)
+	if assert.NoError(t, err) {
+		assert.Empty(t, all)
+	}
+
+	byCustomer, err := shipments.GetShipmentsByCustomerID(ctx, 2, anna.ID)
+	if assert.NoError(t, err) {
+		assert.Empty(t, byCustomer)
+	}
+}
+
+func testMergeCustomers(t *testing.T, shipments ShipmentRepository, customers CustomerRepository) {
+	ctx := context.Background()
+	anna := insertCustomer(t, customers, testCustomer(1, "anna"))
+	annaDuplicate := insertCustomer(t, customers, testCustomer(1, "anna b"))
+	erik := insertCustomer(t, customers, testCustomer(1, "erik"))
+
+	insertShipment(t, shipments, models.Shipment{AccountID: 1, Weight: 10, FromID: annaDuplicate.ID, ToID: erik.ID})
+	insertShipment(t, shipments, models.Shipment{AccountID: 1, Weight: 20, FromID: annaDuplicate.ID, ToID: annaDuplicate.ID})
+
+	merges, err := customers.MergeCustomers(ctx, 1, anna.ID, []int{annaDuplicate.ID})
+	if assert.NoError(t, err) && assert.Len(t, merges, 1) {
+		assert.NotZero(t, merges[0].ID)
+		assert.Equal(t, anna.ID, merges[0].SurvivorID)
+		assert.Equal(t, annaDuplicate.ID, merges[0].MergedID)
+		assert.Equal(t, 3, merges[0].ShipmentsMoved)
+		assert.Contains(t, merges[0].MergedCustomer, "anna b")
+	}
+
+	_, err = customers.GetCustomerByID(ctx, 1, annaDuplicate.ID)
+	assert.True(t, errs.Is(err, errs.NotFound), "merged customer: %v", err)
+
+	moved, err := shipments.GetShipmentsByCustomerID(ctx, 1, anna.ID)
+	if assert.NoError(t, err) {
+		assert.Len(t, moved, 2)
+	}
+
+	recorded, err := customers.GetMergesBySurvivorID(ctx, anna.ID)
+	if assert.NoError(t, err) && assert.Len(t, recorded, 1) {
+		assert.Equal(t, annaDuplicate.ID, recorded[0].MergedID)
+		assert.Contains(t, recorded[0].MergedCustomer, "anna b")
+	}
+}
+
+func testMergeMissingCustomer(t *testing.T, _ ShipmentRepository, customers CustomerRepository) {
+	ctx := context.Background()
+	anna := insertCustomer(t, customers, testCustomer(1, "anna"))
+	erik := insertCustomer(t, customers, testCustomer(1, "erik"))
+	other := insertCustomer(t, customers, testCustomer(2, "anna"))
+
+	_, err := customers.MergeCustomers(ctx, 1, anna.ID, []int{erik.ID, other.ID})
+	assert.True(t, errs.Is(err, errs.NotFound), "duplicate of other account: %v", err)
+
+	_, err = customers.MergeCustomers(ctx, 1, other.ID, []int{erik.ID})
+	assert.True(t, errs.Is(err, errs.NotFound), "survivor of other account: %v", err)
+
+	_, err = customers.GetCustomerByID(ctx, 1, erik.ID)
+	assert.NoError(t, err, "customer is not merged on failure")
+}
+
+func testChainedMerge(t *testing.T, _ ShipmentRepository, customers CustomerRepository) {
+	ctx := context.Background()
+	anna := insertCustomer(t, customers, testCustomer(1, "anna"))
+	annaB := insertCustomer(t, customers, testCustomer(1, "anna b"))
+	annaC := insertCustomer(t, customers, testCustomer(1, "anna c"))
+
+	// anna b is merged into anna, which is merged into anna c afterwards
+	if _, err := customers.MergeCustomers(ctx, 1, anna.ID, []int{annaB.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := customers.MergeCustomers(ctx, 1, annaC.ID, []int{anna.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	exported, err := customers.GetMergesBySurvivorID(ctx, annaC.ID)
+	if assert.NoError(t, err) && assert.Len(t, exported, 2) {
+		assert.Equal(t, annaB.ID, exported[0].MergedID)
+		assert.Contains(t, exported[0].MergedCustomer, "anna b")
+		assert.Equal(t, anna.ID, exported[1].MergedID)
+	}
+
+	orphaned, err := customers.GetMergesBySurvivorID(ctx, anna.ID)
+	if assert.NoError(t, err) {
+		assert.Empty(t, orphaned)
+	}
+
+	if assert.NoError(t, customers.EraseCustomer(ctx, annaC.Pseudonymized(time.Now()))) {
+		erased, err := customers.GetMergesBySurvivorID(ctx, annaC.ID)
+		if assert.NoError(t, err) && assert.Len(t, erased, 2) {
+			for _, merge := range erased {
+				assert.Equal(t, "{}", merge.MergedCustomer, "merged customer %d", merge.MergedID)
+			}
+		}
+	}
+}
+
+func testEraseCustomer(t *testing.T, _ ShipmentRepository, customers CustomerRepository) {
+	ctx := context.Background()
+	anna := insertCustomer(t, customers, testCustomer(1, "anna"))
+	annaDuplicate := insertCustomer(t, customers, testCustomer(1, "anna b"))
+	if _, err := customers.MergeCustomers(ctx, 1, anna.ID, []int{annaDuplicate.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	erasedAt := time.Now().UTC().Truncate(time.Second)
+	if assert.NoError(t, customers.EraseCustomer(ctx, anna.Pseudonymized(erasedAt))) {
+		found, err := customers.GetCustomerByID(ctx, 1, anna.ID)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "Erased", found.Name)
+			assert.Empty(t, found.Phone)
+			assert.Equal(t, "SE", found.CountryCode)
+			if assert.NotNil(t, found.ErasedAt) {
+				assert.True(t, erasedAt.Equal(*found.ErasedAt))
+			}
+		}
+
+		merges, err := customers.GetMergesBySurvivorID(ctx, anna.ID)
+		if assert.NoError(t, err) && assert.Len(t, merges, 1) {
+			assert.Equal(t, "{}", merges[0].MergedCustomer)
+		}
+	}
+
+	missing := anna.Pseudonymized(erasedAt)
+	missing.AccountID = 2
+	err := customers.EraseCustomer(ctx, missing)
+	assert.True(t, errs.Is(err, errs.NotFound), "customer of other account: %v", err)
+}
+
+func testRetention(t *testing.T, _ ShipmentRepository, customers CustomerRepository) {
+	ctx := context.Background()
+	anna := insertCustomer(t, customers, testCustomer(1, "anna"))
+	erik := insertCustomer(t, customers, testCustomer(2, "erik"))
+	if err := customers.EraseCustomer(ctx, erik.Pseudonymized(time.Now())); err != nil {
+		t.Fatal(err)
+	}
+
+	expired, err := customers.GetCustomersForRetention(ctx, time.Now().Add(time.Hour))
+	if assert.NoError(t, err) && assert.Len(t, expired, 1) {
+		assert.Equal(t, anna.ID, expired[0].ID)
+	}
+
+	expired, err = customers.GetCustomersForRetention(ctx, time.Now().Add(-time.Hour))
+	if assert.NoError(t, err) {
+		assert.Empty(t, expired)
+	}
+}
+
+func TestRepositoryContract_Memory(t *testing.T) {
+	testRepositoryContract(t, func(t *testing.T) (ShipmentRepository, CustomerRepository) {
+		shipments := NewMemoryShipmentsRepo()
+		return shipments, NewMemoryCustomersRepo(shipments)
+	})
+}
@@ -1,33 +1,48 @@
 package db
 
 import (
-	sq "github.com/Masterminds/squirrel"
+	"context"
+	"encoding/json"
 	"github.com/jinzhu/gorm"
-	"log"
+	"sendify_test/shipment/encryption"
+	"sendify_test/shipment/errs"
 	"sendify_test/shipment/models"
 	"time"
 )
 
+// mergedCustomerField is name of the field customer snapshots are encrypted
+// for in customer_merges table
+const mergedCustomerField = "customer_merges.merged_customer"
+
 type CustomersRepo struct {
-	db *gorm.DB
+	db     *gorm.DB
+	cipher encryption.Cipher
 }
 
-func NewCustomersRepo(db *gorm.DB) *CustomersRepo {
+func NewCustomersRepo(db *gorm.DB, cipher encryption.Cipher) *CustomersRepo {
 	return &CustomersRepo{
-		db: db,
+		db:     db,
+		cipher: cipher,
 	}
 }
 
-// GetCustomerByID retrieves customer object from customers table by ID
-func (r CustomersRepo) GetCustomerByID(id int) (models.Customer, error) {
+// GetCustomerByID retrieves customer object of the account from customers
+// table by ID
+func (r CustomersRepo) GetCustomerByID(ctx context.Context, accountID, id int) (models.Customer, error) {
+	ctx, db, end := observe(ctx, r.db, "customers", "GetCustomerByID")
+	defer end()
+
 	var customer models.Customer
-	err := r.db.
+	err := db.
 		Table("customers").
-		Where("customers.id = ?", id).
+		Where("customers.account_id = ? AND customers.id = ?", accountID, id).
 		Take(&customer).
 		Error
 	if err != nil {
-		log.Println("Failed to retrieve customer by ID, err: ", err.Error())
+		return models.Customer{}, notFound(err, "customer not found")
+	}
+
+	if err := customerPII(&customer).decrypt(r.cipher); err != nil {
 		return models.Customer{}, err
 	}
 
@@ -35,62 +50,493 @@ func (r CustomersRepo) GetCustomerByID(id int) (models.Customer, error) {
 }
 
 // CheckIfCustomerPresentAndReturn checks if customer object with same
-// name, email and address is present in customers table, if so returns it
-func (r CustomersRepo) CheckIfCustomerPresentAndReturn(customer *models.Customer) error {
-	err := r.db.
+// account, name, email and address (see models.Customer.MatchKey) is present
+// in customers table, if so returns it
+func (r CustomersRepo) CheckIfCustomerPresentAndReturn(ctx context.Context, customer *models.Customer) error {
+	ctx, db, end := observe(ctx, r.db, "customers", "CheckIfCustomerPresentAndReturn")
+	defer end()
+
+	err := db.
 		Table("customers").
-		Where("customers.name = ? AND customers.email = ? AND customers.address = ?",
-			customer.Name, customer.Email, customer.Address).
+		Where("customers.account_id = ?", customer.AccountID).
+		Where("customers.match_key = ?", r.cipher.BlindIndex(customer.MatchKey())).
+		Order("customers.id").
 		Take(&customer).
 		Error
 	if err != nil {
-		if err != gorm.ErrRecordNotFound {
-			log.Println("Failed to check if customer present, err: ", err.Error())
-		}
+		return notFound(err, "customer not found")
+	}
+
+	if err := customerPII(customer).decrypt(r.cipher); err != nil {
 		return err
 	}
 	return nil
 }
 
 // InsertAndReturnCustomer inserts new customer object into customers table
-// and returns it
-func (r CustomersRepo) InsertAndReturnCustomer(customer *models.Customer) error {
-	_, err := sq.
+// unless customer with the same match key is present, and returns the
+// present one. Customers are unique by account and match key, so customer
+// inserted concurrently by other transaction is returned as well
+func (r CustomersRepo) InsertAndReturnCustomer(ctx context.Context, customer *models.Customer) error {
+	ctx, db, end := observe(ctx, r.db, "customers", "InsertAndReturnCustomer")
+	defer end()
+
+	values, err := customerPII(customer).encrypt(r.cipher)
+	if err != nil {
+		return err
+	}
+	values["account_id"] = customer.AccountID
+	values["match_key"] = r.cipher.BlindIndex(customer.MatchKey())
+	values["country_code"] = customer.CountryCode
+	values["created_at"] = time.Now()
+
+	insert := statement(db).
 		Insert("customers").
-		Columns(
-			"name",
-			"email",
-			"address",
-			"country_code",
-			"created_at",
-		).
-		Values(
-			customer.Name,
-			customer.Email,
-			customer.Address,
-			customer.CountryCode,
-			time.Now(),
-		).
-		RunWith(r.db.DB()).Exec()
-	if err != nil {
-		log.Println("Failed to insert customer, err:", err.Error())
+		SetMap(values)
+	_, err = ignoreDuplicate(db, insert, "account_id, match_key").
+		RunWith(db.CommonDB()).Exec()
+	if err != nil {
+		return err
+	}
+
+	return r.CheckIfCustomerPresentAndReturn(ctx, customer)
+}
+
+// UpdateCustomerPhone sets phone number of customer with specified ID
+func (r CustomersRepo) UpdateCustomerPhone(ctx context.Context, id int, phone string) error {
+	ctx, db, end := observe(ctx, r.db, "customers", "UpdateCustomerPhone")
+	defer end()
+
+	encrypted, err := r.cipher.Encrypt("customers.phone", phone)
+	if err != nil {
+		return err
+	}
+
+	err = db.
+		Table("customers").
+		Where("customers.id = ?", id).
+		Update("phone", encrypted).
+		Error
+	if err != nil {
 		return err
 	}
 
-	return r.CheckIfCustomerPresentAndReturn(customer)
+	return nil
 }
 
-func (r CustomersRepo) GetCustomersByIDs(customerIDs []int) (models.Customers, error) {
+// GetCustomersByIDs retrieves customer objects of the account from
+// customers table by IDs
+func (r CustomersRepo) GetCustomersByIDs(ctx context.Context, accountID int, customerIDs []int) (models.Customers, error) {
+	ctx, db, end := observe(ctx, r.db, "customers", "GetCustomersByIDs")
+	defer end()
+
 	var customers models.Customers
-	err := r.db.
+	err := db.
 		Table("customers").
+		Where("customers.account_id = ?", accountID).
 		Where("customers.id IN(?)", customerIDs).
 		Find(&customers).
 		Error
 	if err != nil {
-		log.Println("Failed to retrieve customers by IDs, err: ", err.Error())
+		return nil, err
+	}
+
+	if err := decryptCustomers(r.cipher, customers); err != nil {
+		return nil, err
+	}
+
+	return customers, nil
+}
+
+// GetAllCustomers retrieves all customer objects of the account from
+// customers table
+func (r CustomersRepo) GetAllCustomers(ctx context.Context, accountID int) (models.Customers, error) {
+	ctx, db, end := observe(ctx, r.db, "customers", "GetAllCustomers")
+	defer end()
+
+	var customers models.Customers
+	err := db.
+		Table("customers").
+		Where("customers.account_id = ?", accountID).
+		Order("customers.id").
+		Find(&customers).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	if err := decryptCustomers(r.cipher, customers); err != nil {
+		return nil, err
+	}
+
+	return customers, nil
+}
+
+// MergeCustomers repoints shipments and merge records of duplicate customers
+// to the survivor, records merges into customer_merges table and deletes
+// duplicates, so customers merged into duplicates earlier are exported and
+// erased along with the survivor. It runs in a single transaction, or in the
+// current one if repo has it. Returns
+// errs.NotFound error if any of customers is missing in the account
+func (r CustomersRepo) MergeCustomers(ctx context.Context, accountID, survivorID int, duplicateIDs []int) ([]models.CustomerMerge, error) {
+	ctx, db, end := observe(ctx, r.db, "customers", "MergeCustomers")
+	defer end()
+
+	var merges []models.CustomerMerge
+	err := transaction(db, func(tx *gorm.DB) error {
+		var survivor models.Customer
+		err := forUpdate(tx).
+			Table("customers").
+			Where("customers.account_id = ? AND customers.id = ?", accountID, survivorID).
+			Take(&survivor).
+			Error
+		if err != nil {
+			return notFound(err, "customer not found")
+		}
+
+		var duplicates models.Customers
+		err = forUpdate(tx).
+			Table("customers").
+			Where("customers.account_id = ?", accountID).
+			Where("customers.id IN(?)", duplicateIDs).
+			Order("customers.id").
+			Find(&duplicates).
+			Error
+		if err != nil {
+			return err
+		}
+		if len(duplicates) != len(duplicateIDs) {
+			return errs.New(errs.NotFound, "customer not found")
+		}
+		if err := decryptCustomers(r.cipher, duplicates); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, duplicate := range duplicates {
+			moved, err := repointShipments(tx, duplicate.ID, survivorID)
+			if err != nil {
+				return err
+			}
+
+			snapshot, err := json.Marshal(duplicate)
+			if err != nil {
+				return err
+			}
+			encryptedSnapshot, err := r.cipher.Encrypt(mergedCustomerField, string(snapshot))
+			if err != nil {
+				return err
+			}
+
+			merge := models.CustomerMerge{
+				SurvivorID:     survivorID,
+				MergedID:       duplicate.ID,
+				MergedCustomer: string(snapshot),
+				ShipmentsMoved: moved,
+				CreatedAt:      now,
+			}
+			insert := statement(tx).
+				Insert("customer_merges").
+				Columns(
+					"survivor_id",
+					"merged_id",
+					"merged_customer",
+					"shipments_moved",
+					"created_at",
+				).
+				Values(
+					merge.SurvivorID,
+					merge.MergedID,
+					encryptedSnapshot,
+					merge.ShipmentsMoved,
+					merge.CreatedAt,
+				)
+			id, err := insertReturningID(tx, insert)
+			if err != nil {
+				return err
+			}
+			merge.ID = id
+			merges = append(merges, merge)
+		}
+
+		err = tx.
+			Table("customer_merges").
+			Where("customer_merges.survivor_id IN(?)", duplicateIDs).
+			Update("survivor_id", survivorID).
+			Error
+		if err != nil {
+			return err
+		}
+
+		return tx.
+			Table("customers").
+			Where("customers.id IN(?)", duplicateIDs).
+			Delete(models.Customer{}).
+			Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return merges, nil
+}
+
+// repointShipments moves shipments from one customer to another, returns
+// number of moved shipments
+func repointShipments(tx *gorm.DB, fromCustomerID, toCustomerID int) (int, error) {
+	var moved int64
+	for _, column := range []string{"customer_from", "customer_to"} {
+		result := tx.
+			Table("shipments").
+			Where("shipments."+column+" = ?", fromCustomerID).
+			Update(column, toCustomerID)
+		if result.Error != nil {
+			return 0, result.Error
+		}
+		moved += result.RowsAffected
+	}
+
+	return int(moved), nil
+}
+
+// GetMergesBySurvivorID retrieves records of customers merged into one with
+// specified ID
+func (r CustomersRepo) GetMergesBySurvivorID(ctx context.Context, survivorID int) ([]models.CustomerMerge, error) {
+	ctx, db, end := observe(ctx, r.db, "customers", "GetMergesBySurvivorID")
+	defer end()
+
+	var merges []models.CustomerMerge
+	err := db.
+		Table("customer_merges").
+		Where("customer_merges.survivor_id = ?", survivorID).
+		Order("customer_merges.id").
+		Find(&merges).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range merges {
+		merges[i].MergedCustomer, err = r.cipher.Decrypt(mergedCustomerField, merges[i].MergedCustomer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return merges, nil
+}
+
+// EraseCustomer replaces personal data of customer by pseudonymised one and
+// removes personal data of customers merged into it. It runs in a single
+// transaction, or in the current one if repo has it. Returns errs.NotFound
+// error if customer is missing in the account
+func (r CustomersRepo) EraseCustomer(ctx context.Context, erased models.Customer) error {
+	ctx, db, end := observe(ctx, r.db, "customers", "EraseCustomer")
+	defer end()
+
+	values, err := customerPII(&erased).encrypt(r.cipher)
+	if err != nil {
+		return err
+	}
+	values["match_key"] = r.cipher.BlindIndex(erased.MatchKey())
+	values["erased_at"] = erased.ErasedAt
+
+	err = transaction(db, func(tx *gorm.DB) error {
+		result := tx.
+			Table("customers").
+			Where("customers.account_id = ? AND customers.id = ?", erased.AccountID, erased.ID).
+			Updates(values)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errs.New(errs.NotFound, "customer not found")
+		}
+
+		return tx.
+			Table("customer_merges").
+			Where("customer_merges.survivor_id = ?", erased.ID).
+			Update("merged_customer", "{}").
+			Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetCustomersForRetention retrieves customers which are not erased yet,
+// created before specified time and have no shipments created since then
+func (r CustomersRepo) GetCustomersForRetention(ctx context.Context, before time.Time) (models.Customers, error) {
+	ctx, db, end := observe(ctx, r.db, "customers", "GetCustomersForRetention")
+	defer end()
+
+	var customers models.Customers
+	err := db.
+		Table("customers").
+		Where("customers.erased_at IS NULL AND customers.created_at < ?", before).
+		Where(`NOT EXISTS (
+			SELECT 1 FROM shipments
+			WHERE (shipments.customer_from = customers.id OR shipments.customer_to = customers.id)
+				AND shipments.created_at >= ?)`, before).
+		Order("customers.id").
+		Find(&customers).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	if err := decryptCustomers(r.cipher, customers); err != nil {
 		return nil, err
 	}
 
 	return customers, nil
 }
+
+// ReencryptCustomers re-encrypts personal data of all customers and their
+// merge records by active key and recomputes match keys, match keys pending
+// since they became blind indexes are recomputed then. Each customer is
+// updated in its own transaction, so the job may be run again after failure.
+// Customer getting match key of present one is reported along with it and
+// its match key is kept pending until they are merged. Returns number of
+// updated customers and pairs of colliding ones
+func (r CustomersRepo) ReencryptCustomers(ctx context.Context) (int, models.DuplicateCandidates, error) {
+	ctx, db, end := observe(ctx, r.db, "customers", "ReencryptCustomers")
+	defer end()
+
+	var rows []struct {
+		models.Customer
+		StoredMatchKey string `gorm:"column:match_key"`
+	}
+	err := db.
+		Table("customers").
+		Order("customers.id").
+		Find(&rows).
+		Error
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var pendingIDs []int
+	err = db.
+		Table("customer_match_key_backfill").
+		Pluck("customer_id", &pendingIDs).
+		Error
+	if err != nil {
+		return 0, nil, err
+	}
+	pending := make(map[int]bool, len(pendingIDs))
+	for _, id := range pendingIDs {
+		pending[id] = true
+	}
+
+	var (
+		updated    int
+		collisions models.DuplicateCandidates
+	)
+	for _, row := range rows {
+		customer := row.Customer
+		values, err := customerPII(&customer).reencrypt(r.cipher)
+		if err != nil {
+			return updated, collisions, err
+		}
+
+		if err := customerPII(&customer).decrypt(r.cipher); err != nil {
+			return updated, collisions, err
+		}
+		recomputed := true
+		if matchKey := r.cipher.BlindIndex(customer.MatchKey()); matchKey != row.StoredMatchKey {
+			// match keys are unique, customer which gets the key of present
+			// one is its duplicate, e.g. key was computed by other function
+			var present models.Customer
+			err := db.
+				Table("customers").
+				Where("customers.account_id = ? AND customers.match_key = ?", customer.AccountID, matchKey).
+				Take(&present).
+				Error
+			switch {
+			case gorm.IsRecordNotFoundError(err):
+				values["match_key"] = matchKey
+			case err != nil:
+				return updated, collisions, err
+			default:
+				if err := customerPII(&present).decrypt(r.cipher); err != nil {
+					return updated, collisions, err
+				}
+				collisions = append(collisions, models.DuplicateCandidate{
+					First:   present,
+					Second:  customer,
+					Score:   1,
+					Reasons: []string{"same match key"},
+				})
+				recomputed = false
+			}
+		}
+
+		dequeue := recomputed && pending[customer.ID]
+		if len(values) == 0 && !dequeue {
+			continue
+		}
+
+		err = transaction(db, func(tx *gorm.DB) error {
+			if len(values) > 0 {
+				err := tx.
+					Table("customers").
+					Where("customers.id = ?", customer.ID).
+					Updates(values).
+					Error
+				if err != nil {
+					return err
+				}
+			}
+			if dequeue {
+				return tx.Exec("DELETE FROM customer_match_key_backfill WHERE customer_id = ?", customer.ID).Error
+			}
+			return nil
+		})
+		if err != nil {
+			return updated, collisions, err
+		}
+		if len(values) > 0 {
+			updated++
+		}
+	}
+
+	// customers deleted since migration, e.g. merged ones, are not pending
+	err = db.Exec("DELETE FROM customer_match_key_backfill WHERE customer_id NOT IN (SELECT id FROM customers)").Error
+	if err != nil {
+		return updated, collisions, err
+	}
+
+	var merges []models.CustomerMerge
+	err = db.
+		Table("customer_merges").
+		Order("customer_merges.id").
+		Find(&merges).
+		Error
+	if err != nil {
+		return updated, collisions, err
+	}
+
+	for _, merge := range merges {
+		snapshot, changed, err := r.cipher.Reencrypt(mergedCustomerField, merge.MergedCustomer)
+		if err != nil {
+			return updated, collisions, err
+		}
+		if !changed {
+			continue
+		}
+
+		err = db.
+			Table("customer_merges").
+			Where("customer_merges.id = ?", merge.ID).
+			Update("merged_customer", snapshot).
+			Error
+		if err != nil {
+			return updated, collisions, err
+		}
+	}
+
+	return updated, collisions, nil
+}
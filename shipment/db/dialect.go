@@ -0,0 +1,97 @@
+package db
+
+import (
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jinzhu/gorm"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+)
+
+// Dialects of supported databases, named as gorm dialects are, their
+// drivers and gorm dialects must be imported by main package
+const (
+	DialectMySQL    = "mysql"
+	DialectPostgres = "postgres"
+	DialectSQLite   = "sqlite3"
+)
+
+// ParseDialect returns dialect of the driver, mysql, postgres or sqlite
+func ParseDialect(driver string) (string, error) {
+	switch driver {
+	case "mysql":
+		return DialectMySQL, nil
+	case "postgres":
+		return DialectPostgres, nil
+	case "sqlite", "sqlite3":
+		return DialectSQLite, nil
+	}
+	return "", fmt.Errorf("unknown DB driver %q, expected one of mysql, postgres, sqlite", driver)
+}
+
+// statement returns squirrel statement builder with placeholders of db
+// dialect, Postgres uses numbered ones
+func statement(db *gorm.DB) sq.StatementBuilderType {
+	return statementBuilder(db.Dialect().GetName())
+}
+
+func statementBuilder(dialect string) sq.StatementBuilderType {
+	if dialect == DialectPostgres {
+		return sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	}
+	return sq.StatementBuilder
+}
+
+// insertReturningID runs insert and returns ID of inserted row, Postgres
+// doesn't report last insert ID, so it's returned by the statement
+func insertReturningID(db *gorm.DB, insert sq.InsertBuilder) (int, error) {
+	if db.Dialect().GetName() == DialectPostgres {
+		var id int
+		err := insert.Suffix("RETURNING id").RunWith(db.CommonDB()).QueryRow().Scan(&id)
+		if conn, ok := db.CommonDB().(contextConn); ok {
+			err = conn.wrap(err)
+		} else if conn, ok := db.CommonDB().(contextDB); ok {
+			err = conn.wrap(err)
+		}
+		return id, err
+	}
+
+	result, err := insert.RunWith(db.CommonDB()).Exec()
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// ignoreDuplicate makes insert skip row violating unique index on columns
+// instead of failing, MySQL needs no columns as it checks all unique indexes
+func ignoreDuplicate(db *gorm.DB, insert sq.InsertBuilder, columns string) sq.InsertBuilder {
+	if db.Dialect().GetName() == DialectMySQL {
+		return insert.Suffix("ON DUPLICATE KEY UPDATE id = id")
+	}
+	return insert.Suffix("ON CONFLICT (" + columns + ") DO NOTHING")
+}
+
+// forUpdate locks selected rows until the end of transaction, SQLite locks
+// whole database on write and doesn't support row locks
+func forUpdate(db *gorm.DB) *gorm.DB {
+	if db.Dialect().GetName() == DialectSQLite {
+		return db
+	}
+	return db.Set("gorm:query_option", "FOR UPDATE")
+}
+
+// dbSystem returns OpenTelemetry attribute of database system of the dialect
+func dbSystem(dialect string) attribute.KeyValue {
+	switch dialect {
+	case DialectPostgres:
+		return semconv.DBSystemPostgreSQL
+	case DialectSQLite:
+		return semconv.DBSystemSqlite
+	}
+	return semconv.DBSystemMySQL
+}
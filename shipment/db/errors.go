@@ -0,0 +1,132 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"net"
+	"sendify_test/shipment/errs"
+	"strings"
+)
+
+// Messages of domain errors translated from errors of drivers
+const (
+	duplicateMessage   = "record already exists"
+	referencedMessage  = "record is referenced by other records"
+	missingRefMessage  = "referenced record does not exist"
+	constraintMessage  = "record violates constraint"
+	concurrentMessage  = "record is being modified concurrently, retry later"
+	unavailableMessage = "database is unavailable"
+)
+
+// MySQL error numbers translated into domain errors
+const (
+	mysqlLockWaitTimeout  = 1205
+	mysqlDeadlock         = 1213
+	mysqlDuplicateEntry   = 1062
+	mysqlRowIsReferenced  = 1451
+	mysqlNoReferencedRow  = 1452
+	mysqlCheckConstraint  = 3819
+	mysqlTooManyConnected = 1040
+)
+
+// Postgres error codes (SQLSTATE) translated into domain errors
+const (
+	postgresUniqueViolation     = "23505"
+	postgresForeignKeyViolation = "23503"
+	postgresCheckViolation      = "23514"
+	postgresSerializationFailed = "40001"
+	postgresDeadlockDetected    = "40P01"
+	postgresLockNotAvailable    = "55P03"
+	postgresTooManyConnections  = "53300"
+	postgresCannotConnectNow    = "57P03"
+	postgresConnectionException = "08"
+)
+
+// translators return domain error for errors of their driver, or nil for
+// other errors
+var translators = []func(err error) error{
+	translateMySQL,
+	translatePostgres,
+}
+
+// translate returns domain error for errors of the driver, messages of
+// domain errors don't contain SQL, so they may be shown to clients
+func translate(err error) error {
+	for _, translator := range translators {
+		if translated := translator(err); translated != nil {
+			return translated
+		}
+	}
+
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, sql.ErrConnDone) || errors.As(err, &netErr) {
+		return errs.Wrap(errs.Unavailable, unavailableMessage, err)
+	}
+	return err
+}
+
+func translateMySQL(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return nil
+	}
+
+	switch mysqlErr.Number {
+	case mysqlDuplicateEntry:
+		return errs.Wrap(errs.Conflict, duplicateMessage, err)
+	case mysqlRowIsReferenced:
+		return errs.Wrap(errs.Conflict, referencedMessage, err)
+	case mysqlNoReferencedRow:
+		return errs.Wrap(errs.Validation, missingRefMessage, err)
+	case mysqlCheckConstraint:
+		return errs.Wrap(errs.Validation, constraintMessage, err)
+	case mysqlLockWaitTimeout, mysqlDeadlock:
+		return errs.Wrap(errs.Conflict, concurrentMessage, err)
+	case mysqlTooManyConnected:
+		return errs.Wrap(errs.Unavailable, unavailableMessage, err)
+	}
+	return nil
+}
+
+func translatePostgres(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return nil
+	}
+
+	switch pqErr.Code {
+	case postgresUniqueViolation:
+		return errs.Wrap(errs.Conflict, duplicateMessage, err)
+	case postgresForeignKeyViolation:
+		// the same code is reported for deletion of referenced record and
+		// insertion of record referring to missing one
+		if strings.HasPrefix(pqErr.Message, "update or delete") {
+			return errs.Wrap(errs.Conflict, referencedMessage, err)
+		}
+		return errs.Wrap(errs.Validation, missingRefMessage, err)
+	case postgresCheckViolation:
+		return errs.Wrap(errs.Validation, constraintMessage, err)
+	case postgresSerializationFailed, postgresDeadlockDetected, postgresLockNotAvailable:
+		return errs.Wrap(errs.Conflict, concurrentMessage, err)
+	case postgresTooManyConnections, postgresCannotConnectNow:
+		return errs.Wrap(errs.Unavailable, unavailableMessage, err)
+	}
+	if pqErr.Code.Class() == postgresConnectionException {
+		return errs.Wrap(errs.Unavailable, unavailableMessage, err)
+	}
+	return nil
+}
+
+// notFound returns NotFound error with message if err is
+// gorm.ErrRecordNotFound
+func notFound(err error, message string) error {
+	if gorm.IsRecordNotFoundError(err) {
+		return errs.Wrap(errs.NotFound, message, err)
+	}
+	return err
+}
@@ -0,0 +1,39 @@
+//go:build cgo
+// +build cgo
+
+package db
+
+import (
+	"errors"
+	"github.com/mattn/go-sqlite3"
+	"sendify_test/shipment/errs"
+)
+
+// SQLite driver requires cgo, so its errors are translated only when it's
+// built
+func init() {
+	translators = append(translators, translateSQLite)
+}
+
+func translateSQLite(err error) error {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return nil
+	}
+
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return errs.Wrap(errs.Conflict, duplicateMessage, err)
+	case sqlite3.ErrConstraintForeignKey:
+		// the same code is reported for insertion of record referring to
+		// missing one, but service inserts references it has just resolved
+		return errs.Wrap(errs.Conflict, referencedMessage, err)
+	case sqlite3.ErrConstraintCheck:
+		return errs.Wrap(errs.Validation, constraintMessage, err)
+	}
+	switch sqliteErr.Code {
+	case sqlite3.ErrBusy, sqlite3.ErrLocked:
+		return errs.Wrap(errs.Conflict, concurrentMessage, err)
+	}
+	return nil
+}
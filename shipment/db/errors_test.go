@@ -0,0 +1,43 @@
+package db
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"sendify_test/shipment/errs"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want errs.Kind
+	}{
+		{name: "Duplicate entry", err: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, want: errs.Conflict},
+		{name: "Row is referenced", err: &mysql.MySQLError{Number: 1451}, want: errs.Conflict},
+		{name: "No referenced row", err: &mysql.MySQLError{Number: 1452}, want: errs.Validation},
+		{name: "Deadlock", err: &mysql.MySQLError{Number: 1213}, want: errs.Conflict},
+		{name: "Too many connections", err: &mysql.MySQLError{Number: 1040}, want: errs.Unavailable},
+		{name: "Bad connection", err: driver.ErrBadConn, want: errs.Unavailable},
+		{name: "Invalid connection", err: fmt.Errorf("query: %w", mysql.ErrInvalidConn), want: errs.Unavailable},
+		{name: "Syntax error", err: &mysql.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax"}, want: errs.Internal},
+		{name: "Other error", err: errors.New("unexpected"), want: errs.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := translate(tt.err)
+			assert.Equal(t, tt.want, errs.KindOf(err))
+			assert.ErrorIs(t, err, tt.err)
+			assert.NotContains(t, errs.Message(err), "SQL")
+		})
+	}
+
+	err := notFound(gorm.ErrRecordNotFound, "shipment not found")
+	assert.True(t, errs.Is(err, errs.NotFound))
+	assert.Equal(t, "shipment not found", errs.Message(err))
+}
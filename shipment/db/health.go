@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+)
+
+// SchemaVersion is version of the latest migration the code relies on,
+// service is not ready while DB schema is older
+const SchemaVersion = 5
+
+type HealthRepo struct {
+	db *gorm.DB
+}
+
+func NewHealthRepo(db *gorm.DB) *HealthRepo {
+	return &HealthRepo{
+		db: db,
+	}
+}
+
+// Ping checks connection to DB
+func (r HealthRepo) Ping(ctx context.Context) error {
+	if err := r.db.DB().PingContext(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetSchemaVersion retrieves version of the latest applied migration from
+// schema_migrations table
+func (r HealthRepo) GetSchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	err := r.db.DB().
+		QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").
+		Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// CountPendingMatchKeys retrieves number of customers whose match keys are
+// to be recomputed, see CustomersRepo.ReencryptCustomers. Customers deleted
+// since, e.g. merged ones, are not counted
+func (r HealthRepo) CountPendingMatchKeys(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.DB().
+		QueryRowContext(ctx, `SELECT COUNT(*) FROM customer_match_key_backfill
+			JOIN customers ON customers.id = customer_match_key_backfill.customer_id`).
+		Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
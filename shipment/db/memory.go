@@ -0,0 +1,635 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"sendify_test/shipment/errs"
+	"sendify_test/shipment/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryShipmentsRepo keeps shipments in memory, meant for tests of code
+// depending on ShipmentRepository. IDs are assigned sequentially starting
+// from 1, transactions are run by MemoryTransactor
+type MemoryShipmentsRepo struct {
+	mu        sync.RWMutex
+	shipments []models.Shipment
+	parcels   int
+}
+
+func NewMemoryShipmentsRepo() *MemoryShipmentsRepo {
+	return &MemoryShipmentsRepo{}
+}
+
+// GetShipmentByID retrieves shipment of the account by ID
+func (r *MemoryShipmentsRepo) GetShipmentByID(_ context.Context, accountID, id int) (models.Shipment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, shipment := range r.shipments {
+		if shipment.AccountID == accountID && shipment.ID == id {
+			return copyShipment(shipment), nil
+		}
+	}
+	return models.Shipment{}, errs.New(errs.NotFound, "shipment not found")
+}
+
+// GetAllShipments retrieves all shipments of the account
+func (r *MemoryShipmentsRepo) GetAllShipments(_ context.Context, accountID int) (models.Shipments, error) {
+	return r.filter(func(shipment models.Shipment) bool {
+		return shipment.AccountID == accountID
+	}), nil
+}
+
+// GetShipmentsByCustomerID retrieves shipments of the account sent or
+// received by customer
+func (r *MemoryShipmentsRepo) GetShipmentsByCustomerID(_ context.Context, accountID, customerID int) (models.Shipments, error) {
+	return r.filter(func(shipment models.Shipment) bool {
+		return shipment.AccountID == accountID && (shipment.FromID == customerID || shipment.ToID == customerID)
+	}), nil
+}
+
+// InsertShipment stores shipment with its parcels and sets IDs of them
+func (r *MemoryShipmentsRepo) InsertShipment(_ context.Context, shipment *models.Shipment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	shipment.ID = len(r.shipments) + 1
+	shipment.CreatedAt = time.Now()
+	for i := range shipment.Parcels {
+		r.parcels++
+		shipment.Parcels[i].ID = r.parcels
+		shipment.Parcels[i].ShipmentID = shipment.ID
+	}
+
+	stored := copyShipment(*shipment)
+	stored.From, stored.To = models.Customer{}, models.Customer{}
+	r.shipments = append(r.shipments, stored)
+	return nil
+}
+
+// filter returns shipments matching fn in order of their IDs
+func (r *MemoryShipmentsRepo) filter(fn func(shipment models.Shipment) bool) models.Shipments {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	shipments := models.Shipments{}
+	for _, shipment := range r.shipments {
+		if fn(shipment) {
+			shipments = append(shipments, copyShipment(shipment))
+		}
+	}
+	return shipments
+}
+
+// snapshot returns function restoring current state of repo
+func (r *MemoryShipmentsRepo) snapshot() func() {
+	r.mu.RLock()
+	shipments := append([]models.Shipment(nil), r.shipments...)
+	parcels := r.parcels
+	r.mu.RUnlock()
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.shipments, r.parcels = shipments, parcels
+	}
+}
+
+// repoint moves shipments from one customer to another, returns number of
+// moved references
+func (r *MemoryShipmentsRepo) repoint(fromCustomerID, toCustomerID int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var moved int
+	for i := range r.shipments {
+		if r.shipments[i].FromID == fromCustomerID {
+			r.shipments[i].FromID = toCustomerID
+			moved++
+		}
+		if r.shipments[i].ToID == fromCustomerID {
+			r.shipments[i].ToID = toCustomerID
+			moved++
+		}
+	}
+	return moved
+}
+
+// MemoryCustomersRepo keeps customers and their merges in memory, meant for
+// tests of code depending on CustomerRepository. Personal data is kept in
+// plaintext, shipments of merged customers are repointed in shipments repo
+type MemoryCustomersRepo struct {
+	mu        sync.RWMutex
+	customers map[int]models.Customer
+	merges    []models.CustomerMerge
+	lastID    int
+	shipments *MemoryShipmentsRepo
+}
+
+func NewMemoryCustomersRepo(shipments *MemoryShipmentsRepo) *MemoryCustomersRepo {
+	return &MemoryCustomersRepo{
+		customers: map[int]models.Customer{},
+		shipments: shipments,
+	}
+}
+
+// GetCustomerByID retrieves customer of the account by ID
+func (r *MemoryCustomersRepo) GetCustomerByID(_ context.Context, accountID, id int) (models.Customer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	customer, ok := r.customers[id]
+	if !ok || customer.AccountID != accountID {
+		return models.Customer{}, errs.New(errs.NotFound, "customer not found")
+	}
+	return copyCustomer(customer), nil
+}
+
+// CheckIfCustomerPresentAndReturn checks if customer with same account and
+// match key is present, if so returns it
+func (r *MemoryCustomersRepo) CheckIfCustomerPresentAndReturn(_ context.Context, customer *models.Customer) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	present, ok := r.present(*customer)
+	if !ok {
+		return errs.New(errs.NotFound, "customer not found")
+	}
+	*customer = copyCustomer(present)
+	return nil
+}
+
+// InsertAndReturnCustomer stores customer unless customer with the same
+// match key is present, and returns the present one
+func (r *MemoryCustomersRepo) InsertAndReturnCustomer(_ context.Context, customer *models.Customer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if present, ok := r.present(*customer); ok {
+		*customer = copyCustomer(present)
+		return nil
+	}
+
+	r.lastID++
+	customer.ID = r.lastID
+	customer.CreatedAt = time.Now()
+	customer.ErasedAt = nil
+	customer.AddressID = 0
+	r.customers[customer.ID] = copyCustomer(*customer)
+	return nil
+}
+
+// present returns the earliest customer with the same account and match key
+func (r *MemoryCustomersRepo) present(customer models.Customer) (models.Customer, bool) {
+	var (
+		earliest models.Customer
+		found    bool
+	)
+	matchKey := customer.MatchKey()
+	for _, candidate := range r.customers {
+		if candidate.AccountID != customer.AccountID || candidate.MatchKey() != matchKey {
+			continue
+		}
+		if !found || candidate.ID < earliest.ID {
+			earliest, found = candidate, true
+		}
+	}
+	return earliest, found
+}
+
+// UpdateCustomerPhone sets phone number of customer with specified ID
+func (r *MemoryCustomersRepo) UpdateCustomerPhone(_ context.Context, id int, phone string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if customer, ok := r.customers[id]; ok {
+		customer.Phone = phone
+		r.customers[id] = customer
+	}
+	return nil
+}
+
+// GetCustomersByIDs retrieves customers of the account by IDs
+func (r *MemoryCustomersRepo) GetCustomersByIDs(_ context.Context, accountID int, customerIDs []int) (models.Customers, error) {
+	ids := map[int]bool{}
+	for _, id := range customerIDs {
+		ids[id] = true
+	}
+
+	return r.filter(func(customer models.Customer) bool {
+		return customer.AccountID == accountID && ids[customer.ID]
+	}), nil
+}
+
+// GetAllCustomers retrieves all customers of the account
+func (r *MemoryCustomersRepo) GetAllCustomers(_ context.Context, accountID int) (models.Customers, error) {
+	return r.filter(func(customer models.Customer) bool {
+		return customer.AccountID == accountID
+	}), nil
+}
+
+// MergeCustomers repoints shipments and merges of duplicate customers to the
+// survivor, records merges and deletes duplicates. Returns errs.NotFound error if any
+// of customers is missing in the account
+func (r *MemoryCustomersRepo) MergeCustomers(_ context.Context, accountID, survivorID int, duplicateIDs []int) ([]models.CustomerMerge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if survivor, ok := r.customers[survivorID]; !ok || survivor.AccountID != accountID {
+		return nil, errs.New(errs.NotFound, "customer not found")
+	}
+
+	duplicates := make(models.Customers, 0, len(duplicateIDs))
+	for _, id := range duplicateIDs {
+		duplicate, ok := r.customers[id]
+		if !ok || duplicate.AccountID != accountID {
+			return nil, errs.New(errs.NotFound, "customer not found")
+		}
+		duplicates = append(duplicates, duplicate)
+	}
+	sort.Slice(duplicates, func(i, j int) bool { return duplicates[i].ID < duplicates[j].ID })
+
+	now := time.Now()
+	var merges []models.CustomerMerge
+	for _, duplicate := range duplicates {
+		snapshot, err := json.Marshal(duplicate)
+		if err != nil {
+			return nil, err
+		}
+
+		merge := models.CustomerMerge{
+			ID:             len(r.merges) + 1,
+			SurvivorID:     survivorID,
+			MergedID:       duplicate.ID,
+			MergedCustomer: string(snapshot),
+			ShipmentsMoved: r.shipments.repoint(duplicate.ID, survivorID),
+			CreatedAt:      now,
+		}
+		r.merges = append(r.merges, merge)
+		merges = append(merges, merge)
+	}
+
+	for _, duplicate := range duplicates {
+		for i := range r.merges {
+			if r.merges[i].SurvivorID == duplicate.ID {
+				r.merges[i].SurvivorID = survivorID
+			}
+		}
+		delete(r.customers, duplicate.ID)
+	}
+	return merges, nil
+}
+
+// GetMergesBySurvivorID retrieves records of customers merged into one with
+// specified ID
+func (r *MemoryCustomersRepo) GetMergesBySurvivorID(_ context.Context, survivorID int) ([]models.CustomerMerge, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	merges := []models.CustomerMerge{}
+	for _, merge := range r.merges {
+		if merge.SurvivorID == survivorID {
+			merges = append(merges, merge)
+		}
+	}
+	return merges, nil
+}
+
+// EraseCustomer replaces personal data of customer by pseudonymised one and
+// removes personal data of customers merged into it. Returns errs.NotFound
+// error if customer is missing
+func (r *MemoryCustomersRepo) EraseCustomer(_ context.Context, erased models.Customer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	customer, ok := r.customers[erased.ID]
+	if !ok || customer.AccountID != erased.AccountID {
+		return errs.New(errs.NotFound, "customer not found")
+	}
+
+	erased = copyCustomer(erased)
+	customer.Name = erased.Name
+	customer.Email = erased.Email
+	customer.Phone = erased.Phone
+	customer.Address = erased.Address
+	customer.ErasedAt = erased.ErasedAt
+	r.customers[customer.ID] = customer
+
+	for i := range r.merges {
+		if r.merges[i].SurvivorID == erased.ID {
+			r.merges[i].MergedCustomer = "{}"
+		}
+	}
+	return nil
+}
+
+// GetCustomersForRetention retrieves customers which are not erased yet,
+// created before specified time and have no shipments created since then
+func (r *MemoryCustomersRepo) GetCustomersForRetention(_ context.Context, before time.Time) (models.Customers, error) {
+	active := map[int]bool{}
+	for _, shipment := range r.shipments.filter(func(shipment models.Shipment) bool {
+		return !shipment.CreatedAt.Before(before)
+	}) {
+		active[shipment.FromID] = true
+		active[shipment.ToID] = true
+	}
+
+	return r.filter(func(customer models.Customer) bool {
+		return customer.ErasedAt == nil && customer.CreatedAt.Before(before) && !active[customer.ID]
+	}), nil
+}
+
+// ReencryptCustomers does nothing as personal data is kept in plaintext
+func (r *MemoryCustomersRepo) ReencryptCustomers(context.Context) (int, models.DuplicateCandidates, error) {
+	return 0, nil, nil
+}
+
+// snapshot returns function restoring current state of repo
+func (r *MemoryCustomersRepo) snapshot() func() {
+	r.mu.RLock()
+	customers := make(map[int]models.Customer, len(r.customers))
+	for id, customer := range r.customers {
+		customers[id] = copyCustomer(customer)
+	}
+	merges := append([]models.CustomerMerge(nil), r.merges...)
+	lastID := r.lastID
+	r.mu.RUnlock()
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.customers, r.merges, r.lastID = customers, merges, lastID
+	}
+}
+
+// filter returns customers matching fn in order of their IDs
+func (r *MemoryCustomersRepo) filter(fn func(customer models.Customer) bool) models.Customers {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	customers := models.Customers{}
+	for _, customer := range r.customers {
+		if fn(customer) {
+			customers = append(customers, copyCustomer(customer))
+		}
+	}
+	sort.Slice(customers, func(i, j int) bool { return customers[i].ID < customers[j].ID })
+	return customers
+}
+
+// MemoryAddressBookRepo keeps saved addresses in memory, meant for tests of
+// code depending on AddressBookRepository. Personal data is kept in plaintext
+type MemoryAddressBookRepo struct {
+	mu        sync.RWMutex
+	addresses map[int]models.SavedAddress
+	lastID    int
+}
+
+func NewMemoryAddressBookRepo() *MemoryAddressBookRepo {
+	return &MemoryAddressBookRepo{
+		addresses: map[int]models.SavedAddress{},
+	}
+}
+
+// GetAddressByID retrieves saved address by ID
+func (r *MemoryAddressBookRepo) GetAddressByID(_ context.Context, id int) (models.SavedAddress, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	address, ok := r.addresses[id]
+	if !ok {
+		return models.SavedAddress{}, errs.New(errs.NotFound, "saved address not found")
+	}
+	return copySavedAddress(address), nil
+}
+
+// GetAddressesByAccountID retrieves all saved addresses owned by account in
+// order of their labels
+func (r *MemoryAddressBookRepo) GetAddressesByAccountID(_ context.Context, accountID int) (models.SavedAddresses, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	addresses := models.SavedAddresses{}
+	for _, address := range r.addresses {
+		if address.AccountID == accountID {
+			addresses = append(addresses, copySavedAddress(address))
+		}
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		if addresses[i].Label != addresses[j].Label {
+			return addresses[i].Label < addresses[j].Label
+		}
+		return addresses[i].ID < addresses[j].ID
+	})
+	return addresses, nil
+}
+
+// InsertAddress stores saved address and sets its ID
+func (r *MemoryAddressBookRepo) InsertAddress(_ context.Context, address *models.SavedAddress) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	address.ID = r.lastID
+	address.CreatedAt = time.Now()
+	address.UpdatedAt = address.CreatedAt
+	r.addresses[address.ID] = copySavedAddress(*address)
+	return nil
+}
+
+// UpdateAddress updates saved address owned by address.AccountID,
+// returns errs.NotFound error if there is no such address
+func (r *MemoryAddressBookRepo) UpdateAddress(_ context.Context, address models.SavedAddress) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.addresses[address.ID]
+	if !ok || stored.AccountID != address.AccountID {
+		return errs.New(errs.NotFound, "saved address not found")
+	}
+
+	address.CreatedAt = stored.CreatedAt
+	address.UpdatedAt = time.Now()
+	r.addresses[address.ID] = copySavedAddress(address)
+	return nil
+}
+
+// DeleteAddress deletes saved address owned by account,
+// returns errs.NotFound error if there is no such address
+func (r *MemoryAddressBookRepo) DeleteAddress(_ context.Context, accountID, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.addresses[id]
+	if !ok || stored.AccountID != accountID {
+		return errs.New(errs.NotFound, "saved address not found")
+	}
+
+	delete(r.addresses, id)
+	return nil
+}
+
+// ReencryptAddresses does nothing as personal data is kept in plaintext
+func (r *MemoryAddressBookRepo) ReencryptAddresses(context.Context) (int, error) {
+	return 0, nil
+}
+
+// snapshot returns function restoring current state of repo
+func (r *MemoryAddressBookRepo) snapshot() func() {
+	r.mu.RLock()
+	addresses := make(map[int]models.SavedAddress, len(r.addresses))
+	for id, address := range r.addresses {
+		addresses[id] = copySavedAddress(address)
+	}
+	lastID := r.lastID
+	r.mu.RUnlock()
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.addresses, r.lastID = addresses, lastID
+	}
+}
+
+// MemoryAuditRepo keeps audit log entries in memory, meant for tests of code
+// depending on AuditRepository. IDs are assigned sequentially starting from 1
+type MemoryAuditRepo struct {
+	mu      sync.RWMutex
+	entries models.AuditEntries
+}
+
+func NewMemoryAuditRepo() *MemoryAuditRepo {
+	return &MemoryAuditRepo{}
+}
+
+// InsertEntry appends entry to audit log
+func (r *MemoryAuditRepo) InsertEntry(_ context.Context, entry models.AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry.ID = len(r.entries) + 1
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+// GetEntries retrieves the latest audit log entries of the account about
+// the entity, optionally of the entity with specified ID only
+func (r *MemoryAuditRepo) GetEntries(_ context.Context, accountID int, entity string, entityID int, limit int) (models.AuditEntries, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var entries models.AuditEntries
+	for i := len(r.entries) - 1; i >= 0 && len(entries) < limit; i-- {
+		entry := r.entries[i]
+		if entry.AccountID == accountID && entry.Entity == entity && (entityID == 0 || entry.EntityID == entityID) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// snapshot returns function restoring current state of repo
+func (r *MemoryAuditRepo) snapshot() func() {
+	r.mu.RLock()
+	entries := append(models.AuditEntries(nil), r.entries...)
+	r.mu.RUnlock()
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.entries = entries
+	}
+}
+
+// MemoryTransactor runs functions in transaction of memory repos, meant for
+// tests of code depending on UnitOfWork. Transactions are run one at a time
+// and changes of repos made since transaction began are discarded if it
+// fails, including ones made outside of it
+type MemoryTransactor struct {
+	mu          sync.Mutex
+	shipments   *MemoryShipmentsRepo
+	customers   *MemoryCustomersRepo
+	addressBook *MemoryAddressBookRepo
+	audit       *MemoryAuditRepo
+}
+
+func NewMemoryTransactor(
+	shipments *MemoryShipmentsRepo,
+	customers *MemoryCustomersRepo,
+	addressBook *MemoryAddressBookRepo,
+	audit *MemoryAuditRepo,
+) *MemoryTransactor {
+	return &MemoryTransactor{
+		shipments:   shipments,
+		customers:   customers,
+		addressBook: addressBook,
+		audit:       audit,
+	}
+}
+
+// Transaction runs fn with the repos, their changes are discarded if fn
+// returns error or panics
+func (t *MemoryTransactor) Transaction(_ context.Context, fn func(tx Repositories) error) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	restores := []func(){
+		t.shipments.snapshot(),
+		t.customers.snapshot(),
+		t.addressBook.snapshot(),
+		t.audit.snapshot(),
+	}
+
+	panicked := true
+	defer func() {
+		if panicked || err != nil {
+			for _, restore := range restores {
+				restore()
+			}
+		}
+	}()
+
+	err = fn(Repositories{
+		Shipments:   t.shipments,
+		Customers:   t.customers,
+		AddressBook: t.addressBook,
+		Audit:       t.audit,
+	})
+
+	panicked = false
+	return err
+}
+
+// copyShipment returns copy of shipment not sharing parcels with it, so
+// stored shipments aren't modified by callers
+func copyShipment(shipment models.Shipment) models.Shipment {
+	if shipment.Parcels != nil {
+		shipment.Parcels = append(models.Parcels{}, shipment.Parcels...)
+	}
+	return shipment
+}
+
+// copyCustomer returns copy of customer not sharing street lines and time
+// of erasure with it, so stored customers aren't modified by callers
+func copyCustomer(customer models.Customer) models.Customer {
+	if customer.Address.StreetLines != nil {
+		customer.Address.StreetLines = append(models.StreetLines{}, customer.Address.StreetLines...)
+	}
+	if customer.ErasedAt != nil {
+		erasedAt := *customer.ErasedAt
+		customer.ErasedAt = &erasedAt
+	}
+	return customer
+}
+
+// copySavedAddress returns copy of saved address not sharing street lines
+// with it, so stored addresses aren't modified by callers
+func copySavedAddress(address models.SavedAddress) models.SavedAddress {
+	if address.Address.StreetLines != nil {
+		address.Address.StreetLines = append(models.StreetLines{}, address.Address.StreetLines...)
+	}
+	return address
+}
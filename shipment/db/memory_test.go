@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sendify_test/shipment/models"
+	"testing"
+)
+
+func TestMemoryTransactor_Transaction(t *testing.T) {
+	ctx := context.Background()
+	shipments := NewMemoryShipmentsRepo()
+	customers := NewMemoryCustomersRepo(shipments)
+	addressBook := NewMemoryAddressBookRepo()
+	audit := NewMemoryAuditRepo()
+	transactor := NewMemoryTransactor(shipments, customers, addressBook, audit)
+
+	anna := insertCustomer(t, customers, testCustomer(1, "anna"))
+	change := func(tx Repositories) error {
+		if err := tx.Customers.UpdateCustomerPhone(ctx, anna.ID, "+46709876543"); err != nil {
+			return err
+		}
+		erik := testCustomer(1, "erik")
+		if err := tx.Customers.InsertAndReturnCustomer(ctx, &erik); err != nil {
+			return err
+		}
+		if err := tx.AddressBook.InsertAddress(ctx, &models.SavedAddress{AccountID: 1, Label: "office"}); err != nil {
+			return err
+		}
+		return tx.Audit.InsertEntry(ctx, models.AuditEntry{AccountID: 1, Entity: models.EntityCustomer, EntityID: erik.ID})
+	}
+	assertUnchanged := func() {
+		all, err := customers.GetAllCustomers(ctx, 1)
+		if assert.NoError(t, err) && assert.Len(t, all, 1) {
+			assert.Equal(t, "+46701234567", all[0].Phone)
+		}
+		addresses, err := addressBook.GetAddressesByAccountID(ctx, 1)
+		if assert.NoError(t, err) {
+			assert.Empty(t, addresses)
+		}
+		entries, err := audit.GetEntries(ctx, 1, models.EntityCustomer, 0, 10)
+		if assert.NoError(t, err) {
+			assert.Empty(t, entries)
+		}
+	}
+
+	// changes are discarded if function fails or panics
+	failure := errors.New("failure")
+	err := transactor.Transaction(ctx, func(tx Repositories) error {
+		if err := change(tx); err != nil {
+			return err
+		}
+		return failure
+	})
+	assert.Equal(t, failure, err)
+	assertUnchanged()
+
+	assert.Panics(t, func() {
+		_ = transactor.Transaction(ctx, func(tx Repositories) error {
+			if err := change(tx); err != nil {
+				return err
+			}
+			panic(failure)
+		})
+	})
+	assertUnchanged()
+
+	// IDs of discarded records are assigned again
+	if assert.NoError(t, transactor.Transaction(ctx, change)) {
+		all, err := customers.GetAllCustomers(ctx, 1)
+		if assert.NoError(t, err) && assert.Len(t, all, 2) {
+			assert.Equal(t, "+46709876543", all[0].Phone)
+			assert.Equal(t, anna.ID+1, all[1].ID)
+		}
+		addresses, err := addressBook.GetAddressesByAccountID(ctx, 1)
+		if assert.NoError(t, err) && assert.Len(t, addresses, 1) {
+			assert.Equal(t, 1, addresses[0].ID)
+		}
+		entries, err := audit.GetEntries(ctx, 1, models.EntityCustomer, 0, 10)
+		if assert.NoError(t, err) {
+			assert.Len(t, entries, 1)
+		}
+	}
+}
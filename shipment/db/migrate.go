@@ -0,0 +1,403 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*/*.sql
+var migrationFiles embed.FS
+
+// migrationsLock is name of MySQL lock held while migrations are applied,
+// so replicas migrating on startup don't apply them concurrently
+const migrationsLock = "shipment_schema_migrations"
+
+// migrationsLockKey is key of Postgres advisory lock, Postgres locks are
+// identified by numbers
+const migrationsLockKey = 7286457391
+
+// migrationsLockTimeout is time in seconds to wait for migrations of other
+// replica
+const migrationsLockTimeout = 60
+
+// migrationName is "<version>_<name>.<up|down|baseline>.sql", e.g.
+// "0002_shipment_customer_keys.up.sql"
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down|baseline)\.sql$`)
+
+// baselineTable is table of the schema the service was released with, its
+// presence in database without recorded migrations means that database was
+// created by hand before migrations were introduced
+const baselineTable = "customers"
+
+// statementEnd separates statements of migration, statements are executed
+// one by one as multi-statement queries are disabled by driver
+var statementEnd = regexp.MustCompile(`;[ \t]*(\r?\n|$)`)
+
+// Migration is numbered schema change, Down reverts Up. Baseline of the
+// first migration converts database created before migrations were
+// introduced to the schema Up creates, it's optional
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Baseline string
+}
+
+// MigrationStatus is migration with time it was applied at, nil for pending
+// migration
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// LoadMigrations reads migrations from fsys, each version must have both up
+// and down migration and versions must be sequential starting from 1
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, file := range files {
+		match := migrationName.FindStringSubmatch(path.Base(file))
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration name %s, expected <version>_<name>.<up|down|baseline>.sql", file)
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has different names %s and %s", version, migration.Name, match[2])
+		}
+
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		switch match[3] {
+		case "up":
+			migration.Up = string(data)
+		case "down":
+			migration.Down = string(data)
+		default:
+			migration.Baseline = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return nil, fmt.Errorf("migration %d is missing", i+1)
+		}
+		if strings.TrimSpace(migration.Up) == "" || strings.TrimSpace(migration.Down) == "" {
+			return nil, fmt.Errorf("migration %d must have both up and down migration", migration.Version)
+		}
+		if migration.Baseline != "" && migration.Version != 1 {
+			return nil, fmt.Errorf("migration %d can't have baseline, only the first one can", migration.Version)
+		}
+	}
+	return migrations, nil
+}
+
+// splitStatements splits migration into statements ending with semicolon at
+// the end of line, comments are kept with statements they precede
+func splitStatements(migration string) []string {
+	var statements []string
+	for _, statement := range statementEnd.Split(migration, -1) {
+		if !isBlank(statement) {
+			statements = append(statements, strings.TrimSpace(statement))
+		}
+	}
+	return statements
+}
+
+// isBlank reports whether statement contains comments only
+func isBlank(statement string) bool {
+	for _, line := range strings.Split(statement, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
+
+// Migrator applies embedded migrations and records their versions in
+// schema_migrations table
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+}
+
+// NewMigrator returns migrator applying migrations of the dialect, see
+// ParseDialect
+func NewMigrator(db *sql.DB, dialect string) (*Migrator, error) {
+	fsys, err := fs.Sub(migrationFiles, path.Join("migrations", dialect))
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(migrations) == 0 {
+		return nil, fmt.Errorf("no migrations of %s dialect", dialect)
+	}
+
+	return &Migrator{
+		db:         db,
+		dialect:    dialect,
+		migrations: migrations,
+	}, nil
+}
+
+// LatestVersion returns version of the latest embedded migration
+func (m Migrator) LatestVersion() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies pending migrations in order, returns applied ones. Database
+// created before migrations were introduced is converted by baseline of the
+// first migration instead of applying it
+func (m Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		var baseline bool
+		if len(versions) == 0 {
+			baseline, err = hasTable(ctx, conn, m.dialect, baselineTable)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+
+			up := migration.Up
+			if baseline && migration.Version == 1 {
+				if migration.Baseline == "" {
+					return fmt.Errorf("database has %s table, but no migrations recorded, and there is no baseline "+
+						"of %s dialect to convert it", baselineTable, m.dialect)
+				}
+				up = migration.Baseline
+			}
+
+			err := apply(ctx, conn, up, func(tx *sql.Tx) error {
+				_, err := recordVersion(m.dialect, migration.Version).RunWith(tx).ExecContext(ctx)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the latest applied migration, returns false if there is none
+func (m Migrator) Down(ctx context.Context) (Migration, bool, error) {
+	var (
+		reverted Migration
+		ok       bool
+	)
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, applied := versions[migration.Version]; !applied {
+				continue
+			}
+
+			err := apply(ctx, conn, migration.Down, func(tx *sql.Tx) error {
+				_, err := forgetVersion(m.dialect, migration.Version).RunWith(tx).ExecContext(ctx)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to revert migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			reverted, ok = migration, true
+			return nil
+		}
+		return nil
+	})
+	return reverted, ok, err
+}
+
+// Status lists embedded migrations with times they were applied at
+func (m Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	versions, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Migration: migration}
+		if appliedAt, ok := versions[migration.Version]; ok {
+			appliedAt := appliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// locked runs fn on single connection holding migrations lock, SQLite
+// allows single writer only, so it needs no lock
+func (m Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	switch m.dialect {
+	case DialectMySQL:
+		var acquired sql.NullInt64
+		err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", migrationsLock, migrationsLockTimeout).Scan(&acquired)
+		if err != nil {
+			return err
+		}
+		if acquired.Int64 != 1 {
+			return fmt.Errorf("failed to acquire %s lock in %ds", migrationsLock, migrationsLockTimeout)
+		}
+		defer conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", migrationsLock)
+	case DialectPostgres:
+		lockCtx, cancel := context.WithTimeout(ctx, migrationsLockTimeout*time.Second)
+		_, err = conn.ExecContext(lockCtx, "SELECT pg_advisory_lock($1)", migrationsLockKey)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to acquire %s lock in %ds: %w", migrationsLock, migrationsLockTimeout, err)
+		}
+		defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationsLockKey)
+	}
+
+	return fn(conn)
+}
+
+// appliedVersions returns times of applied migrations by their versions,
+// schema_migrations table is created if missing
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INT NOT NULL,
+    applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (version))`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[int]time.Time{}
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		versions[version] = appliedAt
+	}
+	return versions, rows.Err()
+}
+
+// hasTable reports whether table exists in the current database or schema
+func hasTable(ctx context.Context, conn *sql.Conn, dialect, table string) (bool, error) {
+	query := "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?"
+	switch dialect {
+	case DialectPostgres:
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1"
+	case DialectSQLite:
+		query = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+	}
+
+	var count int
+	if err := conn.QueryRowContext(ctx, query, table).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// recordVersion returns statement recording applied migration in
+// schema_migrations, with placeholders of the dialect
+func recordVersion(dialect string, version int) sq.InsertBuilder {
+	return statementBuilder(dialect).Insert("schema_migrations").Columns("version").Values(version)
+}
+
+// forgetVersion returns statement removing reverted migration from
+// schema_migrations, with placeholders of the dialect
+func forgetVersion(dialect string, version int) sq.DeleteBuilder {
+	return statementBuilder(dialect).Delete("schema_migrations").Where(sq.Eq{"version": version})
+}
+
+// apply executes statements of migration and records it by record in a
+// single transaction. MySQL commits DDL statements implicitly, so failed
+// migration may be applied partially and has to be fixed by hand, Postgres
+// and SQLite roll back DDL statements as well
+func apply(ctx context.Context, conn *sql.Conn, migration string, record func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range splitStatements(migration) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	if err := record(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	file := func(data string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(data)} }
+
+	migrations, err := LoadMigrations(fstest.MapFS{
+		"0002_second.up.sql":      file("ALTER TABLE a ADD COLUMN b INT;"),
+		"0002_second.down.sql":    file("ALTER TABLE a DROP COLUMN b;"),
+		"0001_first.up.sql":       file("CREATE TABLE a (id INT);"),
+		"0001_first.down.sql":     file("DROP TABLE a;"),
+		"0001_first.baseline.sql": file("ALTER TABLE a ADD COLUMN id INT;"),
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []Migration{
+			{Version: 1, Name: "first", Up: "CREATE TABLE a (id INT);", Down: "DROP TABLE a;", Baseline: "ALTER TABLE a ADD COLUMN id INT;"},
+			{Version: 2, Name: "second", Up: "ALTER TABLE a ADD COLUMN b INT;", Down: "ALTER TABLE a DROP COLUMN b;"},
+		}, migrations)
+	}
+
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{
+			name:  "Invalid name",
+			files: fstest.MapFS{"first.up.sql": file("CREATE TABLE a (id INT);")},
+		},
+		{
+			name: "Missing down migration",
+			files: fstest.MapFS{
+				"0001_first.up.sql": file("CREATE TABLE a (id INT);"),
+			},
+		},
+		{
+			name: "Missing version",
+			files: fstest.MapFS{
+				"0002_second.up.sql":   file("ALTER TABLE a ADD COLUMN b INT;"),
+				"0002_second.down.sql": file("ALTER TABLE a DROP COLUMN b;"),
+			},
+		},
+		{
+			name: "Baseline of later migration",
+			files: fstest.MapFS{
+				"0001_first.up.sql":        file("CREATE TABLE a (id INT);"),
+				"0001_first.down.sql":      file("DROP TABLE a;"),
+				"0002_second.up.sql":       file("ALTER TABLE a ADD COLUMN b INT;"),
+				"0002_second.down.sql":     file("ALTER TABLE a DROP COLUMN b;"),
+				"0002_second.baseline.sql": file("ALTER TABLE a ADD COLUMN b INT;"),
+			},
+		},
+		{
+			name: "Different names",
+			files: fstest.MapFS{
+				"0001_first.up.sql":   file("CREATE TABLE a (id INT);"),
+				"0001_other.down.sql": file("DROP TABLE a;"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadMigrations(tt.files)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	statements := splitStatements(`-- accounts
+CREATE TABLE a (
+    id INT);
+
+CREATE TRIGGER a_no_update BEFORE UPDATE ON a
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'a; is append-only';
+-- trailing comment
+`)
+	assert.Equal(t, []string{
+		"-- accounts\nCREATE TABLE a (\n    id INT)",
+		"CREATE TRIGGER a_no_update BEFORE UPDATE ON a\n    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'a; is append-only'",
+	}, statements)
+}
+
+func TestNewMigrator(t *testing.T) {
+	var latest []int
+	for _, dialect := range []string{DialectMySQL, DialectPostgres, DialectSQLite} {
+		migrator, err := NewMigrator(nil, dialect)
+		if assert.NoError(t, err, dialect) {
+			assert.GreaterOrEqual(t, migrator.LatestVersion(), SchemaVersion, dialect)
+			for _, migration := range migrator.migrations {
+				assert.NotEmpty(t, splitStatements(migration.Up), migration.Name)
+				assert.NotEmpty(t, splitStatements(migration.Down), migration.Name)
+			}
+			latest = append(latest, migrator.LatestVersion())
+		}
+	}
+	// schema of each dialect must be migrated to the same version
+	assert.Equal(t, []int{latest[0], latest[0], latest[0]}, latest)
+
+	// the service was released with MySQL schema only, so only its databases
+	// are converted from the baseline
+	mysql, err := NewMigrator(nil, DialectMySQL)
+	if assert.NoError(t, err) {
+		assert.NotEmpty(t, splitStatements(mysql.migrations[0].Baseline))
+	}
+
+	_, err = NewMigrator(nil, "oracle")
+	assert.Error(t, err)
+}
+
+// TestVersionStatements checks versions of migrations are recorded with
+// placeholders of each dialect, Postgres rejects "?"
+func TestVersionStatements(t *testing.T) {
+	tests := []struct {
+		dialect    string
+		wantRecord string
+		wantForget string
+	}{
+		{DialectMySQL, "INSERT INTO schema_migrations (version) VALUES (?)", "DELETE FROM schema_migrations WHERE version = ?"},
+		{DialectPostgres, "INSERT INTO schema_migrations (version) VALUES ($1)", "DELETE FROM schema_migrations WHERE version = $1"},
+		{DialectSQLite, "INSERT INTO schema_migrations (version) VALUES (?)", "DELETE FROM schema_migrations WHERE version = ?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			query, args, err := recordVersion(tt.dialect, 3).ToSql()
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.wantRecord, query)
+				assert.Equal(t, []interface{}{3}, args)
+			}
+
+			query, args, err = forgetVersion(tt.dialect, 3).ToSql()
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.wantForget, query)
+				assert.Equal(t, []interface{}{3}, args)
+			}
+		})
+	}
+}
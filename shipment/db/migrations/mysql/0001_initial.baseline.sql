@@ -0,0 +1,135 @@
+-- converts database of db/migration.sql the service was released with
+-- (shipments and customers tables only) to the schema of
+-- 0001_initial.up.sql, it's applied instead of the latter if tables exist,
+-- but no migration is recorded (see Migrator.Up)
+
+-- shipments and customers present are assigned to the default account
+ALTER TABLE `shipments`
+    ADD COLUMN `account_id` INT NOT NULL DEFAULT 1 AFTER `id`,
+    ADD INDEX `account` (`account_id`);
+
+ALTER TABLE `shipments`
+    ALTER COLUMN `account_id` DROP DEFAULT;
+
+ALTER TABLE `customers`
+    ADD COLUMN `account_id` INT NOT NULL DEFAULT 1 AFTER `id`,
+    ADD COLUMN `match_key` CHAR(64) NOT NULL DEFAULT '' AFTER `account_id`,
+    MODIFY COLUMN `name` VARCHAR(1024) NULL,
+    MODIFY COLUMN `email` VARCHAR(1024) NULL,
+    ADD COLUMN `phone` VARCHAR(1024) NOT NULL DEFAULT '' AFTER `email`,
+    ADD COLUMN `address_street_lines` VARCHAR(2048) NOT NULL DEFAULT '' AFTER `address`,
+    ADD COLUMN `address_house_number` VARCHAR(1024) NOT NULL DEFAULT '' AFTER `address_street_lines`,
+    ADD COLUMN `address_postal_code` VARCHAR(1024) NOT NULL DEFAULT '' AFTER `address_house_number`,
+    ADD COLUMN `address_city` VARCHAR(1024) NOT NULL DEFAULT '' AFTER `address_postal_code`,
+    ADD COLUMN `address_region` VARCHAR(1024) NOT NULL DEFAULT '' AFTER `address_city`,
+    ADD COLUMN `erased_at` DATETIME NULL AFTER `created_at`,
+    DROP INDEX `customer`;
+
+-- single line addresses have "<street> <house number>, <city> <postal code>"
+-- format, assignments are applied left to right, so parts are cut off the
+-- end first
+UPDATE `customers` SET
+    `address_street_lines` = TRIM(SUBSTRING_INDEX(`address`, ',', 1)),
+    `address_city` = TRIM(SUBSTRING_INDEX(`address`, ',', -1));
+
+UPDATE `customers` SET
+    `address_house_number` = SUBSTRING_INDEX(`address_street_lines`, ' ', -1),
+    `address_street_lines` = TRIM(LEFT(`address_street_lines`,
+        CHAR_LENGTH(`address_street_lines`) - CHAR_LENGTH(`address_house_number`)))
+WHERE `address_street_lines` REGEXP ' [0-9][0-9A-Za-z/-]*$';
+
+UPDATE `customers` SET
+    `address_postal_code` = SUBSTRING_INDEX(`address_city`, ' ', -1),
+    `address_city` = TRIM(LEFT(`address_city`,
+        CHAR_LENGTH(`address_city`) - CHAR_LENGTH(`address_postal_code`)))
+WHERE `address_city` REGEXP ' [0-9]+$';
+
+-- match key is SHA-256 of models.Customer.MatchKey, as encryption.Plaintext
+-- computes it, address is formatted by models.PostalAddress.String
+UPDATE `customers` SET `match_key` = SHA2(CONCAT_WS('|',
+    LOWER(TRIM(REGEXP_REPLACE(COALESCE(`name`, ''), '[[:space:]]+', ' '))),
+    LOWER(TRIM(REGEXP_REPLACE(COALESCE(`email`, ''), '[[:space:]]+', ' '))),
+    LOWER(TRIM(REGEXP_REPLACE(CONCAT(
+        `address_street_lines`,
+        IF(`address_house_number` <> '', CONCAT(' ', `address_house_number`), ''),
+        ', ',
+        TRIM(CONCAT(`address_postal_code`, ' ', `address_city`))), '[[:space:]]+', ' '))),
+    LOWER(TRIM(COALESCE(`country_code`, '')))), 256);
+
+ALTER TABLE `customers`
+    DROP COLUMN `address`,
+    ALTER COLUMN `account_id` DROP DEFAULT,
+    ADD INDEX `account_match_key` (`account_id`, `match_key`);
+
+-- tables the baseline schema doesn't have
+CREATE TABLE `saved_addresses` (
+    `id` INT NOT NULL AUTO_INCREMENT,
+    `account_id` INT NOT NULL,
+    `label` VARCHAR(50) NOT NULL,
+    `name` VARCHAR(1024) NULL,
+    `email` VARCHAR(1024) NULL,
+    `phone` VARCHAR(1024) NOT NULL DEFAULT '',
+    `address_street_lines` VARCHAR(2048) NOT NULL DEFAULT '',
+    `address_house_number` VARCHAR(1024) NOT NULL DEFAULT '',
+    `address_postal_code` VARCHAR(1024) NOT NULL DEFAULT '',
+    `address_city` VARCHAR(1024) NOT NULL DEFAULT '',
+    `address_region` VARCHAR(1024) NOT NULL DEFAULT '',
+    `country_code` VARCHAR(2) NULL,
+    `created_at` DATETIME NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` DATETIME NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX `account` (`account_id`),
+    PRIMARY KEY (`id`));
+
+CREATE TABLE `customer_merges` (
+    `id` INT NOT NULL AUTO_INCREMENT,
+    `survivor_id` INT NOT NULL,
+    `merged_id` INT NOT NULL,
+    `merged_customer` TEXT NOT NULL,
+    `shipments_moved` INT NOT NULL DEFAULT 0,
+    `created_at` DATETIME NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX `survivor` (`survivor_id`),
+    PRIMARY KEY (`id`));
+
+-- accounts (tenants) and their API keys, only SHA-256 hashes of keys are
+-- stored
+CREATE TABLE `accounts` (
+    `id` INT NOT NULL AUTO_INCREMENT,
+    `name` VARCHAR(100) NOT NULL,
+    `created_at` DATETIME NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`));
+
+CREATE TABLE `api_keys` (
+    `id` INT NOT NULL AUTO_INCREMENT,
+    `account_id` INT NOT NULL,
+    `name` VARCHAR(100) NOT NULL,
+    `role` VARCHAR(20) NOT NULL,
+    `prefix` VARCHAR(16) NOT NULL,
+    `hash` CHAR(64) NOT NULL,
+    `created_at` DATETIME NULL DEFAULT CURRENT_TIMESTAMP,
+    `revoked_at` DATETIME NULL,
+    UNIQUE INDEX `hash` (`hash`),
+    INDEX `account` (`account_id`),
+    PRIMARY KEY (`id`));
+
+INSERT INTO `accounts` (`id`, `name`) VALUES (1, 'Default');
+
+-- append-only audit log of mutating operations, personal data is redacted
+-- in changes; triggers reject modification of recorded entries
+CREATE TABLE `audit_log` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT,
+    `account_id` INT NOT NULL,
+    `actor` VARCHAR(255) NOT NULL,
+    `action` VARCHAR(20) NOT NULL,
+    `entity` VARCHAR(30) NOT NULL,
+    `entity_id` INT NOT NULL,
+    `changes` TEXT NOT NULL,
+    `request_id` VARCHAR(100) NOT NULL DEFAULT '',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX `account_entity` (`account_id`, `entity`, `entity_id`),
+    PRIMARY KEY (`id`));
+
+CREATE TRIGGER `audit_log_no_update` BEFORE UPDATE ON `audit_log`
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
+
+CREATE TRIGGER `audit_log_no_delete` BEFORE DELETE ON `audit_log`
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
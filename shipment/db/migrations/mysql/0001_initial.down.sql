@@ -0,0 +1,13 @@
+DROP TABLE `audit_log`;
+
+DROP TABLE `api_keys`;
+
+DROP TABLE `accounts`;
+
+DROP TABLE `customer_merges`;
+
+DROP TABLE `saved_addresses`;
+
+DROP TABLE `customers`;
+
+DROP TABLE `shipments`;
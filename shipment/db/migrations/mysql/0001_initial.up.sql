@@ -0,0 +1,107 @@
+-- schema db/migration.sql had when migrations were introduced, applied to
+-- empty databases. Databases created by hand from db/migration.sql have no
+-- version recorded, ones with the schema the service was released with are
+-- converted by 0001_initial.baseline.sql instead (see Migrator.Up)
+
+CREATE TABLE `shipments` (
+    `id` INT NOT NULL AUTO_INCREMENT,
+    `account_id` INT NOT NULL,
+    `weight` INT NULL,
+    `price` INT NULL,
+    `customer_from` INT NULL,
+    `customer_to` INT NULL,
+    `created_at` DATETIME NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX `account` (`account_id`),
+    PRIMARY KEY (`id`));
+
+-- personal data is stored encrypted (see encryption package), match key is
+-- blind index (HMAC-SHA256) of models.Customer.MatchKey
+CREATE TABLE `customers` (
+    `id` INT NOT NULL AUTO_INCREMENT,
+    `account_id` INT NOT NULL,
+    `match_key` CHAR(64) NOT NULL DEFAULT '',
+    `name` VARCHAR(1024) NULL,
+    `email` VARCHAR(1024) NULL,
+    `phone` VARCHAR(1024) NOT NULL DEFAULT '',
+    `address_street_lines` VARCHAR(2048) NOT NULL DEFAULT '',
+    `address_house_number` VARCHAR(1024) NOT NULL DEFAULT '',
+    `address_postal_code` VARCHAR(1024) NOT NULL DEFAULT '',
+    `address_city` VARCHAR(1024) NOT NULL DEFAULT '',
+    `address_region` VARCHAR(1024) NOT NULL DEFAULT '',
+    `country_code` VARCHAR(2) NULL,
+    `created_at` DATETIME NULL DEFAULT CURRENT_TIMESTAMP,
+    `erased_at` DATETIME NULL,
+    INDEX `account_match_key` (`account_id`, `match_key`),
+    PRIMARY KEY (`id`));
+
+CREATE TABLE `saved_addresses` (
+    `id` INT NOT NULL AUTO_INCREMENT,
+    `account_id` INT NOT NULL,
+    `label` VARCHAR(50) NOT NULL,
+    `name` VARCHAR(1024) NULL,
+    `email` VARCHAR(1024) NULL,
+    `phone` VARCHAR(1024) NOT NULL DEFAULT '',
+    `address_street_lines` VARCHAR(2048) NOT NULL DEFAULT '',
+    `address_house_number` VARCHAR(1024) NOT NULL DEFAULT '',
+    `address_postal_code` VARCHAR(1024) NOT NULL DEFAULT '',
+    `address_city` VARCHAR(1024) NOT NULL DEFAULT '',
+    `address_region` VARCHAR(1024) NOT NULL DEFAULT '',
+    `country_code` VARCHAR(2) NULL,
+    `created_at` DATETIME NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` DATETIME NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX `account` (`account_id`),
+    PRIMARY KEY (`id`));
+
+CREATE TABLE `customer_merges` (
+    `id` INT NOT NULL AUTO_INCREMENT,
+    `survivor_id` INT NOT NULL,
+    `merged_id` INT NOT NULL,
+    `merged_customer` TEXT NOT NULL,
+    `shipments_moved` INT NOT NULL DEFAULT 0,
+    `created_at` DATETIME NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX `survivor` (`survivor_id`),
+    PRIMARY KEY (`id`));
+
+-- accounts (tenants) and their API keys, only SHA-256 hashes of keys are
+-- stored
+CREATE TABLE `accounts` (
+    `id` INT NOT NULL AUTO_INCREMENT,
+    `name` VARCHAR(100) NOT NULL,
+    `created_at` DATETIME NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`));
+
+CREATE TABLE `api_keys` (
+    `id` INT NOT NULL AUTO_INCREMENT,
+    `account_id` INT NOT NULL,
+    `name` VARCHAR(100) NOT NULL,
+    `role` VARCHAR(20) NOT NULL,
+    `prefix` VARCHAR(16) NOT NULL,
+    `hash` CHAR(64) NOT NULL,
+    `created_at` DATETIME NULL DEFAULT CURRENT_TIMESTAMP,
+    `revoked_at` DATETIME NULL,
+    UNIQUE INDEX `hash` (`hash`),
+    INDEX `account` (`account_id`),
+    PRIMARY KEY (`id`));
+
+INSERT INTO `accounts` (`id`, `name`) VALUES (1, 'Default');
+
+-- append-only audit log of mutating operations, personal data is redacted
+-- in changes; triggers reject modification of recorded entries
+CREATE TABLE `audit_log` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT,
+    `account_id` INT NOT NULL,
+    `actor` VARCHAR(255) NOT NULL,
+    `action` VARCHAR(20) NOT NULL,
+    `entity` VARCHAR(30) NOT NULL,
+    `entity_id` INT NOT NULL,
+    `changes` TEXT NOT NULL,
+    `request_id` VARCHAR(100) NOT NULL DEFAULT '',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX `account_entity` (`account_id`, `entity`, `entity_id`),
+    PRIMARY KEY (`id`));
+
+CREATE TRIGGER `audit_log_no_update` BEFORE UPDATE ON `audit_log`
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
+
+CREATE TRIGGER `audit_log_no_delete` BEFORE DELETE ON `audit_log`
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
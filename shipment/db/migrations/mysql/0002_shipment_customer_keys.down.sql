@@ -0,0 +1,9 @@
+-- indexes are used by foreign keys, so they are dropped afterwards
+ALTER TABLE `shipments`
+    DROP FOREIGN KEY `shipments_customer_from_fk`,
+    DROP FOREIGN KEY `shipments_customer_to_fk`;
+
+ALTER TABLE `shipments`
+    DROP INDEX `customer_from`,
+    DROP INDEX `customer_to`,
+    DROP INDEX `created_at`;
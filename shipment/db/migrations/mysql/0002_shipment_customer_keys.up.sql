@@ -0,0 +1,10 @@
+-- shipments must refer to existing customers, customers are deleted only
+-- after their shipments are repointed (see CustomersRepo.MergeCustomers).
+-- Fails if shipments refer to missing customers, such references must be
+-- fixed first
+ALTER TABLE `shipments`
+    ADD INDEX `customer_from` (`customer_from`),
+    ADD INDEX `customer_to` (`customer_to`),
+    ADD INDEX `created_at` (`created_at`),
+    ADD CONSTRAINT `shipments_customer_from_fk` FOREIGN KEY (`customer_from`) REFERENCES `customers` (`id`),
+    ADD CONSTRAINT `shipments_customer_to_fk` FOREIGN KEY (`customer_to`) REFERENCES `customers` (`id`);
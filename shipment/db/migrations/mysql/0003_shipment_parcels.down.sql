@@ -0,0 +1,4 @@
+ALTER TABLE `shipments`
+    DROP COLUMN `currency`;
+
+DROP TABLE `parcels`;
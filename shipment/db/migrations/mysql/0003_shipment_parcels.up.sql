@@ -0,0 +1,16 @@
+-- shipments consist of parcels, weight of shipment is total weight of its
+-- parcels. Existing shipments get a single parcel of their weight
+CREATE TABLE `parcels` (
+    `id` INT NOT NULL AUTO_INCREMENT,
+    `shipment_id` INT NOT NULL,
+    `weight` INT NOT NULL,
+    INDEX `shipment` (`shipment_id`),
+    PRIMARY KEY (`id`),
+    CONSTRAINT `parcels_shipment_fk` FOREIGN KEY (`shipment_id`) REFERENCES `shipments` (`id`));
+
+INSERT INTO `parcels` (`shipment_id`, `weight`)
+    SELECT `id`, `weight` FROM `shipments` WHERE `weight` IS NOT NULL ORDER BY `id`;
+
+-- prices are in currency of the tariff, see models.PriceCurrency
+ALTER TABLE `shipments`
+    ADD COLUMN `currency` CHAR(3) NOT NULL DEFAULT 'SEK' AFTER `price`;
@@ -0,0 +1,4 @@
+-- merged duplicates aren't restored
+ALTER TABLE `customers`
+    DROP INDEX `account_match_key`,
+    ADD INDEX `account_match_key` (`account_id`, `match_key`);
@@ -0,0 +1 @@
+DROP TABLE `customer_match_key_backfill`;
@@ -0,0 +1,15 @@
+DROP TABLE audit_log;
+
+DROP FUNCTION audit_log_append_only();
+
+DROP TABLE api_keys;
+
+DROP TABLE accounts;
+
+DROP TABLE customer_merges;
+
+DROP TABLE saved_addresses;
+
+DROP TABLE customers;
+
+DROP TABLE shipments;
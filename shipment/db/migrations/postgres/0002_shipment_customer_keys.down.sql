@@ -0,0 +1,9 @@
+ALTER TABLE shipments
+    DROP CONSTRAINT shipments_customer_from_fk,
+    DROP CONSTRAINT shipments_customer_to_fk;
+
+DROP INDEX shipments_customer_from;
+
+DROP INDEX shipments_customer_to;
+
+DROP INDEX shipments_created_at;
@@ -0,0 +1,13 @@
+-- shipments must refer to existing customers, customers are deleted only
+-- after their shipments are repointed (see CustomersRepo.MergeCustomers).
+-- Fails if shipments refer to missing customers, such references must be
+-- fixed first
+CREATE INDEX shipments_customer_from ON shipments (customer_from);
+
+CREATE INDEX shipments_customer_to ON shipments (customer_to);
+
+CREATE INDEX shipments_created_at ON shipments (created_at);
+
+ALTER TABLE shipments
+    ADD CONSTRAINT shipments_customer_from_fk FOREIGN KEY (customer_from) REFERENCES customers (id),
+    ADD CONSTRAINT shipments_customer_to_fk FOREIGN KEY (customer_to) REFERENCES customers (id);
@@ -0,0 +1,15 @@
+-- shipments consist of parcels, weight of shipment is total weight of its
+-- parcels. Existing shipments get a single parcel of their weight
+CREATE TABLE parcels (
+    id SERIAL PRIMARY KEY,
+    shipment_id INT NOT NULL CONSTRAINT parcels_shipment_fk REFERENCES shipments (id),
+    weight INT NOT NULL);
+
+CREATE INDEX parcels_shipment ON parcels (shipment_id);
+
+INSERT INTO parcels (shipment_id, weight)
+    SELECT id, weight FROM shipments WHERE weight IS NOT NULL ORDER BY id;
+
+-- prices are in currency of the tariff, see models.PriceCurrency
+ALTER TABLE shipments
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'SEK';
@@ -0,0 +1,38 @@
+-- customers of an account are unique by match key, so concurrent shipments
+-- of a new customer can't create duplicates (see
+-- CustomersRepo.InsertAndReturnCustomer). Existing duplicates are merged
+-- into the earliest customer first; they differ in case, whitespaces or
+-- phone only, so merges are recorded without snapshots of merged customers
+CREATE TABLE customer_duplicates (
+    id INT NOT NULL,
+    survivor_id INT NOT NULL,
+    PRIMARY KEY (id));
+
+INSERT INTO customer_duplicates (id, survivor_id)
+    SELECT customers.id, survivors.id
+    FROM customers
+    JOIN (SELECT account_id, match_key, MIN(id) AS id FROM customers GROUP BY account_id, match_key) AS survivors
+        ON survivors.account_id = customers.account_id AND survivors.match_key = customers.match_key
+    WHERE customers.id <> survivors.id;
+
+INSERT INTO customer_merges (survivor_id, merged_id, merged_customer, shipments_moved)
+    SELECT survivor_id, id, '{}',
+        (SELECT COUNT(*) FROM shipments WHERE shipments.customer_from = customer_duplicates.id) +
+        (SELECT COUNT(*) FROM shipments WHERE shipments.customer_to = customer_duplicates.id)
+    FROM customer_duplicates ORDER BY id;
+
+UPDATE shipments
+    SET customer_from = (SELECT survivor_id FROM customer_duplicates WHERE customer_duplicates.id = shipments.customer_from)
+    WHERE customer_from IN (SELECT id FROM customer_duplicates);
+
+UPDATE shipments
+    SET customer_to = (SELECT survivor_id FROM customer_duplicates WHERE customer_duplicates.id = shipments.customer_to)
+    WHERE customer_to IN (SELECT id FROM customer_duplicates);
+
+DELETE FROM customers WHERE id IN (SELECT id FROM customer_duplicates);
+
+DROP TABLE customer_duplicates;
+
+DROP INDEX customers_account_match_key;
+
+CREATE UNIQUE INDEX customers_account_match_key ON customers (account_id, match_key);
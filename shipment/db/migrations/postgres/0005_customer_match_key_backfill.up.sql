@@ -0,0 +1,11 @@
+-- match keys of customers stored before they became blind indexes are
+-- unkeyed SHA-256 (e.g. ones of 0001_initial.baseline.sql), so lookups by
+-- blind index miss them. Such keys can't be told apart, so match keys of all
+-- present customers are recomputed by rotate-keys job, which empties this
+-- table; service doesn't start while it's not empty
+CREATE TABLE customer_match_key_backfill (
+    customer_id INT NOT NULL,
+    PRIMARY KEY (customer_id));
+
+INSERT INTO customer_match_key_backfill (customer_id)
+    SELECT id FROM customers;
@@ -0,0 +1,105 @@
+-- schema of mysql/0001_initial.up.sql, AUTOINCREMENT assigns IDs
+
+CREATE TABLE shipments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INT NOT NULL,
+    weight INT NULL,
+    price INT NULL,
+    customer_from INT NULL,
+    customer_to INT NULL,
+    created_at DATETIME NULL DEFAULT CURRENT_TIMESTAMP);
+
+CREATE INDEX shipments_account ON shipments (account_id);
+
+-- personal data is stored encrypted (see encryption package), match key is
+-- blind index (HMAC-SHA256) of models.Customer.MatchKey
+CREATE TABLE customers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INT NOT NULL,
+    match_key CHAR(64) NOT NULL DEFAULT '',
+    name VARCHAR(1024) NULL,
+    email VARCHAR(1024) NULL,
+    phone VARCHAR(1024) NOT NULL DEFAULT '',
+    address_street_lines VARCHAR(2048) NOT NULL DEFAULT '',
+    address_house_number VARCHAR(1024) NOT NULL DEFAULT '',
+    address_postal_code VARCHAR(1024) NOT NULL DEFAULT '',
+    address_city VARCHAR(1024) NOT NULL DEFAULT '',
+    address_region VARCHAR(1024) NOT NULL DEFAULT '',
+    country_code VARCHAR(2) NULL,
+    created_at DATETIME NULL DEFAULT CURRENT_TIMESTAMP,
+    erased_at DATETIME NULL);
+
+CREATE INDEX customers_account_match_key ON customers (account_id, match_key);
+
+CREATE TABLE saved_addresses (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INT NOT NULL,
+    label VARCHAR(50) NOT NULL,
+    name VARCHAR(1024) NULL,
+    email VARCHAR(1024) NULL,
+    phone VARCHAR(1024) NOT NULL DEFAULT '',
+    address_street_lines VARCHAR(2048) NOT NULL DEFAULT '',
+    address_house_number VARCHAR(1024) NOT NULL DEFAULT '',
+    address_postal_code VARCHAR(1024) NOT NULL DEFAULT '',
+    address_city VARCHAR(1024) NOT NULL DEFAULT '',
+    address_region VARCHAR(1024) NOT NULL DEFAULT '',
+    country_code VARCHAR(2) NULL,
+    created_at DATETIME NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NULL DEFAULT CURRENT_TIMESTAMP);
+
+CREATE INDEX saved_addresses_account ON saved_addresses (account_id);
+
+CREATE TABLE customer_merges (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    survivor_id INT NOT NULL,
+    merged_id INT NOT NULL,
+    merged_customer TEXT NOT NULL,
+    shipments_moved INT NOT NULL DEFAULT 0,
+    created_at DATETIME NULL DEFAULT CURRENT_TIMESTAMP);
+
+CREATE INDEX customer_merges_survivor ON customer_merges (survivor_id);
+
+-- accounts (tenants) and their API keys, only SHA-256 hashes of keys are
+-- stored
+CREATE TABLE accounts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(100) NOT NULL,
+    created_at DATETIME NULL DEFAULT CURRENT_TIMESTAMP);
+
+CREATE TABLE api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    hash CHAR(64) NOT NULL,
+    created_at DATETIME NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at DATETIME NULL);
+
+CREATE UNIQUE INDEX api_keys_hash ON api_keys (hash);
+
+CREATE INDEX api_keys_account ON api_keys (account_id);
+
+-- ID is assigned by AUTOINCREMENT, so it is the first one
+INSERT INTO accounts (name) VALUES ('Default');
+
+-- append-only audit log of mutating operations, personal data is redacted
+-- in changes; triggers reject modification of recorded entries
+CREATE TABLE audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INT NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    entity VARCHAR(30) NOT NULL,
+    entity_id INT NOT NULL,
+    changes TEXT NOT NULL,
+    request_id VARCHAR(100) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP);
+
+CREATE INDEX audit_log_account_entity ON audit_log (account_id, entity, entity_id);
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+    BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END;
+
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+    BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END;
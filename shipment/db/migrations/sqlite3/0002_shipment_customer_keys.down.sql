@@ -0,0 +1,16 @@
+CREATE TABLE shipments_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INT NOT NULL,
+    weight INT NULL,
+    price INT NULL,
+    customer_from INT NULL,
+    customer_to INT NULL,
+    created_at DATETIME NULL DEFAULT CURRENT_TIMESTAMP);
+
+INSERT INTO shipments_old SELECT id, account_id, weight, price, customer_from, customer_to, created_at FROM shipments;
+
+DROP TABLE shipments;
+
+ALTER TABLE shipments_old RENAME TO shipments;
+
+CREATE INDEX shipments_account ON shipments (account_id);
@@ -0,0 +1,26 @@
+-- shipments must refer to existing customers, customers are deleted only
+-- after their shipments are repointed (see CustomersRepo.MergeCustomers).
+-- SQLite can't add foreign keys to existing table, so it's rebuilt. Keys
+-- are enforced only if enabled by _foreign_keys=1 in DSN
+CREATE TABLE shipments_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INT NOT NULL,
+    weight INT NULL,
+    price INT NULL,
+    customer_from INT NULL CONSTRAINT shipments_customer_from_fk REFERENCES customers (id),
+    customer_to INT NULL CONSTRAINT shipments_customer_to_fk REFERENCES customers (id),
+    created_at DATETIME NULL DEFAULT CURRENT_TIMESTAMP);
+
+INSERT INTO shipments_new SELECT id, account_id, weight, price, customer_from, customer_to, created_at FROM shipments;
+
+DROP TABLE shipments;
+
+ALTER TABLE shipments_new RENAME TO shipments;
+
+CREATE INDEX shipments_account ON shipments (account_id);
+
+CREATE INDEX shipments_customer_from ON shipments (customer_from);
+
+CREATE INDEX shipments_customer_to ON shipments (customer_to);
+
+CREATE INDEX shipments_created_at ON shipments (created_at);
@@ -0,0 +1,4 @@
+-- merged duplicates aren't restored
+DROP INDEX customers_account_match_key;
+
+CREATE INDEX customers_account_match_key ON customers (account_id, match_key);
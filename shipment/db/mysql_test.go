@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"sendify_test/shipment/encryption"
+	"sendify_test/shipment/models"
+	"testing"
+	"time"
+)
+
+// openMySQLDB creates empty database on MySQL server of TEST_MYSQL_DSN,
+// which is dropped after the test. The test is skipped unless the variable
+// is set, e.g. to root:secret@tcp(localhost:3306)/
+func openMySQLDB(t *testing.T) *gorm.DB {
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("TEST_MYSQL_DSN is not set")
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	cfg.DBName = fmt.Sprintf("shipment_test_%d", time.Now().UnixNano())
+	if _, err := server.Exec("CREATE DATABASE `" + cfg.DBName + "`"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := server.Exec("DROP DATABASE `" + cfg.DBName + "`"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	cfg.ParseTime = true
+	db, err := gorm.Open(DialectMySQL, cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// TestMigrator_MySQLReleasedSchema checks database of db/migration.sql the
+// service was released with is converted by baseline and migrated to the
+// latest version by embedded migrations
+func TestMigrator_MySQLReleasedSchema(t *testing.T) {
+	ctx := context.Background()
+	db := openMySQLDB(t)
+
+	schema, err := os.ReadFile("testdata/migration.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range splitStatements(string(schema)) {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+
+	migrator, err := NewMigrator(db.DB(), DialectMySQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := migrator.Up(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, applied, migrator.LatestVersion())
+
+	healthRepo := NewHealthRepo(db)
+	version, err := healthRepo.GetSchemaVersion(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, SchemaVersion, version)
+	}
+
+	// addresses are split and match keys are computed as encryption.Plaintext
+	// does, so customers are found by their details
+	customersRepo := NewCustomersRepo(db, encryption.Plaintext{})
+	customers, err := customersRepo.GetAllCustomers(ctx, 1)
+	if assert.NoError(t, err) && assert.Len(t, customers, 2) {
+		assert.Equal(t, models.PostalAddress{
+			StreetLines: models.StreetLines{"Drottninggatan"},
+			HouseNumber: "1",
+			PostalCode:  "11151",
+			City:        "Stockholm",
+		}, customers[0].Address)
+		assert.Equal(t, "12B", customers[1].Address.HouseNumber)
+
+		for _, customer := range customers {
+			present := models.Customer{
+				AccountID:   1,
+				Name:        customer.Name,
+				Email:       customer.Email,
+				Address:     customer.Address,
+				CountryCode: customer.CountryCode,
+			}
+			if assert.NoError(t, customersRepo.CheckIfCustomerPresentAndReturn(ctx, &present), customer.Name) {
+				assert.Equal(t, customer.ID, present.ID)
+			}
+		}
+	}
+
+	shipment, err := NewShipmentsRepo(db).GetShipmentByID(ctx, 1, 1)
+	if assert.NoError(t, err) && assert.Len(t, shipment.Parcels, 1) {
+		assert.Equal(t, 1, shipment.FromID)
+		assert.Equal(t, 2, shipment.ToID)
+		assert.Equal(t, 10, shipment.Parcels[0].Weight)
+	}
+
+	// match keys are recomputed as blind indexes by rotate-keys
+	pending, err := healthRepo.CountPendingMatchKeys(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, pending)
+	}
+	_, collisions, err := NewCustomersRepo(db, testKeyring(t)).ReencryptCustomers(ctx)
+	if assert.NoError(t, err) {
+		assert.Empty(t, collisions)
+	}
+	pending, err = healthRepo.CountPendingMatchKeys(ctx)
+	if assert.NoError(t, err) {
+		assert.Zero(t, pending)
+	}
+}
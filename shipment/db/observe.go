@@ -0,0 +1,34 @@
+package db
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"sendify_test/shipment/metrics"
+	"sendify_test/shipment/tracing"
+	"time"
+)
+
+// observe starts span of repo method, applies its query deadline and returns
+// db bound to the context with function ending span and recording duration
+// of the method, the function should be deferred
+func observe(ctx context.Context, db *gorm.DB, repo, method string) (context.Context, *gorm.DB, func()) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "db."+repo+"."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			dbSystem(db.Dialect().GetName()),
+			semconv.DBOperationKey.String(method),
+			attribute.String("db.repo", repo),
+		))
+
+	ctx, cancel := withQueryTimeout(ctx, repo+"."+method)
+
+	return ctx, withContext(ctx, db, repo+"."+method), func() {
+		cancel()
+		span.End()
+		metrics.ObserveQuery(repo, method, start)
+	}
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"sendify_test/shipment/encryption"
+	"sendify_test/shipment/models"
+	"strings"
+)
+
+// piiFields points to personal data of a customer or saved address, which
+// is stored encrypted in table
+type piiFields struct {
+	table   string
+	name    *string
+	email   *string
+	phone   *string
+	address *models.PostalAddress
+}
+
+func customerPII(customer *models.Customer) piiFields {
+	return piiFields{
+		table:   "customers",
+		name:    &customer.Name,
+		email:   &customer.Email,
+		phone:   &customer.Phone,
+		address: &customer.Address,
+	}
+}
+
+func savedAddressPII(address *models.SavedAddress) piiFields {
+	return piiFields{
+		table:   "saved_addresses",
+		name:    &address.Name,
+		email:   &address.Email,
+		phone:   &address.Phone,
+		address: &address.Address,
+	}
+}
+
+// columns returns pointers to plaintext values by column names, street
+// lines are joined by newline
+func (f piiFields) columns(streetLines *string) map[string]*string {
+	return map[string]*string{
+		"name":                 f.name,
+		"email":                f.email,
+		"phone":                f.phone,
+		"address_street_lines": streetLines,
+		"address_house_number": &f.address.HouseNumber,
+		"address_postal_code":  &f.address.PostalCode,
+		"address_city":         &f.address.City,
+		"address_region":       &f.address.Region,
+	}
+}
+
+// encrypt returns encrypted values by column names
+func (f piiFields) encrypt(cipher encryption.Cipher) (map[string]interface{}, error) {
+	streetLines := strings.Join(f.address.StreetLines, "\n")
+
+	values := map[string]interface{}{}
+	for column, value := range f.columns(&streetLines) {
+		encrypted, err := cipher.Encrypt(f.table+"."+column, *value)
+		if err != nil {
+			return nil, err
+		}
+		values[column] = encrypted
+	}
+
+	return values, nil
+}
+
+// decrypt replaces encrypted values read from DB by plaintext ones
+func (f piiFields) decrypt(cipher encryption.Cipher) error {
+	streetLines := strings.Join(f.address.StreetLines, "\n")
+
+	for column, value := range f.columns(&streetLines) {
+		decrypted, err := cipher.Decrypt(f.table+"."+column, *value)
+		if err != nil {
+			return err
+		}
+		*value = decrypted
+	}
+
+	if err := f.address.StreetLines.Scan(streetLines); err != nil {
+		return err
+	}
+	return nil
+}
+
+// reencrypt returns values re-encrypted by active key by column names, only
+// changed values are returned
+func (f piiFields) reencrypt(cipher encryption.Cipher) (map[string]interface{}, error) {
+	streetLines := strings.Join(f.address.StreetLines, "\n")
+
+	values := map[string]interface{}{}
+	for column, value := range f.columns(&streetLines) {
+		reencrypted, changed, err := cipher.Reencrypt(f.table+"."+column, *value)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			values[column] = reencrypted
+		}
+	}
+
+	return values, nil
+}
+
+// decryptCustomers decrypts personal data of customers in place
+func decryptCustomers(cipher encryption.Cipher, customers models.Customers) error {
+	for i := range customers {
+		if err := customerPII(&customers[i]).decrypt(cipher); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decryptAddresses decrypts personal data of saved addresses in place
+func decryptAddresses(cipher encryption.Cipher, addresses models.SavedAddresses) error {
+	for i := range addresses {
+		if err := savedAddressPII(&addresses[i]).decrypt(cipher); err != nil {
+			return err
+		}
+	}
+	return nil
+}
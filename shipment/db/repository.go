@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"sendify_test/shipment/models"
+	"time"
+)
+
+// ShipmentRepository stores shipments, implemented by ShipmentsRepo and
+// MemoryShipmentsRepo
+type ShipmentRepository interface {
+	GetShipmentByID(ctx context.Context, accountID, id int) (models.Shipment, error)
+	GetAllShipments(ctx context.Context, accountID int) (models.Shipments, error)
+	GetShipmentsByCustomerID(ctx context.Context, accountID, customerID int) (models.Shipments, error)
+	InsertShipment(ctx context.Context, shipment *models.Shipment) error
+}
+
+// CustomerRepository stores customers and records of their merges,
+// customers of an account are unique by models.Customer.MatchKey, even if
+// they are inserted concurrently. Implemented by CustomersRepo and
+// MemoryCustomersRepo
+type CustomerRepository interface {
+	GetCustomerByID(ctx context.Context, accountID, id int) (models.Customer, error)
+	CheckIfCustomerPresentAndReturn(ctx context.Context, customer *models.Customer) error
+	InsertAndReturnCustomer(ctx context.Context, customer *models.Customer) error
+	UpdateCustomerPhone(ctx context.Context, id int, phone string) error
+	GetCustomersByIDs(ctx context.Context, accountID int, customerIDs []int) (models.Customers, error)
+	GetAllCustomers(ctx context.Context, accountID int) (models.Customers, error)
+	MergeCustomers(ctx context.Context, accountID, survivorID int, duplicateIDs []int) ([]models.CustomerMerge, error)
+	GetMergesBySurvivorID(ctx context.Context, survivorID int) ([]models.CustomerMerge, error)
+	EraseCustomer(ctx context.Context, erased models.Customer) error
+	GetCustomersForRetention(ctx context.Context, before time.Time) (models.Customers, error)
+	ReencryptCustomers(ctx context.Context) (int, models.DuplicateCandidates, error)
+}
+
+// AddressBookRepository stores saved addresses of accounts, implemented by
+// AddressBookRepo and MemoryAddressBookRepo
+type AddressBookRepository interface {
+	GetAddressByID(ctx context.Context, id int) (models.SavedAddress, error)
+	GetAddressesByAccountID(ctx context.Context, accountID int) (models.SavedAddresses, error)
+	InsertAddress(ctx context.Context, address *models.SavedAddress) error
+	UpdateAddress(ctx context.Context, address models.SavedAddress) error
+	DeleteAddress(ctx context.Context, accountID, id int) error
+	ReencryptAddresses(ctx context.Context) (int, error)
+}
+
+// AccountRepository stores accounts and their API keys, implemented by
+// AccountsRepo
+type AccountRepository interface {
+	GetAllAccounts(ctx context.Context) (models.Accounts, error)
+	InsertAccount(ctx context.Context, account *models.Account) error
+	GetActiveAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error)
+	InsertAPIKey(ctx context.Context, key *models.APIKey) error
+	RevokeAPIKey(ctx context.Context, id int) error
+}
+
+// AuditRepository appends entries to audit log, implemented by AuditRepo
+// and MemoryAuditRepo
+type AuditRepository interface {
+	InsertEntry(ctx context.Context, entry models.AuditEntry) error
+	GetEntries(ctx context.Context, accountID int, entity string, entityID int, limit int) (models.AuditEntries, error)
+}
+
+// HealthRepository checks state of the database, implemented by HealthRepo
+type HealthRepository interface {
+	Ping(ctx context.Context) error
+	GetSchemaVersion(ctx context.Context) (int, error)
+	CountPendingMatchKeys(ctx context.Context) (int, error)
+}
+
+// Repositories are repositories bound to the same transaction, see
+// UnitOfWork
+type Repositories struct {
+	Shipments   ShipmentRepository
+	Customers   CustomerRepository
+	AddressBook AddressBookRepository
+	Audit       AuditRepository
+}
+
+// UnitOfWork runs functions in transaction with repositories bound to it,
+// changes are committed if function returns nil and discarded otherwise.
+// Implemented by Transactor and MemoryTransactor
+type UnitOfWork interface {
+	Transaction(ctx context.Context, fn func(tx Repositories) error) error
+}
+
+var (
+	_ ShipmentRepository    = (*ShipmentsRepo)(nil)
+	_ ShipmentRepository    = (*MemoryShipmentsRepo)(nil)
+	_ CustomerRepository    = (*CustomersRepo)(nil)
+	_ CustomerRepository    = (*MemoryCustomersRepo)(nil)
+	_ AddressBookRepository = (*AddressBookRepo)(nil)
+	_ AddressBookRepository = (*MemoryAddressBookRepo)(nil)
+	_ AccountRepository     = (*AccountsRepo)(nil)
+	_ AuditRepository       = (*AuditRepo)(nil)
+	_ AuditRepository       = (*MemoryAuditRepo)(nil)
+	_ HealthRepository      = (*HealthRepo)(nil)
+	_ UnitOfWork            = (*Transactor)(nil)
+	_ UnitOfWork            = (*MemoryTransactor)(nil)
+)
@@ -1,9 +1,8 @@
 package db
 
 import (
-	sq "github.com/Masterminds/squirrel"
+	"context"
 	"github.com/jinzhu/gorm"
-	"log"
 	"sendify_test/shipment/models"
 	"time"
 )
@@ -18,56 +17,158 @@ func NewShipmentsRepo(db *gorm.DB) *ShipmentsRepo {
 	}
 }
 
-// GetShipmentByID retrieves shipment object from shipments table by ID
-func (r ShipmentsRepo) GetShipmentByID(id int) (models.Shipment, error) {
+// GetShipmentByID retrieves shipment object of the account from shipments
+// table by ID with its parcels
+func (r ShipmentsRepo) GetShipmentByID(ctx context.Context, accountID, id int) (models.Shipment, error) {
+	ctx, db, end := observe(ctx, r.db, "shipments", "GetShipmentByID")
+	defer end()
+
 	var shipment models.Shipment
-	err := r.db.First(&shipment, id).Error
+	err := db.
+		Where("shipments.account_id = ?", accountID).
+		First(&shipment, id).
+		Error
 	if err != nil {
-		log.Println("Failed to retrieve shipment by ID, err: ", err.Error())
+		return models.Shipment{}, notFound(err, "shipment not found")
+	}
+
+	shipments := models.Shipments{shipment}
+	if err := attachParcels(db, shipments); err != nil {
 		return models.Shipment{}, err
 	}
 
-	return shipment, nil
+	return shipments[0], nil
 }
 
-// GetAllShipments retrieves all shipment objects from shipments table
-func (r ShipmentsRepo) GetAllShipments() (models.Shipments, error) {
+// GetAllShipments retrieves all shipment objects of the account from
+// shipments table with their parcels
+func (r ShipmentsRepo) GetAllShipments(ctx context.Context, accountID int) (models.Shipments, error) {
+	ctx, db, end := observe(ctx, r.db, "shipments", "GetAllShipments")
+	defer end()
+
 	var shipments models.Shipments
-	err := r.db.
+	err := db.
 		Table("shipments").
+		Where("shipments.account_id = ?", accountID).
 		Find(&shipments).
 		Error
 	if err != nil {
-		log.Println("Failed to retrieve all shipments, err: ", err.Error())
+		return nil, err
+	}
+
+	if err := attachParcels(db, shipments); err != nil {
+		return nil, err
+	}
+
+	return shipments, nil
+}
+
+// GetShipmentsByCustomerID retrieves shipments of the account sent or
+// received by customer with their parcels
+func (r ShipmentsRepo) GetShipmentsByCustomerID(ctx context.Context, accountID, customerID int) (models.Shipments, error) {
+	ctx, db, end := observe(ctx, r.db, "shipments", "GetShipmentsByCustomerID")
+	defer end()
+
+	var shipments models.Shipments
+	err := db.
+		Table("shipments").
+		Where("shipments.account_id = ?", accountID).
+		Where("shipments.customer_from = ? OR shipments.customer_to = ?", customerID, customerID).
+		Order("shipments.id").
+		Find(&shipments).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	if err := attachParcels(db, shipments); err != nil {
 		return nil, err
 	}
 
 	return shipments, nil
 }
 
-// InsertShipment inserts new shipment object into shipments table
-func (r ShipmentsRepo) InsertShipment(shipment models.Shipment) error {
-	_, err := sq.
+// InsertShipment inserts new shipment object into shipments table and its
+// parcels into parcels table, sets IDs of them. It should run in transaction,
+// so shipment isn't stored without parcels
+func (r ShipmentsRepo) InsertShipment(ctx context.Context, shipment *models.Shipment) error {
+	ctx, db, end := observe(ctx, r.db, "shipments", "InsertShipment")
+	defer end()
+
+	now := time.Now()
+	insert := statement(db).
 		Insert("shipments").
 		Columns(
+			"account_id",
 			"weight",
 			"price",
+			"currency",
 			"customer_from",
 			"customer_to",
 			"created_at",
 		).
 		Values(
+			shipment.AccountID,
 			shipment.Weight,
-			shipment.Price,
+			shipment.Price.Amount,
+			shipment.Price.Currency,
 			shipment.FromID,
 			shipment.ToID,
-			time.Now(),
-		).
-		RunWith(r.db.DB()).Exec()
+			now,
+		)
+	id, err := insertReturningID(db, insert)
 	if err != nil {
-		log.Println("Failed to insert shipment, err:", err.Error())
 		return err
 	}
 
+	shipment.ID = id
+	shipment.CreatedAt = now
+
+	for i := range shipment.Parcels {
+		parcel := &shipment.Parcels[i]
+		parcel.ShipmentID = id
+
+		insert := statement(db).
+			Insert("parcels").
+			Columns("shipment_id", "weight").
+			Values(parcel.ShipmentID, parcel.Weight)
+		parcel.ID, err = insertReturningID(db, insert)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachParcels sets parcels of shipments, they are retrieved by a single
+// query in order of insertion
+func attachParcels(db *gorm.DB, shipments models.Shipments) error {
+	if len(shipments) == 0 {
+		return nil
+	}
+
+	shipmentIDs := make([]int, 0, len(shipments))
+	for _, shipment := range shipments {
+		shipmentIDs = append(shipmentIDs, shipment.ID)
+	}
+
+	var parcels models.Parcels
+	err := db.
+		Table("parcels").
+		Where("parcels.shipment_id IN (?)", shipmentIDs).
+		Order("parcels.id").
+		Find(&parcels).
+		Error
+	if err != nil {
+		return err
+	}
+
+	byShipment := map[int]models.Parcels{}
+	for _, parcel := range parcels {
+		byShipment[parcel.ShipmentID] = append(byShipment[parcel.ShipmentID], parcel)
+	}
+	for i := range shipments {
+		shipments[i].Parcels = byShipment[shipments[i].ID]
+	}
 	return nil
 }
@@ -0,0 +1,431 @@
+//go:build cgo
+// +build cgo
+
+package db
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"sendify_test/shipment/errs"
+	"sendify_test/shipment/models"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// openSQLiteDB returns migrated database in temporary file, SQLite runs
+// in-process, so repos are tested against real database
+func openSQLiteDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(DialectSQLite, filepath.Join(t.TempDir(), "shipment.db")+"?_foreign_keys=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.LogMode(false)
+	db.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := NewMigrator(db.DB(), DialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestMigrator_SQLite(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLiteDB(t)
+	migrator, err := NewMigrator(db.DB(), DialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if assert.NoError(t, err) {
+		assert.Len(t, statuses, migrator.LatestVersion())
+		for _, status := range statuses {
+			assert.NotNil(t, status.AppliedAt, status.Name)
+		}
+	}
+
+	applied, err := migrator.Up(ctx)
+	if assert.NoError(t, err) {
+		assert.Empty(t, applied)
+	}
+
+	for version := migrator.LatestVersion(); version > 0; version-- {
+		migration, ok, err := migrator.Down(ctx)
+		if assert.NoError(t, err) && assert.True(t, ok) {
+			assert.Equal(t, version, migration.Version)
+		}
+	}
+	_, ok, err := migrator.Down(ctx)
+	if assert.NoError(t, err) {
+		assert.False(t, ok)
+	}
+
+	applied, err = migrator.Up(ctx)
+	if assert.NoError(t, err) {
+		assert.Len(t, applied, migrator.LatestVersion())
+	}
+}
+
+// TestMigrator_SQLiteBaseline checks database created before migrations
+// were introduced is converted by baseline instead of the first migration
+func TestMigrator_SQLiteBaseline(t *testing.T) {
+	ctx := context.Background()
+	file := func(data string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(data)} }
+	migrations, err := LoadMigrations(fstest.MapFS{
+		"0001_initial.up.sql":       file("CREATE TABLE customers (id INTEGER PRIMARY KEY, account_id INT NOT NULL);"),
+		"0001_initial.down.sql":     file("DROP TABLE customers;"),
+		"0001_initial.baseline.sql": file("ALTER TABLE customers ADD COLUMN account_id INT NOT NULL DEFAULT 1;"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := gorm.Open(DialectSQLite, filepath.Join(t.TempDir(), "baseline.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Exec("CREATE TABLE customers (id INTEGER PRIMARY KEY, name VARCHAR(30))").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("INSERT INTO customers (id, name) VALUES (1, 'Anna')").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// baseline is required to convert database
+	migrator := &Migrator{db: db.DB(), dialect: DialectSQLite, migrations: []Migration{migrations[0]}}
+	migrator.migrations[0].Baseline = ""
+	_, err = migrator.Up(ctx)
+	assert.Error(t, err)
+
+	migrator.migrations = migrations
+	applied, err := migrator.Up(ctx)
+	if assert.NoError(t, err) {
+		assert.Len(t, applied, 1)
+	}
+
+	var name string
+	var accountID int
+	err = db.DB().QueryRowContext(ctx, "SELECT name, account_id FROM customers WHERE id = 1").Scan(&name, &accountID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Anna", name)
+		assert.Equal(t, 1, accountID)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if assert.NoError(t, err) && assert.Len(t, statuses, 1) {
+		assert.NotNil(t, statuses[0].AppliedAt)
+	}
+}
+
+// TestMigrator_SQLiteParcels checks shipments stored before parcels were
+// introduced get a single parcel of their weight
+func TestMigrator_SQLiteParcels(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLiteDB(t)
+	migrator, err := NewMigrator(db.DB(), DialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrateDown(t, migrator, 2)
+	for _, statement := range []string{
+		"INSERT INTO customers (id, account_id, match_key, name) VALUES (1, 1, 'anna', 'Anna')",
+		"INSERT INTO shipments (account_id, weight, price, customer_from, customer_to) VALUES (1, 15, 300, 1, 1)",
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	shipments, err := NewShipmentsRepo(db).GetAllShipments(ctx, 1)
+	if assert.NoError(t, err) && assert.Len(t, shipments, 1) {
+		assert.Equal(t, 15, shipments[0].Weight)
+		assert.Equal(t, models.Price{Amount: 300, Currency: "SEK"}, shipments[0].Price)
+		if assert.Len(t, shipments[0].Parcels, 1) {
+			assert.Equal(t, 15, shipments[0].Parcels[0].Weight)
+		}
+	}
+}
+
+// TestMigrator_SQLiteParcelsDown checks reverting parcels restores schema
+// the previous migration left and keeps shipments
+func TestMigrator_SQLiteParcelsDown(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLiteDB(t)
+	migrator, err := NewMigrator(db.DB(), DialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	anna := models.Customer{AccountID: 1, Name: "Anna", Email: "anna@example.com", CountryCode: "SE"}
+	if err := NewCustomersRepo(db, testKeyring(t)).InsertAndReturnCustomer(ctx, &anna); err != nil {
+		t.Fatal(err)
+	}
+	shipment := models.Shipment{
+		AccountID: 1,
+		Weight:    15,
+		Parcels:   models.Parcels{{Weight: 10}, {Weight: 5}},
+		Price:     models.Price{Amount: 300, Currency: "SEK"},
+		FromID:    anna.ID,
+		ToID:      anna.ID,
+	}
+	if err := NewShipmentsRepo(db).InsertShipment(ctx, &shipment); err != nil {
+		t.Fatal(err)
+	}
+
+	migrateDown(t, migrator, 2)
+
+	// database migrated up to the previous version only
+	previous, err := gorm.Open(DialectSQLite, filepath.Join(t.TempDir(), "previous.db")+"?_foreign_keys=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer previous.Close()
+	previousMigrator, err := NewMigrator(previous.DB(), DialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousMigrator.migrations = previousMigrator.migrations[:2]
+	if _, err := previousMigrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, sqliteSchema(t, previous), sqliteSchema(t, db), "schema differs from the one of migration 2")
+
+	var weight, price int
+	err = db.DB().QueryRowContext(ctx, "SELECT weight, price FROM shipments WHERE id = ?", shipment.ID).Scan(&weight, &price)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 15, weight)
+		assert.Equal(t, 300, price)
+	}
+}
+
+// TestMigrator_SQLiteCustomerDuplicates checks customers with the same match
+// key are merged into the earliest one before match keys become unique
+func TestMigrator_SQLiteCustomerDuplicates(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLiteDB(t)
+	migrator, err := NewMigrator(db.DB(), DialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrateDown(t, migrator, 3)
+	for _, statement := range []string{
+		"INSERT INTO customers (id, account_id, match_key, name) VALUES (1, 1, 'anna', 'Anna'), (2, 1, 'anna', 'ANNA'), (3, 2, 'anna', 'Anna')",
+		"INSERT INTO shipments (id, account_id, weight, price, customer_from, customer_to) VALUES (1, 1, 10, 100, 1, 2), (2, 1, 10, 100, 2, 2)",
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []int
+	if assert.NoError(t, db.Table("customers").Order("id").Pluck("id", &ids).Error) {
+		assert.Equal(t, []int{1, 3}, ids, "customers of other accounts aren't merged")
+	}
+
+	shipments, err := NewShipmentsRepo(db).GetAllShipments(ctx, 1)
+	if assert.NoError(t, err) && assert.Len(t, shipments, 2) {
+		for _, shipment := range shipments {
+			assert.Equal(t, 1, shipment.FromID)
+			assert.Equal(t, 1, shipment.ToID)
+		}
+	}
+
+	merges, err := NewCustomersRepo(db, testKeyring(t)).GetMergesBySurvivorID(ctx, 1)
+	if assert.NoError(t, err) && assert.Len(t, merges, 1) {
+		assert.Equal(t, 2, merges[0].MergedID)
+		assert.Equal(t, 3, merges[0].ShipmentsMoved)
+	}
+
+	err = db.Exec("INSERT INTO customers (account_id, match_key, name) VALUES (1, 'anna', 'Anna')").Error
+	assert.Error(t, err, "match keys are unique")
+}
+
+// migrateDown reverts migrations of fully migrated database until version
+// is the latest applied one
+func migrateDown(t *testing.T, migrator *Migrator, version int) {
+	for latest := migrator.LatestVersion(); latest > version; latest-- {
+		migration, ok, err := migrator.Down(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || migration.Version != latest {
+			t.Fatalf("reverted migration %d, expected %d", migration.Version, latest)
+		}
+	}
+}
+
+// sqliteSchema describes columns, indexes and foreign keys of all tables,
+// unlike sqlite_master it doesn't depend on how tables were created
+func sqliteSchema(t *testing.T, db *gorm.DB) []string {
+	rows, err := db.DB().Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+
+	var schema []string
+	for _, table := range tables {
+		for _, query := range []string{
+			"SELECT name || ' ' || type || ' ' || \"notnull\" || ' ' || IFNULL(dflt_value, '') || ' ' || pk FROM pragma_table_info(?)",
+			"SELECT 'index ' || name || ' ' || \"unique\" FROM pragma_index_list(?) ORDER BY name",
+			"SELECT 'fk ' || \"from\" || ' ' || \"table\" || ' ' || \"to\" FROM pragma_foreign_key_list(?) ORDER BY \"from\"",
+		} {
+			rows, err := db.DB().Query(query, table)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for rows.Next() {
+				var description string
+				if err := rows.Scan(&description); err != nil {
+					t.Fatal(err)
+				}
+				schema = append(schema, table+": "+description)
+			}
+			rows.Close()
+		}
+	}
+	return schema
+}
+
+func TestRepositoryContract_SQLite(t *testing.T) {
+	testRepositoryContract(t, func(t *testing.T) (ShipmentRepository, CustomerRepository) {
+		db := openSQLiteDB(t)
+		return NewShipmentsRepo(db), NewCustomersRepo(db, testKeyring(t))
+	})
+}
+
+// TestCustomersRepo_SQLiteReencrypt checks pending match keys are recomputed,
+// and customer getting match key of present one is reported and kept pending
+// until it's merged
+func TestCustomersRepo_SQLiteReencrypt(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLiteDB(t)
+	customersRepo := NewCustomersRepo(db, testKeyring(t))
+	healthRepo := NewHealthRepo(db)
+
+	anna := insertCustomer(t, customersRepo, testCustomer(1, "anna"))
+	stale := insertCustomer(t, customersRepo, testCustomer(1, "erik"))
+	// the same customer stored in plaintext with match key computed by other
+	// function, e.g. before blind index was introduced
+	err := db.Table("customers").Where("id = ?", stale.ID).Updates(map[string]interface{}{
+		"name":      "Anna",
+		"email":     "anna@example.com",
+		"match_key": "stale",
+	}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// match keys of present customers are queued by migration
+	migrator, err := NewMigrator(db.DB(), DialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrateDown(t, migrator, 4)
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := healthRepo.CountPendingMatchKeys(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, pending)
+	}
+
+	updated, collisions, err := customersRepo.ReencryptCustomers(ctx)
+	if assert.NoError(t, err) && assert.Len(t, collisions, 1) {
+		assert.Equal(t, 1, updated)
+		assert.Equal(t, anna.ID, collisions[0].First.ID)
+		assert.Equal(t, stale.ID, collisions[0].Second.ID)
+		assert.Equal(t, "Anna", collisions[0].Second.Name)
+	}
+
+	pending, err = healthRepo.CountPendingMatchKeys(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, pending)
+	}
+
+	all, err := customersRepo.GetAllCustomers(ctx, 1)
+	if assert.NoError(t, err) {
+		assert.Len(t, all, 2)
+	}
+
+	if _, err := customersRepo.MergeCustomers(ctx, 1, anna.ID, []int{stale.ID}); err != nil {
+		t.Fatal(err)
+	}
+	pending, err = healthRepo.CountPendingMatchKeys(ctx)
+	if assert.NoError(t, err) {
+		assert.Zero(t, pending)
+	}
+
+	updated, collisions, err = customersRepo.ReencryptCustomers(ctx)
+	if assert.NoError(t, err) {
+		assert.Zero(t, updated)
+		assert.Empty(t, collisions)
+	}
+
+	var queued int
+	if assert.NoError(t, db.Table("customer_match_key_backfill").Count(&queued).Error) {
+		assert.Zero(t, queued)
+	}
+}
+
+// TestShipmentsRepo_SQLite checks foreign keys, which in-memory repos
+// don't have
+func TestShipmentsRepo_SQLite(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLiteDB(t)
+	customersRepo := NewCustomersRepo(db, testKeyring(t))
+	shipmentsRepo := NewShipmentsRepo(db)
+
+	anna := models.Customer{AccountID: 1, Name: "Anna", Email: "anna@example.com", CountryCode: "SE"}
+	if err := customersRepo.InsertAndReturnCustomer(ctx, &anna); err != nil {
+		t.Fatal(err)
+	}
+
+	missing := models.Shipment{AccountID: 1, Weight: 10, FromID: anna.ID, ToID: anna.ID + 100}
+	err := shipmentsRepo.InsertShipment(ctx, &missing)
+	assert.True(t, errs.Is(err, errs.Conflict), "shipment to missing customer: %v", err)
+}
+
+func TestAuditRepo_SQLite(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLiteDB(t)
+	auditRepo := NewAuditRepo(db)
+
+	entry := models.AuditEntry{AccountID: 1, Actor: "key:1", Action: "create", Entity: "shipment", EntityID: 1, CreatedAt: time.Now()}
+	if assert.NoError(t, auditRepo.InsertEntry(ctx, entry)) {
+		entries, err := auditRepo.GetEntries(ctx, 1, "shipment", 1, 10)
+		if assert.NoError(t, err) && assert.Len(t, entries, 1) {
+			assert.Equal(t, "create", entries[0].Action)
+		}
+	}
+
+	// audit log is append-only
+	assert.Error(t, db.Exec("UPDATE audit_log SET actor = 'other'").Error)
+	assert.Error(t, db.Exec("DELETE FROM audit_log").Error)
+}
@@ -0,0 +1,28 @@
+-- db/migration.sql the service was released with, applied by hand before
+-- migrations were introduced (without schema name, so it's created in the
+-- database of the test), and rows it had
+CREATE TABLE `shipments` (
+    `id` INT NOT NULL AUTO_INCREMENT,
+    `weight` INT NULL,
+    `price` INT NULL,
+    `customer_from` INT NULL,
+    `customer_to` INT NULL,
+    `created_at` DATETIME NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`));
+
+CREATE TABLE `customers` (
+    `id` INT NOT NULL AUTO_INCREMENT,
+    `name` VARCHAR(30) NULL,
+    `email` VARCHAR(255) NULL,
+    `address` VARCHAR(100) NOT NULL,
+    `country_code` VARCHAR(2) NULL,
+    `created_at` DATETIME NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE INDEX `customer` (`name`, `email`, `address`) VISIBLE,
+    PRIMARY KEY (`id`));
+
+INSERT INTO `customers` (`id`, `name`, `email`, `address`, `country_code`) VALUES
+    (1, 'Daniel Svensson', 'daniel@sendify.se', 'Drottninggatan 1, Stockholm 11151', 'SE'),
+    (2, 'Anna  Svensson', 'Anna@Sendify.se', 'Storgatan 12B, Malmo 21142', 'SE');
+
+INSERT INTO `shipments` (`id`, `weight`, `price`, `customer_from`, `customer_to`) VALUES
+    (1, 10, 1000, 1, 2);
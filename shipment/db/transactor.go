@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jinzhu/gorm"
+	"sendify_test/shipment/encryption"
+)
+
+// Transactor runs functions in DB transaction passing them repos bound to
+// the transaction, personal data is encrypted by cipher as repos outside of
+// transaction do
+type Transactor struct {
+	db     *gorm.DB
+	cipher encryption.Cipher
+}
+
+func NewTransactor(db *gorm.DB, cipher encryption.Cipher) *Transactor {
+	return &Transactor{
+		db:     db,
+		cipher: cipher,
+	}
+}
+
+// Transaction runs fn in transaction, which is committed if fn returns nil
+// and rolled back otherwise. Queries of the transaction are cancelled with ctx
+func (t Transactor) Transaction(ctx context.Context, fn func(tx Repositories) error) error {
+	return transaction(withContext(ctx, t.db, "transaction"), func(tx *gorm.DB) error {
+		return fn(Repositories{
+			Shipments:   NewShipmentsRepo(tx),
+			Customers:   NewCustomersRepo(tx, t.cipher),
+			AddressBook: NewAddressBookRepo(tx, t.cipher),
+			Audit:       NewAuditRepo(tx),
+		})
+	})
+}
+
+// transaction runs fn in transaction, or in the current one if db is
+// already bound to transaction. Unlike gorm Transaction, failure to begin
+// transaction is returned instead of running fn
+func transaction(db *gorm.DB, fn func(tx *gorm.DB) error) (err error) {
+	var conn contextDB
+	switch c := db.CommonDB().(type) {
+	case contextDB:
+		conn = c
+	case *sql.DB:
+		return db.Transaction(fn)
+	default:
+		return fn(db)
+	}
+
+	tx := db.BeginTx(conn.ctx, &sql.TxOptions{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	panicked := true
+	defer func() {
+		if panicked || err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err = fn(withContext(conn.ctx, tx, conn.operation))
+	if err == nil {
+		err = conn.wrap(tx.Commit().Error)
+	}
+
+	panicked = false
+	return err
+}
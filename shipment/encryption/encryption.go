@@ -0,0 +1,316 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// prefix marks encrypted values, values without it are treated as plaintext
+// stored before encryption was enabled
+const prefix = "enc:v1:"
+
+const keySize = 32 // AES-256
+
+// Cipher encrypts and decrypts values of DB fields
+type Cipher interface {
+	// Encrypt encrypts value of the field, field name is bound to ciphertext,
+	// so value cannot be moved to other field
+	Encrypt(field, value string) (string, error)
+	// Decrypt decrypts value of the field, plaintext values are returned as is
+	Decrypt(field, value string) (string, error)
+	// Reencrypt encrypts plaintext value or value encrypted by non active key
+	// by active one, reports whether value was changed
+	Reencrypt(field, value string) (string, bool, error)
+	// BlindIndex returns keyed hash of value to look it up by equality
+	BlindIndex(value string) string
+}
+
+// Keyring is envelope encryption Cipher: each value is encrypted by its own
+// data key (AES-256-GCM), which is encrypted (wrapped) by active master key.
+// Stored value format is "enc:v1:<master key ID>:<wrapped data key>:<ciphertext>"
+type Keyring struct {
+	activeKeyID   string
+	masterKeys    map[string]cipher.AEAD
+	blindIndexKey []byte
+}
+
+// KeyFile is format of the file with master keys, keys are base64 encoded
+// 32 bytes. Keys are rotated by adding new key and making it active, old keys
+// are kept until all values are re-encrypted
+type KeyFile struct {
+	ActiveKey     string            `json:"active_key"`
+	Keys          map[string]string `json:"keys"`
+	BlindIndexKey string            `json:"blind_index_key"`
+}
+
+// NewKeyring returns keyring using master keys by their IDs, blind index key
+// must not be changed during rotation
+func NewKeyring(activeKeyID string, masterKeys map[string][]byte, blindIndexKey []byte) (*Keyring, error) {
+	if len(blindIndexKey) < keySize {
+		return nil, fmt.Errorf("blind index key must be at least %d bytes", keySize)
+	}
+
+	keyring := &Keyring{
+		activeKeyID:   activeKeyID,
+		masterKeys:    map[string]cipher.AEAD{},
+		blindIndexKey: blindIndexKey,
+	}
+	for id, key := range masterKeys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid master key ID %q", id)
+		}
+
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid master key %q: %w", id, err)
+		}
+		keyring.masterKeys[id] = aead
+	}
+
+	if _, ok := keyring.masterKeys[activeKeyID]; !ok {
+		return nil, fmt.Errorf("active master key %q is missing", activeKeyID)
+	}
+
+	return keyring, nil
+}
+
+// LoadKeyFile returns keyring with keys from the JSON file
+func LoadKeyFile(path string) (*Keyring, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keyFile KeyFile
+	if err := json.Unmarshal(data, &keyFile); err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %w", path, err)
+	}
+
+	masterKeys := map[string][]byte{}
+	for id, encoded := range keyFile.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid master key %q: %w", id, err)
+		}
+		masterKeys[id] = key
+	}
+
+	blindIndexKey, err := base64.StdEncoding.DecodeString(keyFile.BlindIndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blind index key: %w", err)
+	}
+
+	return NewKeyring(keyFile.ActiveKey, masterKeys, blindIndexKey)
+}
+
+// ParseMasterKeys returns keyring with master keys specified as comma
+// separated "<ID>:<base64 key>" list, the first key is active one
+func ParseMasterKeys(masterKeys, blindIndexKey string) (*Keyring, error) {
+	keys := map[string][]byte{}
+	var activeKeyID string
+	for _, spec := range strings.Split(masterKeys, ",") {
+		parts := strings.SplitN(strings.TrimSpace(spec), ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.New(`master key must be specified as "<ID>:<base64 key>"`)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid master key %q: %w", parts[0], err)
+		}
+		keys[parts[0]] = key
+
+		if activeKeyID == "" {
+			activeKeyID = parts[0]
+		}
+	}
+
+	indexKey, err := base64.StdEncoding.DecodeString(blindIndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blind index key: %w", err)
+	}
+
+	return NewKeyring(activeKeyID, keys, indexKey)
+}
+
+func (k *Keyring) Encrypt(field, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	wrappedKey, err := seal(k.masterKeys[k.activeKeyID], dataKey, []byte(field))
+	if err != nil {
+		return "", err
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := seal(dataAEAD, []byte(value), []byte(field))
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + k.activeKeyID + ":" +
+		base64.RawStdEncoding.EncodeToString(wrappedKey) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+func (k *Keyring) Decrypt(field, value string) (string, error) {
+	if !strings.HasPrefix(value, prefix) {
+		return value, nil
+	}
+
+	keyID, dataKey, ciphertext, err := k.unwrap(field, value)
+	if err != nil {
+		return "", err
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := open(dataAEAD, ciphertext, []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s encrypted by master key %q: %w", field, keyID, err)
+	}
+
+	return string(plaintext), nil
+}
+
+func (k *Keyring) Reencrypt(field, value string) (string, bool, error) {
+	if value == "" {
+		return value, false, nil
+	}
+	if !strings.HasPrefix(value, prefix) {
+		encrypted, err := k.Encrypt(field, value)
+		return encrypted, err == nil, err
+	}
+
+	keyID, dataKey, ciphertext, err := k.unwrap(field, value)
+	if err != nil {
+		return "", false, err
+	}
+	if keyID == k.activeKeyID {
+		return value, false, nil
+	}
+
+	// only data key is re-wrapped, encrypted value itself is kept
+	wrappedKey, err := seal(k.masterKeys[k.activeKeyID], dataKey, []byte(field))
+	if err != nil {
+		return "", false, err
+	}
+
+	return prefix + k.activeKeyID + ":" +
+		base64.RawStdEncoding.EncodeToString(wrappedKey) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext), true, nil
+}
+
+func (k *Keyring) BlindIndex(value string) string {
+	mac := hmac.New(sha256.New, k.blindIndexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// unwrap parses encrypted value and decrypts its data key
+func (k *Keyring) unwrap(field, value string) (keyID string, dataKey, ciphertext []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, fmt.Errorf("malformed encrypted value of %s", field)
+	}
+
+	keyID = parts[0]
+	masterKey, ok := k.masterKeys[keyID]
+	if !ok {
+		return "", nil, nil, fmt.Errorf("unknown master key %q of %s", keyID, field)
+	}
+
+	wrappedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("malformed data key of %s: %w", field, err)
+	}
+
+	ciphertext, err = base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("malformed ciphertext of %s: %w", field, err)
+	}
+
+	dataKey, err = open(masterKey, wrappedKey, []byte(field))
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to unwrap data key of %s by master key %q: %w", field, keyID, err)
+	}
+
+	return keyID, dataKey, ciphertext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("key must be %d bytes", keySize)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext and prepends random nonce to it
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts ciphertext produced by seal
+func open(aead cipher.AEAD, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+// Plaintext is Cipher keeping values unencrypted, used in tests and when
+// encryption is disabled explicitly. Blind index is unkeyed SHA-256
+type Plaintext struct{}
+
+func (Plaintext) Encrypt(_, value string) (string, error) {
+	return value, nil
+}
+
+func (Plaintext) Decrypt(field, value string) (string, error) {
+	if strings.HasPrefix(value, prefix) {
+		return "", fmt.Errorf("%s is encrypted, but no keys are configured", field)
+	}
+	return value, nil
+}
+
+func (Plaintext) Reencrypt(_, value string) (string, bool, error) {
+	return value, false, nil
+}
+
+func (Plaintext) BlindIndex(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,147 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, keySize)
+}
+
+func testKeyring(t *testing.T, activeKeyID string, keyIDs ...string) *Keyring {
+	keys := map[string][]byte{}
+	for i, id := range keyIDs {
+		keys[id] = testKey(byte(i + 1))
+	}
+
+	keyring, err := NewKeyring(activeKeyID, keys, testKey(0xff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyring
+}
+
+func TestKeyring_EncryptDecrypt(t *testing.T) {
+	keyring := testKeyring(t, "k1", "k1")
+
+	encrypted, err := keyring.Encrypt("customers.name", "Daniel Åberg")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, strings.HasPrefix(encrypted, "enc:v1:k1:"))
+	assert.NotContains(t, encrypted, "Daniel")
+
+	again, err := keyring.Encrypt("customers.name", "Daniel Åberg")
+	if assert.NoError(t, err) {
+		assert.NotEqual(t, encrypted, again, "each value must have its own data key and nonce")
+	}
+
+	decrypted, err := keyring.Decrypt("customers.name", encrypted)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Daniel Åberg", decrypted)
+	}
+
+	// value can't be moved to another field
+	_, err = keyring.Decrypt("customers.email", encrypted)
+	assert.Error(t, err)
+
+	// values stored before encryption was enabled are returned as is
+	decrypted, err = keyring.Decrypt("customers.name", "Daniel")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Daniel", decrypted)
+	}
+
+	encrypted, err = keyring.Encrypt("customers.phone", "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "", encrypted)
+	}
+}
+
+func TestKeyring_Reencrypt(t *testing.T) {
+	oldKeyring := testKeyring(t, "k1", "k1")
+	encrypted, err := oldKeyring.Encrypt("customers.email", "daniel@sendify.se")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	keyring := testKeyring(t, "k2", "k1", "k2")
+
+	reencrypted, changed, err := keyring.Reencrypt("customers.email", encrypted)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, changed)
+	assert.True(t, strings.HasPrefix(reencrypted, "enc:v1:k2:"))
+
+	// value is readable without old key
+	newKeyring, err := NewKeyring("k2", map[string][]byte{"k2": testKey(2)}, testKey(0xff))
+	if !assert.NoError(t, err) {
+		return
+	}
+	decrypted, err := newKeyring.Decrypt("customers.email", reencrypted)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "daniel@sendify.se", decrypted)
+	}
+	_, err = newKeyring.Decrypt("customers.email", encrypted)
+	assert.Error(t, err)
+
+	_, changed, err = keyring.Reencrypt("customers.email", reencrypted)
+	if assert.NoError(t, err) {
+		assert.False(t, changed)
+	}
+
+	reencrypted, changed, err = keyring.Reencrypt("customers.email", "daniel@sendify.se")
+	if assert.NoError(t, err) {
+		assert.True(t, changed)
+		assert.True(t, strings.HasPrefix(reencrypted, "enc:v1:k2:"))
+	}
+}
+
+func TestKeyring_BlindIndex(t *testing.T) {
+	keyring := testKeyring(t, "k1", "k1")
+	rotated := testKeyring(t, "k2", "k1", "k2")
+
+	assert.Equal(t, keyring.BlindIndex("daniel"), keyring.BlindIndex("daniel"))
+	assert.Equal(t, keyring.BlindIndex("daniel"), rotated.BlindIndex("daniel"))
+	assert.NotEqual(t, keyring.BlindIndex("daniel"), keyring.BlindIndex("daniel2"))
+	assert.NotEqual(t, Plaintext{}.BlindIndex("daniel"), keyring.BlindIndex("daniel"))
+	assert.Len(t, keyring.BlindIndex("daniel"), 64)
+}
+
+func TestParseMasterKeys(t *testing.T) {
+	k1 := base64.StdEncoding.EncodeToString(testKey(1))
+	k2 := base64.StdEncoding.EncodeToString(testKey(2))
+	blindIndexKey := base64.StdEncoding.EncodeToString(testKey(0xff))
+
+	keyring, err := ParseMasterKeys("k2:"+k2+", k1:"+k1, blindIndexKey)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "k2", keyring.activeKeyID)
+		assert.Len(t, keyring.masterKeys, 2)
+	}
+
+	_, err = ParseMasterKeys("k1", blindIndexKey)
+	assert.Error(t, err)
+
+	_, err = ParseMasterKeys("k1:"+base64.StdEncoding.EncodeToString([]byte("short")), blindIndexKey)
+	assert.Error(t, err)
+
+	_, err = ParseMasterKeys("k1:"+k1, "")
+	assert.Error(t, err)
+}
+
+func TestPlaintext(t *testing.T) {
+	encrypted, err := Plaintext{}.Encrypt("customers.name", "Daniel")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Daniel", encrypted)
+	}
+
+	encrypted, err = testKeyring(t, "k1", "k1").Encrypt("customers.name", "Daniel")
+	if assert.NoError(t, err) {
+		_, err = Plaintext{}.Decrypt("customers.name", encrypted)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,96 @@
+// Package errs defines domain errors. Repos translate DB errors into them,
+// so services don't depend on DB errors, and controllers map their kinds to
+// HTTP statuses
+package errs
+
+import "errors"
+
+// Kind classifies domain errors
+type Kind int
+
+const (
+	// Internal errors are unexpected ones, their details aren't exposed to
+	// clients
+	Internal Kind = iota
+	// NotFound errors are returned when entity is missing, or belongs to
+	// other account
+	NotFound
+	// Conflict errors are returned when operation conflicts with current
+	// state, e.g. record is duplicate or is still referenced
+	Conflict
+	// Validation errors are returned when request is well-formed, but can't
+	// be processed, e.g. it refers to missing entity
+	Validation
+	// Unavailable errors are returned when dependency, e.g. database, is
+	// unreachable, request may be retried later
+	Unavailable
+)
+
+func (k Kind) String() string {
+	switch k {
+	case NotFound:
+		return "not_found"
+	case Conflict:
+		return "conflict"
+	case Validation:
+		return "validation"
+	case Unavailable:
+		return "unavailable"
+	}
+	return "internal"
+}
+
+// Error is domain error, Message is safe to show to clients, while Err is
+// cause of the error which is logged only
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+}
+
+// New returns error of the kind
+func New(kind Kind, message string) error {
+	return &Error{Kind: kind, Message: message}
+}
+
+// Wrap returns error of the kind caused by err
+func Wrap(kind Kind, message string, err error) error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// KindOf returns kind of the outermost domain error in chain of err,
+// Internal if there is none
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return Internal
+}
+
+// Is reports whether err is domain error of the kind
+func Is(err error, kind Kind) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Kind == kind
+}
+
+// Message returns message of the outermost domain error in chain of err,
+// which is safe to show to clients, empty if there is none
+func Message(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Message
+	}
+	return ""
+}
@@ -0,0 +1,29 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestError(t *testing.T) {
+	cause := errors.New("Error 1062: Duplicate entry 'a' for key 'name'")
+	err := fmt.Errorf("failed to save: %w", Wrap(Conflict, "record already exists", cause))
+
+	assert.Equal(t, Conflict, KindOf(err))
+	assert.True(t, Is(err, Conflict))
+	assert.False(t, Is(err, NotFound))
+	assert.ErrorIs(t, err, cause)
+	assert.Equal(t, "record already exists", Message(err))
+	assert.Equal(t, "failed to save: record already exists: Error 1062: Duplicate entry 'a' for key 'name'", err.Error())
+
+	err = New(NotFound, "shipment not found")
+	assert.Equal(t, NotFound, KindOf(err))
+	assert.Equal(t, "shipment not found", err.Error())
+
+	assert.Equal(t, Internal, KindOf(cause))
+	assert.False(t, Is(nil, Internal))
+	assert.Empty(t, Message(cause))
+	assert.Equal(t, "internal", KindOf(cause).String())
+}
@@ -0,0 +1,53 @@
+// Package httputil contains helpers shared by HTTP middlewares
+package httputil
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// StatusRecorder keeps status of response written by handler. It forwards
+// Flush and Hijack to the wrapped writer, so streaming responses and
+// connection upgrades keep working behind middlewares
+type StatusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// NewStatusRecorder wraps w, status is 200 OK until handler writes other one
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+// Status returns status of the response
+func (r *StatusRecorder) Status() int {
+	return r.status
+}
+
+func (r *StatusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush sends buffered data to the client if the wrapped writer supports it
+func (r *StatusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack takes over the connection if the wrapped writer supports it
+func (r *StatusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T doesn't support hijacking", r.ResponseWriter)
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the wrapped writer, it's used by http.ResponseController
+func (r *StatusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,43 @@
+package httputil
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatusRecorder(t *testing.T) {
+	w := httptest.NewRecorder()
+	recorder := NewStatusRecorder(w)
+	assert.Equal(t, http.StatusOK, recorder.Status())
+
+	recorder.WriteHeader(http.StatusCreated)
+	recorder.Flush()
+	assert.Equal(t, http.StatusCreated, recorder.Status())
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.True(t, w.Flushed)
+
+	// httptest.ResponseRecorder can't be hijacked
+	_, _, err := recorder.Hijack()
+	assert.Error(t, err)
+}
+
+func TestStatusRecorder_Hijack(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := NewStatusRecorder(w).Hijack()
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 204 No Content\r\nConnection: close\r\n\r\n")
+		buf.Flush()
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jinzhu/gorm"
+	"os"
+	"sendify_test/shipment/auth"
+	repo "sendify_test/shipment/db"
+	"sendify_test/shipment/logging"
+	"sendify_test/shipment/models"
+	"sendify_test/shipment/processing"
+	"strconv"
+)
+
+// migrate applies pending migrations (up), reverts the latest applied one
+// (down) or prints status of migrations, one JSON object per line (status)
+func migrate(ctx context.Context, db *gorm.DB, args []string) {
+	logger := logging.FromContext(ctx)
+
+	if len(args) != 1 {
+		logger.Fatal("Usage: migrate <up|down|status>")
+	}
+
+	migrator := newMigrator(ctx, db)
+	switch args[0] {
+	case "up":
+		migrateUp(ctx, migrator)
+	case "down":
+		migration, ok, err := migrator.Down(ctx)
+		if err != nil {
+			logger.Fatal("Failed to revert migration", "error", err)
+		}
+		if !ok {
+			logger.Info("No migrations to revert")
+			return
+		}
+		logger.Info("Reverted migration", "version", migration.Version, "name", migration.Name)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			logger.Fatal("Failed to get status of migrations", "error", err)
+		}
+		for _, status := range statuses {
+			printJSON(map[string]interface{}{
+				"version":    status.Version,
+				"name":       status.Name,
+				"applied_at": status.AppliedAt,
+			})
+		}
+	default:
+		logger.Fatal("Usage: migrate <up|down|status>")
+	}
+}
+
+// migrateUp applies pending migrations
+func migrateUp(ctx context.Context, migrator *repo.Migrator) {
+	logger := logging.FromContext(ctx)
+
+	applied, err := migrator.Up(ctx)
+	for _, migration := range applied {
+		logger.Info("Applied migration", "version", migration.Version, "name", migration.Name)
+	}
+	if err != nil {
+		logger.Fatal("Failed to apply migrations", "error", err)
+	}
+
+	logger.Info("Database schema is up to date", "version", migrator.LatestVersion())
+}
+
+func newMigrator(ctx context.Context, db *gorm.DB) *repo.Migrator {
+	migrator, err := repo.NewMigrator(db.DB(), db.Dialect().GetName())
+	if err != nil {
+		logging.FromContext(ctx).Fatal("Failed to load migrations", "error", err)
+	}
+	return migrator
+}
+
+// findDuplicates prints candidate pairs of duplicate customers of each
+// account to stdout, one JSON object per line
+func findDuplicates(ctx context.Context, processingService processing.Service) {
+	logger := logging.FromContext(ctx)
+
+	accounts, err := processingService.GetAllAccounts(ctx)
+	if err != nil {
+		logger.Fatal("Failed to get accounts", "error", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	var found int
+	for _, account := range accounts {
+		candidates, err := processingService.FindDuplicateCustomers(ctx, account.ID)
+		if err != nil {
+			logger.Fatal("Failed to find duplicate customers", "account_id", account.ID, "error", err)
+		}
+
+		for _, candidate := range candidates {
+			if err := encoder.Encode(candidate); err != nil {
+				logger.Fatal("Failed to print duplicate customers", "error", err)
+			}
+		}
+		found += len(candidates)
+	}
+
+	logger.Info("Found duplicate candidates", "candidates", found, "accounts", len(accounts))
+}
+
+// applyRetention pseudonymises customers without shipments for
+// RETENTION_YEARS years
+func applyRetention(ctx context.Context, cfg *Config, processingService processing.Service) {
+	logger := logging.FromContext(ctx)
+
+	erased, err := processingService.ApplyRetentionPolicy(ctx, cfg.RetentionYears)
+	if err != nil {
+		logger.Fatal("Failed to apply retention policy", "erased", erased, "error", err)
+	}
+
+	logger.Info("Erased customers without recent shipments", "erased", erased, "years", cfg.RetentionYears)
+}
+
+// rotateKeys re-encrypts personal data by the active master key, should be
+// run after new key is made active and before old keys are removed. Pairs of
+// customers getting the same match key are printed to stdout, one JSON
+// object per line, to be merged by merge-customers
+func rotateKeys(ctx context.Context, processingService processing.Service) {
+	logger := logging.FromContext(ctx)
+
+	customers, addresses, collisions, err := processingService.ReencryptPersonalData(ctx)
+	if err != nil {
+		logger.Fatal("Failed to re-encrypt personal data",
+			"customers", customers, "addresses", addresses, "error", err)
+	}
+
+	for _, collision := range collisions {
+		printJSON(collision)
+	}
+	if len(collisions) > 0 {
+		logger.Warn("Customers getting match keys of present ones are kept pending, "+
+			"merge them by merge-customers and run rotate-keys again", "collisions", len(collisions))
+	}
+
+	logger.Info("Re-encrypted personal data", "customers", customers, "addresses", addresses)
+}
+
+// mergeCustomers merges duplicate customers of the account into surviving
+// one and prints merge records, one JSON object per line. Merges are audited
+// as done by the job
+func mergeCustomers(ctx context.Context, processingService processing.Service, args []string) {
+	logger := logging.FromContext(ctx)
+
+	if len(args) < 3 {
+		logger.Fatal("Usage: merge-customers <account ID> <survivor ID> <duplicate IDs...>")
+	}
+
+	ids := make([]int, len(args))
+	for i, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			logger.Fatal("Invalid ID", "id", arg, "error", err)
+		}
+		ids[i] = id
+	}
+
+	request := models.MergeRequest{SurvivorID: ids[1], DuplicateIDs: ids[2:]}
+	if err := request.Validate(); err != nil {
+		logger.Fatal("Invalid merge request", "error", err)
+	}
+
+	merges, err := processingService.MergeCustomers(ctx, models.SystemActor("merge-customers"), ids[0], request)
+	if err != nil {
+		logger.Fatal("Failed to merge customers", "error", err)
+	}
+
+	for _, merge := range merges {
+		printJSON(merge)
+	}
+	logger.Info("Merged customers", "account_id", ids[0], "survivor_id", request.SurvivorID, "merged", len(merges))
+}
+
+// createAccount creates account with the name and prints its ID and API key,
+// the key is shown only once
+func createAccount(ctx context.Context, processingService processing.Service, args []string) {
+	logger := logging.FromContext(ctx)
+
+	if len(args) != 1 {
+		logger.Fatal("Usage: create-account <name>")
+	}
+
+	account, apiKey, key, err := processingService.CreateAccount(ctx, args[0])
+	if err != nil {
+		logger.Fatal("Failed to create account", "error", err)
+	}
+
+	printJSON(map[string]interface{}{
+		"account": account,
+		"api_key": apiKey,
+		"key":     key,
+	})
+}
+
+// createAPIKey issues new API key of the account and prints it, the key is
+// shown only once
+func createAPIKey(ctx context.Context, processingService processing.Service, args []string) {
+	logger := logging.FromContext(ctx)
+
+	if len(args) < 1 || len(args) > 3 {
+		logger.Fatal("Usage: create-api-key <account ID> [name] [viewer|booker|admin]")
+	}
+
+	accountID, err := strconv.Atoi(args[0])
+	if err != nil {
+		logger.Fatal("Invalid account ID", "error", err)
+	}
+
+	name, role := "default", auth.RoleBooker
+	if len(args) > 1 {
+		name = args[1]
+	}
+	if len(args) > 2 {
+		role = auth.Role(args[2])
+	}
+
+	apiKey, key, err := processingService.CreateAPIKey(ctx, accountID, name, role)
+	if err != nil {
+		logger.Fatal("Failed to create API key", "error", err)
+	}
+
+	printJSON(map[string]interface{}{
+		"api_key": apiKey,
+		"key":     key,
+	})
+}
+
+// revokeAPIKey revokes API key by its ID
+func revokeAPIKey(ctx context.Context, processingService processing.Service, args []string) {
+	logger := logging.FromContext(ctx)
+
+	if len(args) != 1 {
+		logger.Fatal("Usage: revoke-api-key <API key ID>")
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		logger.Fatal("Invalid API key ID", "error", err)
+	}
+
+	if err := processingService.RevokeAPIKey(ctx, id); err != nil {
+		logger.Fatal("Failed to revoke API key", "error", err)
+	}
+
+	logger.Info("Revoked API key", "api_key_id", id)
+}
+
+func printJSON(value interface{}) {
+	if err := json.NewEncoder(os.Stdout).Encode(value); err != nil {
+		logging.Default().Fatal("Failed to print result", "error", err)
+	}
+}
@@ -0,0 +1,36 @@
+package logging
+
+import "context"
+
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	requestIDKey
+)
+
+// NewContext returns context carrying the logger
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// FromContext returns logger of the context, default one if there is none
+func FromContext(ctx context.Context) *Logger {
+	if logger, ok := ctx.Value(loggerKey).(*Logger); ok {
+		return logger
+	}
+	return Default()
+}
+
+// With returns context which logger adds key-value pairs to each entry,
+// e.g. ID of processed shipment
+func With(ctx context.Context, keyvals ...interface{}) context.Context {
+	return NewContext(ctx, FromContext(ctx).With(keyvals...))
+}
+
+// RequestID returns ID of request the context belongs to, empty out of
+// request context
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
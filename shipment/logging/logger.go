@@ -0,0 +1,146 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level of log entry, entries below logger's level are dropped
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// ParseLevel returns level by its name, e.g. "info"
+func ParseLevel(name string) (Level, error) {
+	for level, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q, expected one of debug, info, warn, error", name)
+}
+
+// Logger writes entries as JSON objects, one per line, with fields of the
+// logger and key-value pairs of the entry
+type Logger struct {
+	out    io.Writer
+	mu     *sync.Mutex
+	level  Level
+	fields []interface{}
+	now    func() time.Time
+}
+
+// New returns logger writing entries of the level and above to out
+func New(out io.Writer, level Level) *Logger {
+	return &Logger{
+		out:   out,
+		mu:    &sync.Mutex{},
+		level: level,
+		now:   time.Now,
+	}
+}
+
+var defaultLogger = New(os.Stderr, LevelInfo)
+
+// Default returns logger used out of request context
+func Default() *Logger {
+	return defaultLogger
+}
+
+// SetDefault replaces logger used out of request context
+func SetDefault(logger *Logger) {
+	defaultLogger = logger
+}
+
+// With returns logger adding key-value pairs to each entry
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	child := *l
+	child.fields = append(append([]interface{}{}, l.fields...), keyvals...)
+	return &child
+}
+
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	l.log(LevelDebug, msg, keyvals)
+}
+
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.log(LevelInfo, msg, keyvals)
+}
+
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	l.log(LevelWarn, msg, keyvals)
+}
+
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	l.log(LevelError, msg, keyvals)
+}
+
+// Fatal logs entry with error level and exits the process
+func (l *Logger) Fatal(msg string, keyvals ...interface{}) {
+	l.log(LevelError, msg, keyvals)
+	os.Exit(1)
+}
+
+func (l *Logger) log(level Level, msg string, keyvals []interface{}) {
+	if level < l.level {
+		return
+	}
+
+	entry := map[string]interface{}{}
+	addFields(entry, l.fields)
+	addFields(entry, keyvals)
+	entry["time"] = l.now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["msg"] = msg
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		line, _ = json.Marshal(map[string]interface{}{
+			"time":  entry["time"],
+			"level": entry["level"],
+			"msg":   msg,
+			"error": "failed to marshal log entry: " + err.Error(),
+		})
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(append(line, '\n'))
+}
+
+// addFields adds key-value pairs to entry, errors are written as their
+// messages, key without value gets "!MISSING" one
+func addFields(entry map[string]interface{}, keyvals []interface{}) {
+	for i := 0; i < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		var value interface{} = "!MISSING"
+		if i+1 < len(keyvals) {
+			value = keyvals[i+1]
+		}
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		entry[key] = value
+	}
+}
@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLogger(level Level) (*Logger, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	logger := New(out, level)
+	logger.now = func() time.Time { return time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC) }
+	return logger, out
+}
+
+// entries parses logged JSON lines
+func entries(t *testing.T, out *bytes.Buffer) []map[string]interface{} {
+	var result []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+func TestLogger(t *testing.T) {
+	logger, out := newTestLogger(LevelInfo)
+
+	logger.Debug("Dropped")
+	logger.With("request_id", "abc").Error("Failed to get shipment", "shipment_id", 42, "error", errors.New("boom"), "odd")
+
+	assert.Equal(t, []map[string]interface{}{
+		{
+			"time":        "2021-03-01T12:00:00Z",
+			"level":       "error",
+			"msg":         "Failed to get shipment",
+			"request_id":  "abc",
+			"shipment_id": float64(42),
+			"error":       "boom",
+			"odd":         "!MISSING",
+		},
+	}, entries(t, out))
+
+	level, err := ParseLevel("WARN")
+	if assert.NoError(t, err) {
+		assert.Equal(t, LevelWarn, level)
+	}
+	_, err = ParseLevel("verbose")
+	assert.Error(t, err)
+}
+
+func TestMiddleware(t *testing.T) {
+	logger, out := newTestLogger(LevelInfo)
+
+	router := mux.NewRouter()
+	router.Use(Middleware(logger))
+	router.HandleFunc("/shipment/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		ctx := With(r.Context(), "shipment_id", 1)
+		FromContext(ctx).Warn("Shipment not found")
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/shipment/1", nil)
+	r.Header.Set(RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, "req-1", w.Header().Get(RequestIDHeader))
+	logged := entries(t, out)
+	if assert.Len(t, logged, 2) {
+		assert.Equal(t, "Shipment not found", logged[0]["msg"])
+		assert.Equal(t, "req-1", logged[0]["request_id"])
+		assert.Equal(t, "/shipment/{id:[0-9]+}", logged[0]["route"])
+		assert.Equal(t, float64(1), logged[0]["shipment_id"])
+
+		assert.Equal(t, "Request completed", logged[1]["msg"])
+		assert.Equal(t, float64(http.StatusNotFound), logged[1]["status"])
+		assert.NotContains(t, logged[1], "shipment_id")
+	}
+
+	// request ID is generated if missing
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/shipment/1", nil))
+	assert.Len(t, w.Header().Get(RequestIDHeader), 32)
+}
@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/gorilla/mux"
+	"net/http"
+	"sendify_test/shipment/httputil"
+	"time"
+)
+
+// RequestIDHeader carries ID of request, it's generated unless passed by
+// client or proxy and is returned in response
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength limits length of request ID passed by client
+const maxRequestIDLength = 128
+
+// Middleware stores logger with request ID, method and route template in
+// request context and logs completed requests. Should be used on mux router,
+// so route is matched
+func Middleware(logger *Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			requestID := r.Header.Get(RequestIDHeader)
+			if requestID == "" || len(requestID) > maxRequestIDLength {
+				requestID = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+
+			route := r.URL.Path
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, err := current.GetPathTemplate(); err == nil {
+					route = template
+				}
+			}
+
+			requestLogger := logger.With("request_id", requestID, "method", r.Method, "route", route)
+			ctx := context.WithValue(r.Context(), requestIDKey, requestID)
+			ctx = NewContext(ctx, requestLogger)
+
+			recorder := httputil.NewStatusRecorder(w)
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+
+			requestLogger.Info("Request completed",
+				"status", recorder.Status(),
+				"duration_ms", float64(time.Since(start).Microseconds())/1000)
+		})
+	}
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
@@ -1,21 +1,84 @@
 package main
 
 import (
-	"github.com/gorilla/mux"
+	"context"
+	"errors"
+	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/mysql"
-	"log"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sendify_test/shipment/auth"
 	"sendify_test/shipment/controller"
 	repo "sendify_test/shipment/db"
+	"sendify_test/shipment/encryption"
+	"sendify_test/shipment/logging"
+	"sendify_test/shipment/metrics"
 	"sendify_test/shipment/models"
+	"sendify_test/shipment/openapi"
 	"sendify_test/shipment/processing"
+	"sendify_test/shipment/ratelimit"
+	"sendify_test/shipment/tracing"
+	"sendify_test/shipment/validation"
+	"syscall"
+	"time"
 )
 
 type Config struct {
 	ServiceName  string `env:"SERVICE_NAME,required"`
+	LogLevel     string `env:"LOG_LEVEL" envDefault:"info"`
 	Port         int    `env:"PORT" envDefault:"8090"`
+	DBDriver     string `env:"DB_DRIVER" envDefault:"mysql"`
 	DBConnection string `env:"DB_CONNECTION_STRING,required"`
+
+	// QueryTimeouts override QueryTimeout per repo method, e.g.
+	// "shipments.GetAllShipments=10s"
+	QueryTimeout  time.Duration `env:"DB_QUERY_TIMEOUT" envDefault:"5s"`
+	QueryTimeouts []string      `env:"DB_QUERY_TIMEOUTS" envSeparator:","`
+
+	// AutoMigrate applies pending migrations before serving requests
+	AutoMigrate bool `env:"DB_AUTO_MIGRATE"`
+
+	ReadHeaderTimeout time.Duration `env:"HTTP_READ_HEADER_TIMEOUT" envDefault:"5s"`
+	ReadTimeout       time.Duration `env:"HTTP_READ_TIMEOUT" envDefault:"15s"`
+	WriteTimeout      time.Duration `env:"HTTP_WRITE_TIMEOUT" envDefault:"30s"`
+	IdleTimeout       time.Duration `env:"HTTP_IDLE_TIMEOUT" envDefault:"60s"`
+	ShutdownTimeout   time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"30s"`
+
+	ValidationRulesFile string   `env:"VALIDATION_RULES_FILE"`
+	BlockedEmailDomains []string `env:"BLOCKED_EMAIL_DOMAINS" envSeparator:","`
+
+	RetentionYears int `env:"RETENTION_YEARS" envDefault:"5"`
+
+	JWKSURL         string `env:"JWKS_URL"`
+	JWTIssuer       string `env:"JWT_ISSUER"`
+	JWTAudience     string `env:"JWT_AUDIENCE"`
+	JWTRolesClaim   string `env:"JWT_ROLES_CLAIM" envDefault:"roles"`
+	JWTAccountClaim string `env:"JWT_ACCOUNT_CLAIM" envDefault:"account_id"`
+
+	RateLimitDefault  string   `env:"RATE_LIMIT_DEFAULT" envDefault:"120/m"`
+	RateLimitRoutes   []string `env:"RATE_LIMIT_ROUTES" envSeparator:","`
+	RateLimitIP       string   `env:"RATE_LIMIT_IP" envDefault:"600/m"`
+	TrustForwardedFor bool     `env:"TRUST_FORWARDED_FOR"`
+
+	OpenAPIValidateRequests bool `env:"OPENAPI_VALIDATE_REQUESTS"`
+
+	// UnversionedAPISunset is the date, YYYY-MM-DD, after which unversioned
+	// routes may be removed, it is not announced if empty
+	UnversionedAPISunset string `env:"UNVERSIONED_API_SUNSET" envDefault:"2027-04-19"`
+
+	TracingExporter    string  `env:"TRACING_EXPORTER" envDefault:"none"`
+	TracingSampleRatio float64 `env:"TRACING_SAMPLE_RATIO" envDefault:"1"`
+
+	PIIKeyFile       string `env:"PII_KEY_FILE"`
+	PIIMasterKeys    string `env:"PII_MASTER_KEYS"`
+	PIIBlindIndexKey string `env:"PII_BLIND_INDEX_KEY"`
+	// EncryptionDisabled allows to store personal data unencrypted when no
+	// keys are configured, e.g. in local development
+	EncryptionDisabled bool `env:"ENCRYPTION_DISABLED"`
 }
 
 func main() {
@@ -23,27 +86,262 @@ func main() {
 
 	models.LoadEnv(cfg)
 
-	db := models.InitGormConnection(cfg.DBConnection)
+	logLevel, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		logging.Default().Fatal("Invalid LOG_LEVEL", "error", err)
+	}
+	logging.SetDefault(logging.New(os.Stderr, logLevel).With("service", cfg.ServiceName))
+
+	if cfg.ValidationRulesFile != "" {
+		rules, err := validation.LoadRules(cfg.ValidationRulesFile)
+		if err != nil {
+			logging.Default().Fatal("Failed to load validation rules", "error", err)
+		}
+		validation.SetRules(rules)
+	}
+
+	if len(cfg.BlockedEmailDomains) > 0 {
+		validation.SetDomainChecker(validation.NewMemoryDomainChecker(cfg.BlockedEmailDomains...))
+	}
+
+	queryTimeouts, err := repo.ParseQueryTimeouts(cfg.QueryTimeouts)
+	if err != nil {
+		logging.Default().Fatal("Invalid DB_QUERY_TIMEOUTS", "error", err)
+	}
+	repo.SetQueryTimeouts(repo.QueryTimeouts{Default: cfg.QueryTimeout, Operations: queryTimeouts})
+
+	cipher := newCipher(cfg)
 
-	router := mux.NewRouter()
+	dialect, err := repo.ParseDialect(cfg.DBDriver)
+	if err != nil {
+		logging.Default().Fatal("Invalid DB_DRIVER", "error", err)
+	}
+	db := models.InitGormConnection(dialect, cfg.DBConnection)
 
 	// init repo services
 	shipmentsRepo := repo.NewShipmentsRepo(db)
-	customersRepo := repo.NewCustomersRepo(db)
+	customersRepo := repo.NewCustomersRepo(db, cipher)
+	addressBookRepo := repo.NewAddressBookRepo(db, cipher)
+	accountsRepo := repo.NewAccountsRepo(db)
+	auditRepo := repo.NewAuditRepo(db)
 
 	// init shipment
-	processingService := processing.NewService(shipmentsRepo, customersRepo)
-	apiController := controller.NewApiController(processingService)
+	processingService := processing.NewService(
+		repo.NewTransactor(db, cipher),
+		shipmentsRepo,
+		customersRepo,
+		addressBookRepo,
+		accountsRepo,
+		auditRepo,
+		repo.NewHealthRepo(db),
+	)
+
+	command, args := "serve", []string(nil)
+	if len(os.Args) > 1 {
+		command, args = os.Args[1], os.Args[2:]
+	}
+
+	ctx := logging.NewContext(context.Background(), logging.Default().With("command", command))
+
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		ServiceName: cfg.ServiceName,
+		Exporter:    cfg.TracingExporter,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		logging.Default().Fatal("Failed to set up tracing", "error", err)
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			logging.Default().Warn("Failed to flush spans", "error", err)
+		}
+	}()
+
+	switch command {
+	case "serve":
+		if cfg.AutoMigrate {
+			migrateUp(ctx, newMigrator(ctx, db))
+		}
+		// other failures of readiness are reported by /readyz
+		if err := processingService.CheckReadiness(ctx); errors.Is(err, processing.ErrMatchKeysPending) {
+			logging.FromContext(ctx).Fatal("Match keys of customers are pending, run rotate-keys before serve")
+		}
+		serve(ctx, cfg, processingService, db)
+	case "migrate":
+		migrate(ctx, db, args)
+	case "find-duplicates":
+		findDuplicates(ctx, processingService)
+	case "apply-retention":
+		applyRetention(ctx, cfg, processingService)
+	case "rotate-keys":
+		rotateKeys(ctx, processingService)
+	case "merge-customers":
+		mergeCustomers(ctx, processingService, args)
+	case "create-account":
+		createAccount(ctx, processingService, args)
+	case "create-api-key":
+		createAPIKey(ctx, processingService, args)
+	case "revoke-api-key":
+		revokeAPIKey(ctx, processingService, args)
+	default:
+		logging.Default().Fatal("Unknown command, expected one of: serve, migrate, find-duplicates, apply-retention, "+
+			"rotate-keys, merge-customers, create-account, create-api-key, revoke-api-key", "command", command)
+	}
+}
+
+// newCipher returns cipher for personal data using keys from PII_KEY_FILE or
+// PII_MASTER_KEYS. If neither is set, personal data is stored unencrypted
+// only when ENCRYPTION_DISABLED is set, otherwise the service doesn't start
+func newCipher(cfg *Config) encryption.Cipher {
+	var (
+		keyring *encryption.Keyring
+		err     error
+	)
+	switch {
+	case cfg.PIIKeyFile != "":
+		keyring, err = encryption.LoadKeyFile(cfg.PIIKeyFile)
+	case cfg.PIIMasterKeys != "":
+		keyring, err = encryption.ParseMasterKeys(cfg.PIIMasterKeys, cfg.PIIBlindIndexKey)
+	case cfg.EncryptionDisabled:
+		logging.Default().Warn("Encryption is disabled, personal data is stored unencrypted")
+		return encryption.Plaintext{}
+	default:
+		logging.Default().Fatal("No PII encryption keys are configured, set PII_KEY_FILE or PII_MASTER_KEYS, " +
+			"or ENCRYPTION_DISABLED=true to store personal data unencrypted")
+	}
+	if err != nil {
+		logging.Default().Fatal("Failed to load PII encryption keys", "error", err)
+	}
+
+	return keyring
+}
+
+// serve starts HTTP API of the service, on SIGTERM or SIGINT in-flight
+// requests are drained and DB connection is closed
+func serve(ctx context.Context, cfg *Config, processingService processing.Service, db *gorm.DB) {
+	logger := logging.FromContext(ctx)
 
-	shipmentEndpoint := router.PathPrefix("/shipment").Subrouter()
+	apiController := controller.NewApiController(processingService, newTokenVerifier(cfg))
 
-	shipmentEndpoint.HandleFunc("/list", apiController.GetAllShipments).Methods(http.MethodGet)
-	shipmentEndpoint.HandleFunc("", apiController.CreateNewShipment).Methods(http.MethodPost)
-	shipmentEndpoint.HandleFunc("/{id:[0-9]+}", apiController.GetShipmentByID).Methods(http.MethodGet)
+	metrics.RegisterDBStats(db.DB(), cfg.ServiceName)
+
+	ipLimiter, callerLimiter := newRateLimiters(cfg)
+	router := controller.NewRouter(apiController, controller.RouterConfig{
+		Logger:            logging.Default(),
+		IPLimiter:         ipLimiter,
+		CallerLimiter:     callerLimiter,
+		TrustForwardedFor: cfg.TrustForwardedFor,
+		OpenAPI:           newOpenAPIDocument(cfg),
+		UnversionedSunset: unversionedAPISunset(cfg),
+	})
 
 	tcpAddr := net.TCPAddr{Port: cfg.Port}
-	log.Printf("[INFO] Service \""+cfg.ServiceName+"\" is starting on port %v", cfg.Port)
-	if err := http.ListenAndServe(tcpAddr.String(), router); err != nil {
-		log.Fatal("[ERROR] Failed to listen port ", cfg.Port, err)
+	server := &http.Server{
+		Addr:              tcpAddr.String(),
+		Handler:           router,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+
+	serverErrors := make(chan error, 1)
+	go func() {
+		logger.Info("Service is starting", "port", cfg.Port)
+		serverErrors <- server.ListenAndServe()
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+
+	select {
+	case err := <-serverErrors:
+		logger.Fatal("Failed to listen port", "port", cfg.Port, "error", err)
+	case sig := <-signals:
+		logger.Info("Draining requests", "signal", sig.String(), "timeout", cfg.ShutdownTimeout.String())
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("Failed to drain requests", "error", err)
+	}
+
+	if err := db.Close(); err != nil {
+		logger.Warn("Failed to close DB connection", "error", err)
+	}
+
+	logger.Info("Service is stopped")
+}
+
+// newTokenVerifier returns verifier of dashboard users' bearer tokens signed
+// by keys from JWKS_URL (URL or file path), nil if JWKS_URL is not set
+func newTokenVerifier(cfg *Config) *auth.TokenVerifier {
+	if cfg.JWKSURL == "" {
+		return nil
+	}
+
+	jwks, err := auth.LoadJWKS(cfg.JWKSURL)
+	if err != nil {
+		logging.Default().Fatal("Failed to load JWKS", "error", err)
+	}
+
+	return auth.NewTokenVerifier(jwks, auth.TokenConfig{
+		Issuer:       cfg.JWTIssuer,
+		Audience:     cfg.JWTAudience,
+		RolesClaim:   cfg.JWTRolesClaim,
+		AccountClaim: cfg.JWTAccountClaim,
+	})
+}
+
+// newOpenAPIDocument returns document requests are validated against,
+// requests aren't validated if it's nil
+func newOpenAPIDocument(cfg *Config) *openapi.Document {
+	if !cfg.OpenAPIValidateRequests {
+		return nil
+	}
+
+	document, err := openapi.Load()
+	if err != nil {
+		logging.Default().Fatal("Failed to load OpenAPI document", "error", err)
+	}
+	return document
+}
+
+// unversionedAPISunset returns the date after which unversioned routes may
+// be removed, zero if it is not configured
+func unversionedAPISunset(cfg *Config) time.Time {
+	if cfg.UnversionedAPISunset == "" {
+		return time.Time{}
+	}
+
+	sunset, err := time.Parse("2006-01-02", cfg.UnversionedAPISunset)
+	if err != nil {
+		logging.Default().Fatal("Invalid UNVERSIONED_API_SUNSET", "error", err)
 	}
+	return sunset
+}
+
+// newRateLimiters returns limiter of requests per client IP to all routes
+// together and limiter of requests per caller with limits of routes
+func newRateLimiters(cfg *Config) (ipLimiter, callerLimiter *ratelimit.Limiter) {
+	ipLimit, err := ratelimit.ParseLimit(cfg.RateLimitIP)
+	if err != nil {
+		logging.Default().Fatal("Invalid RATE_LIMIT_IP", "error", err)
+	}
+
+	defaultLimit, err := ratelimit.ParseLimit(cfg.RateLimitDefault)
+	if err != nil {
+		logging.Default().Fatal("Invalid RATE_LIMIT_DEFAULT", "error", err)
+	}
+
+	routeLimits, err := ratelimit.ParseRouteLimits(cfg.RateLimitRoutes)
+	if err != nil {
+		logging.Default().Fatal("Invalid RATE_LIMIT_ROUTES", "error", err)
+	}
+
+	store := ratelimit.NewMemoryStore()
+	return ratelimit.NewLimiter(store, ipLimit, nil), ratelimit.NewLimiter(store, defaultLimit, routeLimits)
 }
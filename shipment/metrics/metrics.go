@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const namespace = "shipment"
+
+// Registry holds metrics of the service, Go runtime and process metrics
+// are included
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by route template, method and response status.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of HTTP requests by route template, method and response status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Duration of DB queries by repo method.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"repo", "method"})
+
+	shipmentsCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "shipments_created_total",
+		Help:      "Number of created shipments by origin country and price bucket.",
+	}, []string{"origin_country", "price_bucket"})
+
+	validationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "validation_failures_total",
+		Help:      "Number of failed field validations of request bodies by reason.",
+	}, []string{"reason"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		dbQueryDuration,
+		shipmentsCreated,
+		validationFailures,
+	)
+}
+
+// Handler serves metrics in Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDBStats exposes connection pool stats of DB
+func RegisterDBStats(db *sql.DB, name string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// ObserveQuery records duration of DB queries of repo method since start
+func ObserveQuery(repo, method string, start time.Time) {
+	dbQueryDuration.WithLabelValues(repo, method).Observe(time.Since(start).Seconds())
+}
+
+// priceBuckets are upper bounds of price buckets, prices above the last one
+// fall into "<last>+" bucket
+var priceBuckets = []int{250, 500, 1000, 2500}
+
+// ShipmentCreated counts created shipment
+func ShipmentCreated(originCountry string, price int) {
+	shipmentsCreated.WithLabelValues(originCountry, PriceBucket(price)).Inc()
+}
+
+// PriceBucket returns label of price range, e.g. "250-499"
+func PriceBucket(price int) string {
+	lower := 0
+	for _, upper := range priceBuckets {
+		if price < upper {
+			return strconv.Itoa(lower) + "-" + strconv.Itoa(upper-1)
+		}
+		lower = upper
+	}
+	return strconv.Itoa(lower) + "+"
+}
+
+// ValidationFailed counts failed validation by reason, e.g. "required"
+func ValidationFailed(reason string) {
+	validationFailures.WithLabelValues(reason).Inc()
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPriceBucket(t *testing.T) {
+	tests := []struct {
+		price int
+		want  string
+	}{
+		{price: 0, want: "0-249"},
+		{price: 100, want: "0-249"},
+		{price: 250, want: "250-499"},
+		{price: 999, want: "500-999"},
+		{price: 2000, want: "1000-2499"},
+		{price: 5000, want: "2500+"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, PriceBucket(tt.price), tt.price)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(Middleware)
+	api := router.NewRoute().Subrouter()
+	api.HandleFunc("/shipment/{id:[0-9]+}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods(http.MethodGet)
+
+	for _, path := range []string{"/shipment/1", "/shipment/2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(
+		httpRequests.WithLabelValues("/shipment/{id:[0-9]+}", http.MethodGet, "404")))
+	assert.Equal(t, 1, testutil.CollectAndCount(httpDuration))
+}
+
+func TestHandler(t *testing.T) {
+	ShipmentCreated("SE", 100)
+	ValidationFailed("required")
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := w.Body.String()
+	assert.True(t, strings.Contains(body, `shipment_shipments_created_total{origin_country="SE",price_bucket="0-249"} 1`))
+	assert.True(t, strings.Contains(body, `shipment_validation_failures_total{reason="required"} 1`))
+	assert.True(t, strings.Contains(body, "go_goroutines"))
+}
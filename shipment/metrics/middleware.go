@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"github.com/gorilla/mux"
+	"net/http"
+	"sendify_test/shipment/httputil"
+	"strconv"
+	"time"
+)
+
+// Middleware counts requests and observes their duration by route template
+// of mux router, so IDs in paths don't produce unbounded label values
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := httputil.NewStatusRecorder(w)
+
+		next.ServeHTTP(recorder, r)
+
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		status := strconv.Itoa(recorder.Status())
+		httpRequests.WithLabelValues(route, r.Method, status).Inc()
+		httpDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Account owns shipments, customers and address book, API callers see
+// data of their account only
+type Account struct {
+	ID        int       `json:"id,omitempty" gorm:"column:id"`
+	Name      string    `json:"name" gorm:"column:name"`
+	CreatedAt time.Time `json:"created_at,omitempty" gorm:"column:created_at"`
+}
+
+type Accounts []Account
+
+// APIKey authenticates API callers on behalf of the account, only hash of
+// the key is stored
+type APIKey struct {
+	ID        int        `json:"id,omitempty" gorm:"column:id"`
+	AccountID int        `json:"account_id" gorm:"column:account_id"`
+	Name      string     `json:"name" gorm:"column:name"`
+	Role      string     `json:"role" gorm:"column:role"`
+	Prefix    string     `json:"prefix" gorm:"column:prefix"`
+	Hash      string     `json:"-" gorm:"column:hash"`
+	CreatedAt time.Time  `json:"created_at,omitempty" gorm:"column:created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" gorm:"column:revoked_at"`
+}
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"sendify_test/shipment/validation"
+	"strings"
+)
+
+const (
+	maxStreetLines      = 2
+	maxPostalCodeLength = 10
+)
+
+// PostalAddress is structured postal address, country of the address is
+// specified by country code of its owner
+type PostalAddress struct {
+	StreetLines StreetLines `json:"street_lines" gorm:"column:street_lines"`
+	HouseNumber string      `json:"house_number,omitempty" gorm:"column:house_number"`
+	PostalCode  string      `json:"postal_code,omitempty" gorm:"column:postal_code"`
+	City        string      `json:"city" gorm:"column:city"`
+	Region      string      `json:"region,omitempty" gorm:"column:region"`
+}
+
+// postalCodePatterns contains postal code formats of countries,
+// postal codes of other countries are checked only for allowed characters
+var postalCodePatterns = map[string]*regexp.Regexp{
+	"AT": regexp.MustCompile(`^\d{4}$`),
+	"BE": regexp.MustCompile(`^\d{4}$`),
+	"CA": regexp.MustCompile(`^[A-Z]\d[A-Z] ?\d[A-Z]\d$`),
+	"CH": regexp.MustCompile(`^\d{4}$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"DK": regexp.MustCompile(`^\d{4}$`),
+	"ES": regexp.MustCompile(`^\d{5}$`),
+	"FI": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`),
+	"IS": regexp.MustCompile(`^\d{3}$`),
+	"IT": regexp.MustCompile(`^\d{5}$`),
+	"NL": regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`),
+	"NO": regexp.MustCompile(`^\d{4}$`),
+	"PL": regexp.MustCompile(`^\d{2}-\d{3}$`),
+	"SE": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"UA": regexp.MustCompile(`^\d{5}$`),
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+}
+
+var postalCodeRegex = regexp.MustCompile(`^[A-Z0-9][A-Z0-9 \-]*$`)
+
+// countriesWithoutPostalCodes do not use postal codes at all
+var countriesWithoutPostalCodes = map[string]bool{
+	"AE": true,
+	"HK": true,
+	"QA": true,
+}
+
+// countriesWithRequiredRegion require state or province in address
+var countriesWithRequiredRegion = map[string]bool{
+	"AU": true,
+	"BR": true,
+	"CA": true,
+	"US": true,
+}
+
+// Validate checks address against format of the country
+func (a PostalAddress) Validate(countryCode string) error {
+	v := validation.NewValidator()
+	a.validate(v, "", countryCode)
+	return v.Err()
+}
+
+func (a PostalAddress) validate(v *validation.Validator, path, countryCode string) {
+	if len(a.StreetLines) == 0 {
+		v.AddError(path+"/street_lines", validation.CodeRequired, "empty street line")
+	} else if len(a.StreetLines) > maxStreetLines {
+		v.AddError(path+"/street_lines", validation.CodeTooLong, "too many street lines")
+	}
+	for i, line := range a.StreetLines {
+		v.CheckField(fmt.Sprintf("%s/street_lines/%d", path, i), "address.street_line", line)
+	}
+
+	v.CheckField(path+"/house_number", "address.house_number", a.HouseNumber)
+	v.CheckField(path+"/city", "address.city", a.City)
+
+	if v.CheckField(path+"/region", "address.region", a.Region) &&
+		countriesWithRequiredRegion[countryCode] && strings.TrimSpace(a.Region) == "" {
+		v.AddError(path+"/region", validation.CodeRequired, "empty region")
+	}
+
+	if code, message := checkPostalCode(a.PostalCode, countryCode); code != "" {
+		v.AddError(path+"/postal_code", code, message)
+	}
+}
+
+func checkPostalCode(postalCode, countryCode string) (code, message string) {
+	if countriesWithoutPostalCodes[countryCode] {
+		if postalCode != "" {
+			return validation.CodeNotAllowed, "postal code is not used in country"
+		}
+		return "", ""
+	}
+
+	if postalCode == "" {
+		return validation.CodeRequired, "empty postal code"
+	}
+	if len(postalCode) > maxPostalCodeLength {
+		return validation.CodeTooLong, "too long postal code"
+	}
+
+	pattern, ok := postalCodePatterns[countryCode]
+	if !ok {
+		pattern = postalCodeRegex
+	}
+	if !pattern.MatchString(strings.ToUpper(postalCode)) {
+		return validation.CodeInvalidFormat, "invalid postal code for country " + countryCode
+	}
+
+	return "", ""
+}
+
+// String returns address formatted as single line, e.g.
+// "Volrat Thamsgatan 4, 41260 Göteborg"
+func (a PostalAddress) String() string {
+	street := strings.Join(a.StreetLines, ", ")
+	if a.HouseNumber != "" {
+		street += " " + a.HouseNumber
+	}
+
+	parts := []string{street, strings.TrimSpace(a.PostalCode + " " + a.City)}
+	if a.Region != "" {
+		parts = append(parts, a.Region)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// StreetLines are street lines of an address, stored in DB as newline
+// separated text
+type StreetLines []string
+
+func (l StreetLines) Value() (driver.Value, error) {
+	return strings.Join(l, "\n"), nil
+}
+
+func (l *StreetLines) Scan(src interface{}) error {
+	var value string
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		value = string(v)
+	case string:
+		value = v
+	default:
+		return fmt.Errorf("unsupported street lines type %T", src)
+	}
+
+	if value == "" {
+		*l = nil
+		return nil
+	}
+
+	*l = strings.Split(value, "\n")
+	return nil
+}
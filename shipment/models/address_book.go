@@ -0,0 +1,50 @@
+package models
+
+import (
+	"sendify_test/shipment/validation"
+	"time"
+)
+
+// SavedAddress is a named sender/recipient profile from account's address book
+type SavedAddress struct {
+	ID          int       `json:"id,omitempty" gorm:"column:id"`
+	AccountID   int       `json:"account_id" gorm:"column:account_id"`
+	Label       string    `json:"label" gorm:"column:label"`
+	Name        string    `json:"name" gorm:"column:name"`
+	Email       string    `json:"email" gorm:"column:email"`
+	Phone       string    `json:"phone,omitempty" gorm:"column:phone"`
+	CountryCode string    `json:"country_code" gorm:"column:country_code"`
+	CreatedAt   time.Time `json:"created_at,omitempty" gorm:"column:created_at"`
+	UpdatedAt   time.Time `json:"updated_at,omitempty" gorm:"column:updated_at"`
+
+	Address PostalAddress `json:"address" gorm:"embedded;embedded_prefix:address_"`
+}
+
+// Customer returns customer details stored in saved address
+func (a SavedAddress) Customer() Customer {
+	return Customer{
+		AccountID:   a.AccountID,
+		Name:        a.Name,
+		Email:       a.Email,
+		Phone:       a.Phone,
+		Address:     a.Address,
+		CountryCode: a.CountryCode,
+	}
+}
+
+// Normalize brings saved address details to canonical form
+func (a *SavedAddress) Normalize() {
+	customer := a.Customer()
+	customer.Normalize()
+	a.Email = customer.Email
+	a.Phone = customer.Phone
+}
+
+func (a SavedAddress) Validate() error {
+	v := validation.NewValidator()
+	v.CheckField("/label", "saved_address.label", a.Label)
+	a.Customer().validate(v, "")
+	return v.Err()
+}
+
+type SavedAddresses []SavedAddress
@@ -0,0 +1,194 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Audited actions
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+	AuditMerge  = "merge"
+	AuditErase  = "erase"
+)
+
+// Audited entities
+const (
+	EntityShipment     = "shipment"
+	EntityCustomer     = "customer"
+	EntitySavedAddress = "saved_address"
+)
+
+// Redacted replaces values of personal data in audit log, so audit log keeps
+// which personal data was changed, but not the data itself
+const Redacted = "[redacted]"
+
+// personalDataFields are JSON fields of customers and saved addresses
+// redacted in audit log
+var personalDataFields = map[string]bool{
+	"name":    true,
+	"email":   true,
+	"phone":   true,
+	"address": true,
+}
+
+// Actor is who performs audited operation
+type Actor struct {
+	// ID identifies caller, e.g. "apikey:1" or "system:retention"
+	ID        string
+	RequestID string
+}
+
+// SystemActor returns actor of the job
+func SystemActor(job string) Actor {
+	return Actor{ID: "system:" + job}
+}
+
+// AuditEntry is record of append-only audit log of mutating operations
+type AuditEntry struct {
+	ID        int       `json:"id" gorm:"column:id"`
+	AccountID int       `json:"-" gorm:"column:account_id"`
+	Actor     string    `json:"actor" gorm:"column:actor"`
+	Action    string    `json:"action" gorm:"column:action"`
+	Entity    string    `json:"entity" gorm:"column:entity"`
+	EntityID  int       `json:"entity_id" gorm:"column:entity_id"`
+	Changes   Changes   `json:"changes" gorm:"column:changes"`
+	RequestID string    `json:"request_id,omitempty" gorm:"column:request_id"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+type AuditEntries []AuditEntry
+
+// NewAuditEntry returns entry with changes between JSON representations of
+// entity before and after the action, nil before stands for created entity
+// and nil after for deleted one
+func NewAuditEntry(actor Actor, accountID int, action, entity string, entityID int, before, after interface{}) (AuditEntry, error) {
+	changes, err := NewChanges(before, after)
+	if err != nil {
+		return AuditEntry{}, err
+	}
+
+	return AuditEntry{
+		AccountID: accountID,
+		Actor:     actor.ID,
+		Action:    action,
+		Entity:    entity,
+		EntityID:  entityID,
+		Changes:   changes,
+		RequestID: actor.RequestID,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// Change is value of the field before and after the action
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Changes are changed fields by their JSON names
+type Changes map[string]Change
+
+// NewChanges returns fields which differ in JSON representations of values,
+// personal data is redacted
+func NewChanges(before, after interface{}) (Changes, error) {
+	beforeFields, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := Changes{}
+	for name, value := range beforeFields {
+		if afterValue, ok := afterFields[name]; !ok || !reflect.DeepEqual(value, afterValue) {
+			changes[name] = Change{Before: value, After: afterValue}
+		}
+	}
+	for name, value := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			changes[name] = Change{After: value}
+		}
+	}
+
+	for name, change := range changes {
+		if personalDataFields[name] {
+			if change.Before != nil {
+				change.Before = Redacted
+			}
+			if change.After != nil {
+				change.After = Redacted
+			}
+			changes[name] = change
+		}
+	}
+
+	return changes, nil
+}
+
+func jsonFields(value interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if value == nil || reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil() {
+		return fields, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// Value stores changes as JSON object
+func (c Changes) Value() (driver.Value, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (c *Changes) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported changes type %T", src)
+	}
+
+	return json.Unmarshal(data, c)
+}
+
+// ShipmentAuditRecord is audited representation of shipment, customers are
+// referred by IDs
+type ShipmentAuditRecord struct {
+	Weight       int `json:"weight"`
+	Price        int `json:"price"`
+	CustomerFrom int `json:"customer_from"`
+	CustomerTo   int `json:"customer_to"`
+}
+
+func (s Shipment) AuditRecord() ShipmentAuditRecord {
+	return ShipmentAuditRecord{
+		Weight:       s.Weight,
+		Price:        s.Price.Amount,
+		CustomerFrom: s.FromID,
+		CustomerTo:   s.ToID,
+	}
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"fmt"
+	"sendify_test/shipment/validation"
+	"strings"
+	"time"
+)
+
+// MatchKey returns key identifying customer regardless of letter case and
+// extra spaces in name, email and address. Key contains personal data, so
+// it is stored as blind index only
+func (c Customer) MatchKey() string {
+	return strings.Join([]string{
+		NormalizeText(c.Name),
+		NormalizeText(c.Email),
+		NormalizeText(c.Address.String()),
+		NormalizeText(c.CountryCode),
+	}, "|")
+}
+
+// NormalizeText lowercases text and collapses whitespaces in it
+func NormalizeText(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+// DuplicateCandidate is pair of customers which are likely the same person
+type DuplicateCandidate struct {
+	First   Customer `json:"first"`
+	Second  Customer `json:"second"`
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+type DuplicateCandidates []DuplicateCandidate
+
+// MergeRequest describes customers merged into surviving one
+type MergeRequest struct {
+	SurvivorID   int   `json:"survivor_id"`
+	DuplicateIDs []int `json:"duplicate_ids"`
+}
+
+func (m MergeRequest) Validate() error {
+	v := validation.NewValidator()
+	if m.SurvivorID <= 0 {
+		v.AddError("/survivor_id", validation.CodeRequired, "empty survivor ID")
+	}
+	if len(m.DuplicateIDs) == 0 {
+		v.AddError("/duplicate_ids", validation.CodeRequired, "empty duplicate IDs")
+	}
+
+	seen := map[int]bool{}
+	for i, id := range m.DuplicateIDs {
+		pointer := fmt.Sprintf("/duplicate_ids/%d", i)
+		switch {
+		case id <= 0:
+			v.AddError(pointer, validation.CodeOutOfRange, "invalid customer ID")
+		case id == m.SurvivorID:
+			v.AddError(pointer, validation.CodeNotAllowed, "survivor cannot be merged into itself")
+		case seen[id]:
+			v.AddError(pointer, validation.CodeDuplicate, "customer is listed twice")
+		}
+		seen[id] = true
+	}
+
+	return v.Err()
+}
+
+// CustomerMerge is audit record of customer merged into surviving one
+type CustomerMerge struct {
+	ID             int       `json:"id,omitempty" gorm:"column:id"`
+	SurvivorID     int       `json:"survivor_id" gorm:"column:survivor_id"`
+	MergedID       int       `json:"merged_id" gorm:"column:merged_id"`
+	MergedCustomer string    `json:"merged_customer" gorm:"column:merged_customer"`
+	ShipmentsMoved int       `json:"shipments_moved" gorm:"column:shipments_moved"`
+	CreatedAt      time.Time `json:"created_at,omitempty" gorm:"column:created_at"`
+}
+
+// Pseudonymized returns copy of customer with personal data replaced by
+// placeholders, country is kept as a part of shipments financial records
+func (c Customer) Pseudonymized(erasedAt time.Time) Customer {
+	return Customer{
+		ID:          c.ID,
+		AccountID:   c.AccountID,
+		Name:        "Erased",
+		Email:       fmt.Sprintf("erased-%d@erased.invalid", c.ID),
+		CountryCode: c.CountryCode,
+		CreatedAt:   c.CreatedAt,
+		ErasedAt:    &erasedAt,
+	}
+}
+
+// CustomerDataExport contains all personal data of the customer
+// (GDPR data subject access request)
+type CustomerDataExport struct {
+	Customer   Customer           `json:"customer"`
+	Shipments  []CustomerShipment `json:"shipments"`
+	Merges     []CustomerMerge    `json:"merges"`
+	ExportedAt time.Time          `json:"exported_at"`
+}
+
+// Roles of customer in shipment
+const (
+	RoleSender    = "sender"
+	RoleRecipient = "recipient"
+)
+
+// CustomerShipment is shipment of the customer without details of the
+// other party
+type CustomerShipment struct {
+	ID        int       `json:"id"`
+	Weight    int       `json:"weight"`
+	Parcels   Parcels   `json:"parcels"`
+	Price     Price     `json:"price"`
+	Roles     []string  `json:"roles"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewCustomerShipment returns shipment as seen by the customer
+func NewCustomerShipment(shipment Shipment, customerID int) CustomerShipment {
+	customerShipment := CustomerShipment{
+		ID:        shipment.ID,
+		Weight:    shipment.Weight,
+		Parcels:   shipment.Parcels,
+		Price:     shipment.Price,
+		Roles:     []string{},
+		CreatedAt: shipment.CreatedAt,
+	}
+	if shipment.FromID == customerID {
+		customerShipment.Roles = append(customerShipment.Roles, RoleSender)
+	}
+	if shipment.ToID == customerID {
+		customerShipment.Roles = append(customerShipment.Roles, RoleRecipient)
+	}
+	return customerShipment
+}
@@ -1,72 +1,71 @@
 package models
 
 import (
-	"errors"
+	"fmt"
 	"github.com/biter777/countries"
-	"regexp"
-	"strings"
+	"sendify_test/shipment/validation"
 	"time"
-	"unicode"
 )
 
 type Customer struct {
 	ID          int       `json:"id,omitempty" gorm:"column:id"`
+	AccountID   int       `json:"-" gorm:"column:account_id"`
 	Name        string    `json:"name" gorm:"column:name"`
 	Email       string    `json:"email" gorm:"column:email"`
-	Address     string    `json:"address" gorm:"column:address"`
+	Phone       string    `json:"phone,omitempty" gorm:"column:phone"`
 	CountryCode string    `json:"country_code" gorm:"column:country_code"`
 	CreatedAt   time.Time `json:"created_at,omitempty" gorm:"column:created_at"`
-}
 
-func (c Customer) Validate() error {
-	if len(c.Name) > 30 {
-		return errors.New("too long name")
-	}
+	Address PostalAddress `json:"address" gorm:"embedded;embedded_prefix:address_"`
 
-	var nameRegex = regexp.MustCompile(`^[a-zA-Z\s]+$`).MatchString
-	if !nameRegex(c.Name) {
-		return errors.New("name contains unacceptable characters")
-	}
+	// ErasedAt is set when personal data of the customer is pseudonymised
+	ErasedAt *time.Time `json:"erased_at,omitempty" gorm:"column:erased_at"`
 
-	var emailRegex = regexp.MustCompile(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}$`).MatchString
-	if !emailRegex(c.Email) {
-		return errors.New("email contains unacceptable characters")
-	}
+	// AddressID refers to saved address from address book, customer details
+	// are taken from it when specified
+	AddressID int `json:"address_id,omitempty" gorm:"-"`
+}
 
-	if len(c.CountryCode) != 2 {
-		return errors.New("invalid country code format")
+// Normalize brings customer details to canonical form, e.g. email domain
+// is lowercased and phone is in E.164 format, invalid details are kept as is
+// to be reported by Validate
+func (c *Customer) Normalize() {
+	if email, err := validation.NormalizeEmail(c.Email); err == nil {
+		c.Email = email
 	}
-
-	country := countries.ByName(c.CountryCode)
-	if !country.IsValid() {
-		return errors.New("unknown country code")
+	if phone, err := validation.NormalizePhone(c.Phone, c.CountryCode); err == nil {
+		c.Phone = phone
 	}
+}
 
-	if len(c.Address) >= 100 {
-		return errors.New("too long address")
-	}
-	address := strings.Split(c.Address, ",")
-	if len(address) != 2 {
-		return errors.New("invalid address format")
-	}
+func (c Customer) Validate() error {
+	v := validation.NewValidator()
+	c.validate(v, "")
+	return v.Err()
+}
 
-	for _, partAddress := range address {
-		for _, v := range partAddress {
-			if !unicode.IsLetter(v) && !unicode.IsSpace(v) && !unicode.IsDigit(v) {
-				return errors.New("address contains unacceptable characters")
-			}
-		}
+func (c Customer) validate(v *validation.Validator, path string) {
+	v.CheckField(path+"/name", "customer.name", c.Name)
+	v.CheckEmail(path+"/email", "customer.email", c.Email)
+	v.CheckPhone(path+"/phone", c.Phone, c.CountryCode)
+
+	if len(c.CountryCode) != 2 {
+		v.AddError(path+"/country_code", validation.CodeInvalidFormat, "invalid country code format")
+	} else if country := countries.ByName(c.CountryCode); !country.IsValid() {
+		v.AddError(path+"/country_code", validation.CodeUnknownValue, "unknown country code")
 	}
 
-	return nil
+	c.Address.validate(v, path+"/address", c.CountryCode)
 }
 
 type Customers []Customer
 
 type Shipment struct {
 	ID        int       `json:"id,omitempty" gorm:"column:id"`
+	AccountID int       `json:"-" gorm:"column:account_id"`
 	Weight    int       `json:"weight" gorm:"column:weight"`
-	Price     int       `json:"price,omitempty" gorm:"column:price"`
+	Parcels   Parcels   `json:"parcels" gorm:"-"`
+	Price     Price     `json:"price" gorm:"embedded"`
 	From      Customer  `json:"from" gorm:"-"`
 	FromID    int       `json:"-" gorm:"column:customer_from"`
 	To        Customer  `json:"to" gorm:"-"`
@@ -74,21 +73,73 @@ type Shipment struct {
 	CreatedAt time.Time `json:"created_at,omitempty" gorm:"column:created_at"`
 }
 
+// Normalize brings details of "from" and "to" customers to canonical form
+// and sums weight of parcels
+func (s *Shipment) Normalize() {
+	s.From.Normalize()
+	s.To.Normalize()
+	s.Weight = s.Parcels.Weight()
+}
+
 func (s Shipment) Validate() error {
-	if s.Weight > 1000 || s.Weight <= 0 {
-		return errors.New("invalid weight")
+	v := validation.NewValidator()
+	if len(s.Parcels) == 0 {
+		v.AddError("/parcels", validation.CodeRequired, "empty parcels")
+	} else if len(s.Parcels) > MaxParcels {
+		v.AddError("/parcels", validation.CodeOutOfRange, fmt.Sprintf("more than %d parcels", MaxParcels))
 	}
-	if err := s.From.Validate(); err != nil {
-		return err
+	for i, parcel := range s.Parcels {
+		if parcel.Weight > 1000 || parcel.Weight <= 0 {
+			v.AddError(fmt.Sprintf("/parcels/%d/weight", i), validation.CodeOutOfRange, "invalid weight")
+		}
 	}
-	if err := s.To.Validate(); err != nil {
-		return err
+
+	s.From.validate(v, "/from")
+	s.To.validate(v, "/to")
+
+	if s.From.CountryCode == s.To.CountryCode && s.From.Address.String() == s.To.Address.String() {
+		v.AddError("/to/address", validation.CodeDuplicate, `"from" and "to" locations are same`)
 	}
-	if s.From.Address == s.To.Address {
-		return errors.New(`"from" and "to" locations are same`)
+
+	senderPhone, recipientPhone := v.Rules().RequiredPhones(s.From.CountryCode, s.To.CountryCode)
+	if senderPhone && s.From.Phone == "" {
+		v.AddError("/from/phone", validation.CodeRequired, "phone is required for shipments of the lane")
 	}
+	if recipientPhone && s.To.Phone == "" {
+		v.AddError("/to/phone", validation.CodeRequired, "phone is required for shipments of the lane")
+	}
+
+	return v.Err()
+}
+
+// MaxParcels is the maximum number of parcels of shipment
+const MaxParcels = 20
 
-	return nil
+// Parcel is a package of shipment, weight is in kilograms
+type Parcel struct {
+	ID         int `json:"-" gorm:"column:id"`
+	ShipmentID int `json:"-" gorm:"column:shipment_id"`
+	Weight     int `json:"weight" gorm:"column:weight"`
+}
+
+type Parcels []Parcel
+
+// Weight returns total weight of parcels
+func (p Parcels) Weight() int {
+	var weight int
+	for _, parcel := range p {
+		weight += parcel.Weight
+	}
+	return weight
+}
+
+// PriceCurrency is currency of prices of the tariff, ISO 4217 code
+const PriceCurrency = "SEK"
+
+// Price is amount in whole units of currency
+type Price struct {
+	Amount   int    `json:"amount" gorm:"column:price"`
+	Currency string `json:"currency" gorm:"column:currency"`
 }
 
 const (
@@ -119,18 +170,23 @@ func (s *Shipment) FormPrice() {
 		deliveryRate = OthersRate
 	}
 
+	// price band is chosen by total weight of parcels
+	weight := s.Parcels.Weight()
 	var basePrice int
-	if s.Weight < 10 {
+	if weight < 10 {
 		basePrice = UpTo10kgPrice
-	} else if s.Weight < 25 {
+	} else if weight < 25 {
 		basePrice = UpTo25kgPrice
-	} else if s.Weight < 50 {
+	} else if weight < 50 {
 		basePrice = UpTo50kgPrice
 	} else {
 		basePrice = UpTo1000kgPrice
 	}
 
-	s.Price = basePrice * deliveryRate / 100 // returning from percent to int
+	s.Price = Price{
+		Amount:   basePrice * deliveryRate / 100, // returning from percent to int
+		Currency: PriceCurrency,
+	}
 }
 
 type Shipments []Shipment
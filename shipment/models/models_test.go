@@ -4,7 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sendify_test/shipment/errs"
+	"sendify_test/shipment/validation"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestShipment_Validate(t *testing.T) {
@@ -25,15 +31,25 @@ func TestShipment_Validate(t *testing.T) {
 			fields: fields{
 				// Weight: 0,
 				From: Customer{
-					Name:        "Daniel",
-					Email:       "daniel@sendify.se",
-					Address:     "Volrat Thamsgatan 4, Göteborg 41260",
+					Name:  "Daniel",
+					Email: "daniel@sendify.se",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Volrat Thamsgatan"},
+						HouseNumber: "4",
+						PostalCode:  "41260",
+						City:        "Göteborg",
+					},
 					CountryCode: "SE",
 				},
 				To: Customer{
-					Name:        "Nikita",
-					Email:       "nicitch.astrashkov@gmail.com",
-					Address:     "Prospect Nauki 14, Kharkiv 61166",
+					Name:  "Nikita",
+					Email: "nicitch.astrashkov@gmail.com",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Prospect Nauki"},
+						HouseNumber: "14",
+						PostalCode:  "61166",
+						City:        "Kharkiv",
+					},
 					CountryCode: "UA",
 				},
 			},
@@ -45,15 +61,25 @@ func TestShipment_Validate(t *testing.T) {
 			fields: fields{
 				Weight: 1001,
 				From: Customer{
-					Name:        "Daniel",
-					Email:       "daniel@sendify.se",
-					Address:     "Volrat Thamsgatan 4, Göteborg 41260",
+					Name:  "Daniel",
+					Email: "daniel@sendify.se",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Volrat Thamsgatan"},
+						HouseNumber: "4",
+						PostalCode:  "41260",
+						City:        "Göteborg",
+					},
 					CountryCode: "SE",
 				},
 				To: Customer{
-					Name:        "Nikita",
-					Email:       "nicitch.astrashkov@gmail.com",
-					Address:     "Prospect Nauki 14, Kharkiv 61166",
+					Name:  "Nikita",
+					Email: "nicitch.astrashkov@gmail.com",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Prospect Nauki"},
+						HouseNumber: "14",
+						PostalCode:  "61166",
+						City:        "Kharkiv",
+					},
 					CountryCode: "UA",
 				},
 			},
@@ -65,35 +91,115 @@ func TestShipment_Validate(t *testing.T) {
 			fields: fields{
 				Weight: 1,
 				From: Customer{
-					Name:        "Daniel!",
-					Email:       "daniel@sendify.se",
-					Address:     "Volrat Thamsgatan 4, Göteborg 41260",
+					Name:  "Daniel!",
+					Email: "daniel@sendify.se",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Volrat Thamsgatan"},
+						HouseNumber: "4",
+						PostalCode:  "41260",
+						City:        "Göteborg",
+					},
 					CountryCode: "SE",
 				},
 				To: Customer{
-					Name:        "Nikita",
-					Email:       "nicitch.astrashkov@gmail.com",
-					Address:     "Prospect Nauki 14, Kharkiv 61166",
+					Name:  "Nikita",
+					Email: "nicitch.astrashkov@gmail.com",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Prospect Nauki"},
+						HouseNumber: "14",
+						PostalCode:  "61166",
+						City:        "Kharkiv",
+					},
 					CountryCode: "UA",
 				},
 			},
 			wantErr: true,
 			err:     errors.New("name contains unacceptable characters"),
 		}, // unacceptable name
+		{
+			name: "Unicode names",
+			fields: fields{
+				Weight: 1,
+				From: Customer{
+					Name:  "Björn O'Brien",
+					Email: "daniel@sendify.se",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Volrat Thamsgatan"},
+						HouseNumber: "4",
+						PostalCode:  "41260",
+						City:        "Göteborg",
+					},
+					CountryCode: "SE",
+				},
+				To: Customer{
+					Name:  "José Núñez",
+					Email: "nicitch.astrashkov@gmail.com",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Prospect Nauki"},
+						HouseNumber: "14",
+						PostalCode:  "61166",
+						City:        "Kharkiv",
+					},
+					CountryCode: "UA",
+				},
+			},
+			wantErr: false,
+			err:     nil,
+		}, // unicode names
+		{
+			name: "Several invalid fields",
+			fields: fields{
+				Weight: 1,
+				From: Customer{
+					Name:  "Daniel!",
+					Email: "daniel@sendify?se",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Volrat Thamsgatan"},
+						HouseNumber: "4",
+						PostalCode:  "41260",
+						City:        "Göteborg",
+					},
+					CountryCode: "SE",
+				},
+				To: Customer{
+					Name:  "Nikita",
+					Email: "nicitch.astrashkov@gmail.com",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Prospect Nauki"},
+						HouseNumber: "14",
+						PostalCode:  "61166",
+						City:        "Kharkiv",
+					},
+					CountryCode: "WP",
+				},
+			},
+			wantErr: true,
+			err:     errors.New("name contains unacceptable characters; invalid email address; unknown country code"),
+		}, // all errors are collected
 		{
 			name: "Too long name",
 			fields: fields{
 				Weight: 1,
 				From: Customer{
-					Name:        "Daniel",
-					Email:       "daniel@sendify.se",
-					Address:     "Volrat Thamsgatan 4, Göteborg 41260",
+					Name:  "Daniel",
+					Email: "daniel@sendify.se",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Volrat Thamsgatan"},
+						HouseNumber: "4",
+						PostalCode:  "41260",
+						City:        "Göteborg",
+					},
 					CountryCode: "SE",
 				},
 				To: Customer{
-					Name:        "Nikita Nikita Nikita Nikita Nikita", // too long - 34 chars
-					Email:       "nicitch.astrashkov@gmail.com",
-					Address:     "Prospect Nauki 14, Kharkiv 61166",
+					Name:  "Nikita Nikita Nikita Nikita Nikita", // too long - 34 chars
+					Email: "nicitch.astrashkov@gmail.com",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Prospect Nauki"},
+						HouseNumber: "14",
+						PostalCode:  "61166",
+						City:        "Kharkiv",
+					},
 					CountryCode: "UA",
 				},
 			},
@@ -105,35 +211,55 @@ func TestShipment_Validate(t *testing.T) {
 			fields: fields{
 				Weight: 1,
 				From: Customer{
-					Name:        "Daniel",
-					Email:       "daniel@sendify?se", // unacceptable char in domain part
-					Address:     "Volrat Thamsgatan 4, Göteborg 41260",
+					Name:  "Daniel",
+					Email: "daniel@sendify?se", // unacceptable char in domain part
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Volrat Thamsgatan"},
+						HouseNumber: "4",
+						PostalCode:  "41260",
+						City:        "Göteborg",
+					},
 					CountryCode: "SE",
 				},
 				To: Customer{
-					Name:        "Nikita",
-					Email:       "nicitch.astrashkov@gmail.com",
-					Address:     "Prospect Nauki 14, Kharkiv 61166",
+					Name:  "Nikita",
+					Email: "nicitch.astrashkov@gmail.com",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Prospect Nauki"},
+						HouseNumber: "14",
+						PostalCode:  "61166",
+						City:        "Kharkiv",
+					},
 					CountryCode: "UA",
 				},
 			},
 			wantErr: true,
-			err:     errors.New("email contains unacceptable characters"),
+			err:     errors.New("invalid email address"),
 		}, // unacceptable email
 		{
 			name: "Invalid country code format",
 			fields: fields{
 				Weight: 1,
 				From: Customer{
-					Name:        "Daniel",
-					Email:       "daniel@sendify.se",
-					Address:     "Volrat Thamsgatan 4, Göteborg 41260",
+					Name:  "Daniel",
+					Email: "daniel@sendify.se",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Volrat Thamsgatan"},
+						HouseNumber: "4",
+						PostalCode:  "41260",
+						City:        "Göteborg",
+					},
 					CountryCode: "SE",
 				},
 				To: Customer{
-					Name:        "Nikita",
-					Email:       "nicitch.astrashkov@gmail.com",
-					Address:     "Prospect Nauki 14, Kharkiv 61166",
+					Name:  "Nikita",
+					Email: "nicitch.astrashkov@gmail.com",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Prospect Nauki"},
+						HouseNumber: "14",
+						PostalCode:  "61166",
+						City:        "Kharkiv",
+					},
 					CountryCode: "UKR", // this one is invalid
 				},
 			},
@@ -145,15 +271,25 @@ func TestShipment_Validate(t *testing.T) {
 			fields: fields{
 				Weight: 1,
 				From: Customer{
-					Name:        "Daniel",
-					Email:       "daniel@sendify.se",
-					Address:     "Volrat Thamsgatan 4, Göteborg 41260",
+					Name:  "Daniel",
+					Email: "daniel@sendify.se",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Volrat Thamsgatan"},
+						HouseNumber: "4",
+						PostalCode:  "41260",
+						City:        "Göteborg",
+					},
 					CountryCode: "SE",
 				},
 				To: Customer{
-					Name:        "Nikita",
-					Email:       "nicitch.astrashkov@gmail.com",
-					Address:     "Prospect Nauki 14, Kharkiv 61166",
+					Name:  "Nikita",
+					Email: "nicitch.astrashkov@gmail.com",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Prospect Nauki"},
+						HouseNumber: "14",
+						PostalCode:  "61166",
+						City:        "Kharkiv",
+					},
 					CountryCode: "WP", // this one is invalid
 				},
 			},
@@ -161,93 +297,191 @@ func TestShipment_Validate(t *testing.T) {
 			err:     errors.New("unknown country code"),
 		}, // unknown country code
 		{
-			name: "Too long address",
+			name: "Too long street line",
 			fields: fields{
 				Weight: 1,
 				From: Customer{
-					Name:        "Daniel",
-					Email:       "daniel@sendify.se",
-					Address:     "Volrat Thamsgatan 4, Göteborg 41260",
+					Name:  "Daniel",
+					Email: "daniel@sendify.se",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Volrat Thamsgatan"},
+						HouseNumber: "4",
+						PostalCode:  "41260",
+						City:        "Göteborg",
+					},
 					CountryCode: "SE",
 				},
 				To: Customer{
-					Name:        "Nikita",
-					Email:       "nicitch.astrashkov@gmail.com",
-					Address:     "Prospect Nauki Prospect Nauki Prospect Nauki Prospect Nauki 14, Kharkiv Kharkiv Kharkiv Kharkiv 61166", // invalid - 101 character
+					Name:  "Nikita",
+					Email: "nicitch.astrashkov@gmail.com",
+					Address: PostalAddress{
+						StreetLines: StreetLines{strings.Repeat("Prospect Nauki ", 7)}, // invalid - 105 characters
+						HouseNumber: "14",
+						PostalCode:  "61166",
+						City:        "Kharkiv",
+					},
 					CountryCode: "UA",
 				},
 			},
 			wantErr: true,
-			err:     errors.New("too long address"),
-		}, // too long address
+			err:     errors.New("too long street line"),
+		}, // too long street line
 		{
-			name: "Invalid address format",
+			name: "Invalid postal code",
 			fields: fields{
 				Weight: 1,
 				From: Customer{
-					Name:        "Daniel",
-					Email:       "daniel@sendify.se",
-					Address:     "Volrat Thamsgatan 4 Göteborg 41260", // this one is invalid
+					Name:  "Daniel",
+					Email: "daniel@sendify.se",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Volrat Thamsgatan"},
+						HouseNumber: "4",
+						PostalCode:  "4126", // this one is invalid
+						City:        "Göteborg",
+					},
 					CountryCode: "SE",
 				},
 				To: Customer{
-					Name:        "Nikita",
-					Email:       "nicitch.astrashkov@gmail.com",
-					Address:     "Prospect Nauki 14, Kharkiv 61166",
+					Name:  "Nikita",
+					Email: "nicitch.astrashkov@gmail.com",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Prospect Nauki"},
+						HouseNumber: "14",
+						PostalCode:  "61166",
+						City:        "Kharkiv",
+					},
 					CountryCode: "UA",
 				},
 			},
 			wantErr: true,
-			err:     errors.New("invalid address format"),
-		}, // invalid address format
+			err:     errors.New("invalid postal code for country SE"),
+		}, // invalid postal code
+		{
+			name: "Empty city",
+			fields: fields{
+				Weight: 1,
+				From: Customer{
+					Name:  "Daniel",
+					Email: "daniel@sendify.se",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Volrat Thamsgatan"},
+						HouseNumber: "4",
+						PostalCode:  "41260",
+					},
+					CountryCode: "SE",
+				},
+				To: Customer{
+					Name:  "Nikita",
+					Email: "nicitch.astrashkov@gmail.com",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Prospect Nauki"},
+						HouseNumber: "14",
+						PostalCode:  "61166",
+						City:        "Kharkiv",
+					},
+					CountryCode: "UA",
+				},
+			},
+			wantErr: true,
+			err:     errors.New("empty city"),
+		}, // empty city
 		{
 			name: "Address contains unacceptable characters",
 			fields: fields{
 				Weight: 1,
 				From: Customer{
-					Name:        "Daniel",
-					Email:       "daniel@sendify.se",
-					Address:     "Volrat Thamsgatan!!! 4, Göteborg 41260", // this one is invalid
+					Name:  "Daniel",
+					Email: "daniel@sendify.se",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Volrat Thamsgatan!!!"}, // this one is invalid
+						HouseNumber: "4",
+						PostalCode:  "41260",
+						City:        "Göteborg",
+					},
 					CountryCode: "SE",
 				},
 				To: Customer{
-					Name:        "Nikita",
-					Email:       "nicitch.astrashkov@gmail.com",
-					Address:     "Prospect Nauki 14, Kharkiv 61166",
+					Name:  "Nikita",
+					Email: "nicitch.astrashkov@gmail.com",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Prospect Nauki"},
+						HouseNumber: "14",
+						PostalCode:  "61166",
+						City:        "Kharkiv",
+					},
 					CountryCode: "UA",
 				},
 			},
 			wantErr: true,
-			err:     errors.New("address contains unacceptable characters"),
+			err:     errors.New("street line contains unacceptable characters"),
 		}, // unacceptable characters in address
 		{
 			name: "Valid info",
 			fields: fields{
 				Weight: 1,
 				From: Customer{
-					Name:        "Daniel",
-					Email:       "daniel@sendify.se",
-					Address:     "Volrat Thamsgatan 4, Göteborg 41260",
+					Name:  "Daniel",
+					Email: "daniel@sendify.se",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Volrat Thamsgatan"},
+						HouseNumber: "4",
+						PostalCode:  "41260",
+						City:        "Göteborg",
+					},
 					CountryCode: "SE",
 				},
 				To: Customer{
-					Name:        "Nikita",
-					Email:       "nicitch.astrashkov@gmail.com",
-					Address:     "Prospect Nauki 14, Kharkiv 61166",
+					Name:  "Nikita",
+					Email: "nicitch.astrashkov@gmail.com",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Prospect Nauki"},
+						HouseNumber: "14",
+						PostalCode:  "61166",
+						City:        "Kharkiv",
+					},
 					CountryCode: "UA", // this one is invalid
 				},
 			},
 			wantErr: false,
 			err:     nil,
 		}, // valid info test
+		{
+			name: "Valid address with punctuation",
+			fields: fields{
+				Weight: 1,
+				From: Customer{
+					Name:  "Daniel",
+					Email: "daniel@sendify.se",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Volrat Thamsgatan"},
+						HouseNumber: "4",
+						PostalCode:  "41260",
+						City:        "Göteborg",
+					},
+					CountryCode: "SE",
+				},
+				To: Customer{
+					Name:  "Nikita",
+					Email: "nicitch.astrashkov@gmail.com",
+					Address: PostalAddress{
+						StreetLines: StreetLines{"Rue de l'Église"},
+						HouseNumber: "3-5",
+						PostalCode:  "75001",
+						City:        "Paris",
+					},
+					CountryCode: "FR",
+				},
+			},
+			wantErr: false,
+			err:     nil,
+		}, // valid address with punctuation
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := Shipment{
-				Weight: tt.fields.Weight,
-				Price:  tt.fields.Price,
-				From:   tt.fields.From,
-				To:     tt.fields.To,
+				Parcels: Parcels{{Weight: tt.fields.Weight}},
+				From:    tt.fields.From,
+				To:      tt.fields.To,
 			}
 			if err := s.Validate(); (err != nil) == tt.wantErr {
 				if err != nil { // there is error
@@ -312,14 +546,295 @@ func TestShipment_FormPrice(t *testing.T) { // test for
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := Shipment{
-				Weight: tt.fields.Weight,
-				From:   tt.fields.From,
+				Parcels: Parcels{{Weight: tt.fields.Weight}},
+				From:    tt.fields.From,
 			}
 			s.FormPrice()
-			if !assert.EqualValues(t, tt.expectedPrice, s.Price) {
-				t.Errorf("unexpected result: price should be %d; actual value %d", tt.expectedPrice, s.Price)
+			if !assert.EqualValues(t, tt.expectedPrice, s.Price.Amount) {
+				t.Errorf("unexpected result: price should be %d; actual value %d", tt.expectedPrice, s.Price.Amount)
 			}
+			assert.Equal(t, PriceCurrency, s.Price.Currency)
 		})
 	}
 
 }
+
+func TestShipment_FormPriceParcels(t *testing.T) {
+	s := Shipment{
+		Parcels: Parcels{{Weight: 5}, {Weight: 30}},
+		From:    Customer{CountryCode: "PL"},
+	}
+	s.FormPrice()
+	// 35 kg in total
+	assert.Equal(t, Price{Amount: UpTo50kgPrice * EuropeRate / 100, Currency: PriceCurrency}, s.Price)
+}
+
+func TestPostalAddress_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		address     PostalAddress
+		countryCode string
+		err         error
+	}{
+		{
+			name:        "Swedish postal code with space",
+			address:     PostalAddress{StreetLines: StreetLines{"Volrat Thamsgatan"}, HouseNumber: "4", PostalCode: "412 60", City: "Göteborg"},
+			countryCode: "SE",
+		},
+		{
+			name:        "Polish postal code",
+			address:     PostalAddress{StreetLines: StreetLines{"ul. Marszałkowska"}, HouseNumber: "10/12", PostalCode: "00-590", City: "Warszawa"},
+			countryCode: "PL",
+		},
+		{
+			name:        "Invalid Polish postal code",
+			address:     PostalAddress{StreetLines: StreetLines{"ul. Marszałkowska"}, HouseNumber: "10", PostalCode: "00590", City: "Warszawa"},
+			countryCode: "PL",
+			err:         errors.New("invalid postal code for country PL"),
+		},
+		{
+			name:        "US address without state",
+			address:     PostalAddress{StreetLines: StreetLines{"Main Street"}, HouseNumber: "1", PostalCode: "10001", City: "New York"},
+			countryCode: "US",
+			err:         errors.New("empty region"),
+		},
+		{
+			name:        "Postal code in country without postal codes",
+			address:     PostalAddress{StreetLines: StreetLines{"Queen's Road Central"}, HouseNumber: "1", PostalCode: "999077", City: "Hong Kong"},
+			countryCode: "HK",
+			err:         errors.New("postal code is not used in country"),
+		},
+		{
+			name:        "Too many street lines",
+			address:     PostalAddress{StreetLines: StreetLines{"a", "b", "c"}, PostalCode: "41260", City: "Göteborg"},
+			countryCode: "SE",
+			err:         errors.New("too many street lines"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.address.Validate(tt.countryCode)
+			if tt.err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.err.Error())
+		})
+	}
+}
+
+func TestPostalAddress_String(t *testing.T) {
+	address := PostalAddress{
+		StreetLines: StreetLines{"Volrat Thamsgatan"},
+		HouseNumber: "4",
+		PostalCode:  "41260",
+		City:        "Göteborg",
+	}
+	assert.Equal(t, "Volrat Thamsgatan 4, 41260 Göteborg", address.String())
+}
+
+func TestShipment_ValidateFieldErrors(t *testing.T) {
+	s := Shipment{
+		Parcels: Parcels{{Weight: 10}, {Weight: 0}},
+		From: Customer{
+			Name:        "Daniel",
+			Email:       "daniel@sendify?se",
+			Address:     PostalAddress{StreetLines: StreetLines{"Volrat Thamsgatan"}, HouseNumber: "4", PostalCode: "41260", City: "Göteborg"},
+			CountryCode: "SE",
+		},
+		To: Customer{
+			Name:        "Nikita",
+			Email:       "nicitch.astrashkov@gmail.com",
+			Address:     PostalAddress{StreetLines: StreetLines{"Prospect Nauki"}, HouseNumber: "14", City: "Kharkiv"},
+			CountryCode: "UA",
+		},
+	}
+
+	assert.Equal(t, validation.Errors{
+		{Pointer: "/parcels/1/weight", Code: validation.CodeOutOfRange, Message: "invalid weight"},
+		{Pointer: "/from/email", Code: validation.CodeInvalidFormat, Message: "invalid email address"},
+		{Pointer: "/to/address/postal_code", Code: validation.CodeRequired, Message: "empty postal code"},
+	}, s.Validate())
+
+	s.To.Address.PostalCode = "61166"
+	s.From.Email = "daniel@sendify.se"
+	s.Parcels = nil
+	assert.Equal(t, validation.Errors{
+		{Pointer: "/parcels", Code: validation.CodeRequired, Message: "empty parcels"},
+	}, s.Validate())
+
+	s.Parcels = make(Parcels, MaxParcels+1)
+	for i := range s.Parcels {
+		s.Parcels[i].Weight = 1
+	}
+	assert.Equal(t, validation.Errors{
+		{Pointer: "/parcels", Code: validation.CodeOutOfRange, Message: "more than 20 parcels"},
+	}, s.Validate())
+}
+
+func TestShipment_Normalize(t *testing.T) {
+	s := Shipment{Parcels: Parcels{{Weight: 5}, {Weight: 30}}}
+	s.Normalize()
+	assert.Equal(t, 35, s.Weight)
+}
+
+func TestPrintValidationProblem(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/shipment", nil)
+	w := httptest.NewRecorder()
+
+	PrintValidationProblem(w, r, validation.Errors{
+		{Pointer: "/from/email", Code: validation.CodeRequired, Message: "empty email"},
+	})
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, "application/problem+json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"type": "/problems/validation-error",
+		"title": "Validation failed",
+		"status": 422,
+		"instance": "/shipment",
+		"errors": [{"pointer": "/from/email", "code": "required", "message": "empty email"}]
+	}`, w.Body.String())
+
+	// domain validation error is responded the same way, its cause isn't exposed
+	w = httptest.NewRecorder()
+	PrintValidationProblem(w, r, errs.Wrap(errs.Validation, "saved address not found", errors.New("record not found")))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{
+		"type": "/problems/validation-error",
+		"title": "Validation failed",
+		"status": 422,
+		"instance": "/shipment",
+		"detail": "saved address not found"
+	}`, w.Body.String())
+}
+
+func TestShipment_ValidatePhone(t *testing.T) {
+	rules := validation.DefaultRules()
+	rules.Lanes = []validation.LaneRule{{From: "*", To: "!EU", RequireRecipientPhone: true}}
+	validation.SetRules(rules)
+	defer validation.SetRules(validation.DefaultRules())
+
+	s := Shipment{
+		Parcels: Parcels{{Weight: 1}},
+		From: Customer{
+			Name:        "Daniel",
+			Email:       "daniel@sendify.se",
+			Phone:       "070-123 45 67",
+			Address:     PostalAddress{StreetLines: StreetLines{"Volrat Thamsgatan"}, HouseNumber: "4", PostalCode: "41260", City: "Göteborg"},
+			CountryCode: "SE",
+		},
+		To: Customer{
+			Name:        "Nikita",
+			Email:       "nicitch.astrashkov@gmail.com",
+			Address:     PostalAddress{StreetLines: StreetLines{"Prospect Nauki"}, HouseNumber: "14", PostalCode: "61166", City: "Kharkiv"},
+			CountryCode: "UA",
+		},
+	}
+
+	assert.Equal(t, validation.Errors{
+		{Pointer: "/to/phone", Code: validation.CodeRequired, Message: "phone is required for shipments of the lane"},
+	}, s.Validate())
+
+	s.To.Phone = "050 123 4567"
+	s.Normalize()
+	assert.NoError(t, s.Validate())
+	assert.Equal(t, "+46701234567", s.From.Phone)
+	assert.Equal(t, "+380501234567", s.To.Phone)
+
+	s.To.Phone = "12"
+	assert.Equal(t, validation.Errors{
+		{Pointer: "/to/phone", Code: validation.CodeInvalidFormat, Message: "invalid phone number"},
+	}, s.Validate())
+}
+
+func TestCustomer_MatchKey(t *testing.T) {
+	customer := Customer{
+		Name:        "Daniel Svensson",
+		Email:       "daniel@sendify.se",
+		Address:     PostalAddress{StreetLines: StreetLines{"Volrat Thamsgatan"}, HouseNumber: "4", PostalCode: "41260", City: "Göteborg"},
+		CountryCode: "SE",
+	}
+
+	same := customer
+	same.Name = " daniel   SVENSSON "
+	same.Email = "Daniel@Sendify.se"
+	same.Address.City = "GÖTEBORG"
+	assert.Equal(t, customer.MatchKey(), same.MatchKey())
+
+	other := customer
+	other.Address.HouseNumber = "5"
+	assert.NotEqual(t, customer.MatchKey(), other.MatchKey())
+}
+
+func TestCustomer_Pseudonymized(t *testing.T) {
+	customer := Customer{
+		ID:          42,
+		Name:        "Daniel",
+		Email:       "daniel@sendify.se",
+		Phone:       "+46701234567",
+		Address:     PostalAddress{StreetLines: StreetLines{"Volrat Thamsgatan"}, HouseNumber: "4", PostalCode: "41260", City: "Göteborg"},
+		CountryCode: "SE",
+	}
+	erasedAt := time.Now()
+
+	assert.Equal(t, Customer{
+		ID:          42,
+		Name:        "Erased",
+		Email:       "erased-42@erased.invalid",
+		CountryCode: "SE",
+		ErasedAt:    &erasedAt,
+	}, customer.Pseudonymized(erasedAt))
+}
+
+func TestNewChanges(t *testing.T) {
+	before := Customer{
+		ID:          42,
+		Name:        "Daniel",
+		Email:       "daniel@sendify.se",
+		Phone:       "+46701234567",
+		CountryCode: "SE",
+	}
+	after := before
+	after.Phone = "+46707654321"
+	after.CountryCode = "NO"
+
+	changes, err := NewChanges(before, after)
+	if assert.NoError(t, err) {
+		assert.Equal(t, Changes{
+			"phone":        {Before: Redacted, After: Redacted},
+			"country_code": {Before: "SE", After: "NO"},
+		}, changes)
+	}
+
+	changes, err = NewChanges(nil, Shipment{Weight: 2, Price: Price{Amount: 200, Currency: PriceCurrency}, FromID: 1, ToID: 2}.AuditRecord())
+	if assert.NoError(t, err) {
+		assert.Equal(t, Changes{
+			"weight":        {After: float64(2)},
+			"price":         {After: float64(200)},
+			"customer_from": {After: float64(1)},
+			"customer_to":   {After: float64(2)},
+		}, changes)
+	}
+
+	changes, err = NewChanges(&before, (*Customer)(nil))
+	if assert.NoError(t, err) {
+		assert.Equal(t, Change{Before: Redacted}, changes["name"])
+		assert.Equal(t, Change{Before: "SE"}, changes["country_code"])
+	}
+}
+
+func TestChanges_Scan(t *testing.T) {
+	changes := Changes{"price": {Before: float64(100), After: float64(200)}}
+	value, err := changes.Value()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var scanned Changes
+	if assert.NoError(t, scanned.Scan([]byte(value.(string)))) {
+		assert.Equal(t, changes, scanned)
+	}
+}
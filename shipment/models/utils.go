@@ -2,19 +2,30 @@ package models
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/caarlos0/env"
 	"github.com/jinzhu/gorm"
 	"github.com/joho/godotenv"
-	"log"
 	"net/http"
+	"sendify_test/shipment/errs"
+	"sendify_test/shipment/logging"
+	"sendify_test/shipment/validation"
 	"time"
 )
 
-// InitGormConnection - func that initializing conn to MySQL DB
-func InitGormConnection(connectionString string) *gorm.DB {
-	db, err := gorm.Open("mysql", connectionString)
+// InitGormConnection - func that initializing conn to DB of the dialect (mysql, postgres or sqlite3)
+func InitGormConnection(dialect string, connectionString string) *gorm.DB {
+	db, err := gorm.Open(dialect, connectionString)
 	if err != nil {
-		log.Fatal("Failed to open DB connection, error: ", err.Error())
+		logging.Default().Fatal("Failed to open DB connection", "error", err)
+	}
+	// errors are returned to and logged by callers only
+	db.LogMode(false)
+	if dialect == "sqlite3" {
+		// SQLite allows single writer, concurrent writes of several
+		// connections would fail as database is locked
+		db.DB().SetMaxOpenConns(1)
+		return db
 	}
 	db.DB().SetConnMaxLifetime(time.Second)
 	db.DB().SetMaxIdleConns(4)
@@ -26,11 +37,11 @@ func InitGormConnection(connectionString string) *gorm.DB {
 func LoadEnv(appConfig interface{}) {
 	err := godotenv.Load()
 	if err != nil {
-		log.Fatal("Failed to load config, error: ", err.Error())
+		logging.Default().Fatal("Failed to load config", "error", err)
 	}
 	err = env.Parse(appConfig)
 	if err != nil {
-		log.Fatal("Failed to parse config, error: ", err.Error())
+		logging.Default().Fatal("Failed to parse config", "error", err)
 	}
 }
 
@@ -46,7 +57,7 @@ func PrintHTTPResult(w http.ResponseWriter, resultHTTPCode int, jsonAnswer inter
 
 	body, err := json.Marshal(jsonAnswer)
 	if err != nil {
-		log.Print("failed to marshal json, error: ", err.Error())
+		logging.Default().Error("Failed to marshal json", "error", err)
 		body, _ = json.Marshal(map[string]string{"error": "Internal server error"})
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write(body)
@@ -63,3 +74,57 @@ func PrintHTTPResult(w http.ResponseWriter, resultHTTPCode int, jsonAnswer inter
 	w.WriteHeader(resultHTTPCode)
 	w.Write(body)
 }
+
+// ValidationProblemType identifies problems caused by invalid request body or
+// by errs.Validation error
+const ValidationProblemType = "/problems/validation-error"
+
+// TimeoutProblemType identifies problems caused by exceeded query deadline
+const TimeoutProblemType = "/problems/timeout"
+
+// Problem - problem details object (RFC 7807)
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Errors   validation.Errors `json:"errors,omitempty"`
+}
+
+// PrintHTTPProblem - func for printing problem details as application/problem+json
+func PrintHTTPProblem(w http.ResponseWriter, problem Problem) {
+	body, err := json.Marshal(problem)
+	if err != nil {
+		logging.Default().Error("Failed to marshal json", "error", err)
+		PrintHTTPResult(w, http.StatusInternalServerError, nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+	w.WriteHeader(problem.Status)
+	w.Write(body)
+}
+
+// PrintValidationProblem - func for printing validation errors of request body
+// or errs.Validation error as 422 Unprocessable Entity, field errors are listed
+// and message of domain error is the detail
+func PrintValidationProblem(w http.ResponseWriter, r *http.Request, err error) {
+	problem := Problem{
+		Type:     ValidationProblemType,
+		Title:    "Validation failed",
+		Status:   http.StatusUnprocessableEntity,
+		Instance: r.URL.Path,
+	}
+
+	var fieldErrors validation.Errors
+	if errors.As(err, &fieldErrors) {
+		problem.Errors = fieldErrors
+	} else if message := errs.Message(err); message != "" {
+		problem.Detail = message
+	} else {
+		problem.Detail = err.Error()
+	}
+
+	PrintHTTPProblem(w, problem)
+}
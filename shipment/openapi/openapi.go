@@ -0,0 +1,179 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+//go:embed openapi.json
+var document []byte
+
+// Document is subset of OpenAPI 3 specification of the API requests and
+// responses are validated by. It's maintained by hand in openapi.json and
+// checked against routes and models by tests
+type Document struct {
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Deprecated  bool                 `json:"deprecated"`
+	Parameters  []Parameter          `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Content map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Load parses embedded document and resolves references of its schemas
+func Load() (*Document, error) {
+	var d Document
+	if err := json.Unmarshal(document, &d); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+
+	for path, operations := range d.Paths {
+		for method, operation := range operations {
+			if err := d.resolveOperation(operation); err != nil {
+				return nil, fmt.Errorf("invalid operation %s %s: %w", strings.ToUpper(method), path, err)
+			}
+		}
+	}
+	for name, schema := range d.Components.Schemas {
+		if err := d.resolve(schema); err != nil {
+			return nil, fmt.Errorf("invalid schema %s: %w", name, err)
+		}
+	}
+	return &d, nil
+}
+
+func (d *Document) resolveOperation(operation *Operation) error {
+	for _, parameter := range operation.Parameters {
+		if err := d.resolve(parameter.Schema); err != nil {
+			return err
+		}
+	}
+	if operation.RequestBody != nil {
+		for _, mediaType := range operation.RequestBody.Content {
+			if err := d.resolve(mediaType.Schema); err != nil {
+				return err
+			}
+		}
+	}
+	for _, response := range operation.Responses {
+		for _, mediaType := range response.Content {
+			if err := d.resolve(mediaType.Schema); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Find returns operation serving method and path, e.g. "GET" and
+// "/shipment/1", with values of path parameters. Paths without parameters
+// take precedence, as mux routes are registered so
+func (d *Document) Find(method, path string) (*Operation, map[string]string, bool) {
+	var (
+		found    *Operation
+		params   map[string]string
+		literals = -1
+	)
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for template, operations := range d.Paths {
+		operation, ok := operations[strings.ToLower(method)]
+		if !ok {
+			continue
+		}
+
+		values, matched, ok := matchPath(strings.Split(strings.Trim(template, "/"), "/"), segments)
+		if ok && matched > literals {
+			found, params, literals = operation, values, matched
+		}
+	}
+	return found, params, found != nil
+}
+
+// matchPath returns values of parameters of template segments and number
+// of matched literal segments
+func matchPath(template, segments []string) (map[string]string, int, bool) {
+	if len(template) != len(segments) {
+		return nil, 0, false
+	}
+
+	values := map[string]string{}
+	var literals int
+	for i, segment := range template {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if segments[i] == "" {
+				return nil, 0, false
+			}
+			values[strings.Trim(segment, "{}")] = segments[i]
+			continue
+		}
+		if segment != segments[i] {
+			return nil, 0, false
+		}
+		literals++
+	}
+	return values, literals, true
+}
+
+// Handler serves the document
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(document)
+	})
+}
+
+// uiPage renders the document served at /openapi.json by Swagger UI loaded
+// from CDN
+const uiPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Shipment service API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@4.15.5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@4.15.5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+// UIHandler serves Swagger UI page of the document
+func UIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(uiPage))
+	})
+}
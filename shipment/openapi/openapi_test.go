@@ -0,0 +1,270 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	v1 "sendify_test/shipment/api/v1"
+	v2 "sendify_test/shipment/api/v2"
+	"sendify_test/shipment/controller"
+	"sendify_test/shipment/logging"
+	"sendify_test/shipment/models"
+	"sendify_test/shipment/openapi"
+	"sendify_test/shipment/ratelimit"
+	"sendify_test/shipment/validation"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func loadDocument(t *testing.T) *openapi.Document {
+	document, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return document
+}
+
+// routeVariables matches variables of mux templates with patterns,
+// e.g. "{id:[0-9]+}"
+var routeVariables = regexp.MustCompile(`\{(\w+):[^}]+\}`)
+
+// TestDocument_Routes fails when routes of the router and paths of the
+// document drift apart
+func TestDocument_Routes(t *testing.T) {
+	document := loadDocument(t)
+
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.Limit{Count: 1, Period: time.Minute}, nil)
+	router := controller.NewRouter(controller.NewApiController(nil, nil), controller.RouterConfig{
+		Logger:        logging.New(ioutil.Discard, logging.LevelError),
+		IPLimiter:     limiter,
+		CallerLimiter: limiter,
+	})
+
+	var routes []string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		// subrouters have no methods
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			routes = append(routes, method+" "+routeVariables.ReplaceAllString(template, "{$1}"))
+		}
+		return nil
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var operations []string
+	for path, pathOperations := range document.Paths {
+		for method := range pathOperations {
+			operations = append(operations, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	sort.Strings(routes)
+	sort.Strings(operations)
+	assert.Equal(t, routes, operations, "routes of the router differ from operations of OpenAPI document")
+}
+
+// TestDocument_Schemas fails when JSON fields of models and properties of
+// their schemas drift apart
+func TestDocument_Schemas(t *testing.T) {
+	document := loadDocument(t)
+
+	tests := []struct {
+		schema string
+		model  interface{}
+	}{
+		{"MergeRequest", models.MergeRequest{}},
+		{"CustomerMerge", models.CustomerMerge{}},
+		{"AuditEntry", models.AuditEntry{}},
+		{"Change", models.Change{}},
+
+		{"v1.Shipment", v1.Shipment{}},
+		{"v1.Customer", models.Customer{}},
+		{"v1.PostalAddress", models.PostalAddress{}},
+		{"v1.SavedAddress", models.SavedAddress{}},
+		{"v1.DuplicateCandidate", models.DuplicateCandidate{}},
+		{"v1.CustomerShipment", v1.CustomerShipment{}},
+		{"v1.CustomerDataExport", v1.CustomerDataExport{}},
+
+		{"v2.Shipment", v2.Shipment{}},
+		{"v2.Parcel", v2.Parcel{}},
+		{"v2.Price", v2.Price{}},
+		{"v2.Customer", v2.Customer{}},
+		{"v2.Address", v2.Address{}},
+		{"v2.SavedAddress", v2.SavedAddress{}},
+		{"v2.DuplicateCandidate", v2.DuplicateCandidate{}},
+		{"v2.CustomerShipment", v2.CustomerShipment{}},
+		{"v2.CustomerDataExport", v2.CustomerDataExport{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.schema, func(t *testing.T) {
+			schema, ok := document.Components.Schemas[tt.schema]
+			if !assert.True(t, ok, "schema is missing") {
+				return
+			}
+
+			fields, alwaysPresent := jsonFields(reflect.TypeOf(tt.model))
+			assert.Equal(t, fields, schema.PropertyNames(), "fields of model differ from properties of schema")
+			assert.Subset(t, schema.Required, alwaysPresent, "fields present in every response aren't required")
+		})
+	}
+}
+
+// jsonFields returns sorted names of JSON fields of struct type, and names
+// of fields encoded even if they are empty
+func jsonFields(typ reflect.Type) (fields, alwaysPresent []string) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || field.PkgPath != "" {
+			continue
+		}
+
+		name, options := tag, ""
+		if comma := strings.Index(tag, ","); comma >= 0 {
+			name, options = tag[:comma], tag[comma+1:]
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, name)
+
+		// omitempty doesn't omit structs
+		if !strings.Contains(options, "omitempty") || field.Type.Kind() == reflect.Struct {
+			alwaysPresent = append(alwaysPresent, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields, alwaysPresent
+}
+
+func TestHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	openapi.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json; charset=utf-8", recorder.Header().Get("Content-Type"))
+
+	var document map[string]interface{}
+	if assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &document)) {
+		assert.Equal(t, "3.0.3", document["openapi"])
+	}
+}
+
+func TestDocument_ValidateRequest(t *testing.T) {
+	document := loadDocument(t)
+
+	validShipment := `{"weight": 10,
+		"from": {"name": "Daniel Svensson", "email": "daniel@sendify.se", "country_code": "SE",
+			"address": {"street_lines": ["Volrat Thamsgatan"], "house_number": "4", "postal_code": "41260", "city": "Göteborg"}},
+		"to": {"address_id": 1}}`
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantErrors validation.Errors
+		wantErr    bool
+	}{
+		{"valid body", http.MethodPost, "/shipment", validShipment, nil, false},
+		{"valid query", http.MethodGet, "/audit?entity=customer&id=1&limit=10", "", nil, false},
+		{"valid path", http.MethodGet, "/shipment/1", "", nil, false},
+		{"undocumented operation", http.MethodGet, "/unknown", "", nil, false},
+		{"malformed body", http.MethodPost, "/shipment", `{"weight":`, nil, true},
+		{"missing body", http.MethodPost, "/customer/merge", "", validation.Errors{
+			{Pointer: "", Code: validation.CodeRequired, Message: "body is required"},
+		}, true},
+		{"invalid body", http.MethodPost, "/shipment", `{"weight": "10", "from": {}}`, validation.Errors{
+			{Pointer: "/to", Code: validation.CodeRequired, Message: "is required"},
+			{Pointer: "/weight", Code: validation.CodeInvalidFormat, Message: "must be integer"},
+		}, true},
+		{"invalid body of v2", http.MethodPost, "/v2/shipment", `{"parcels": [{"weight": "10"}], "from": {}}`, validation.Errors{
+			{Pointer: "/to", Code: validation.CodeRequired, Message: "is required"},
+			{Pointer: "/parcels/0/weight", Code: validation.CodeInvalidFormat, Message: "must be integer"},
+		}, true},
+		{"missing query parameter", http.MethodGet, "/audit", "", validation.Errors{
+			{Pointer: "/query/entity", Code: validation.CodeRequired, Message: "is required"},
+		}, true},
+		{"invalid query parameters", http.MethodGet, "/audit?entity=account&limit=1001", "", validation.Errors{
+			{Pointer: "/query/entity", Code: validation.CodeUnknownValue, Message: "must be one of shipment, customer, saved_address"},
+			{Pointer: "/query/limit", Code: validation.CodeOutOfRange, Message: "must be between 1 and 1000"},
+		}, true},
+		{"invalid path parameter", http.MethodGet, "/shipment/abc", "", validation.Errors{
+			{Pointer: "/path/id", Code: validation.CodeInvalidFormat, Message: "must be integer"},
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+
+			err := document.ValidateRequest(r)
+			if !tt.wantErr {
+				assert.NoError(t, err)
+
+				// body is left for handler
+				body, _ := ioutil.ReadAll(r.Body)
+				assert.Equal(t, tt.body, string(body))
+				return
+			}
+
+			if assert.Error(t, err) && tt.wantErrors != nil {
+				assert.Equal(t, tt.wantErrors, err)
+			}
+		})
+	}
+}
+
+func TestDocument_ValidateResponse(t *testing.T) {
+	document := loadDocument(t)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		status      int
+		contentType string
+		body        string
+		wantErr     bool
+	}{
+		{"valid", http.MethodPost, "/customer/merge", http.StatusOK, "application/json; charset=utf-8",
+			`{"survivor_id": 1, "merges": []}`, false},
+		{"valid error", http.MethodGet, "/shipment/1", http.StatusNotFound, "application/json; charset=utf-8",
+			`{"error": "shipment not found"}`, false},
+		{"not JSON", http.MethodGet, "/metrics", http.StatusOK, "text/plain; version=0.0.4", `# metrics`, false},
+		{"undocumented operation", http.MethodGet, "/unknown", http.StatusOK, "application/json", `{}`, true},
+		{"undocumented status", http.MethodGet, "/shipment/1", http.StatusTeapot, "application/json", `{}`, true},
+		{"undocumented content type", http.MethodGet, "/shipment/1", http.StatusOK, "text/html", `<html>`, true},
+		{"missing field", http.MethodGet, "/shipment/1", http.StatusNotFound, "application/json", `{}`, true},
+		{"unknown field", http.MethodPost, "/customer/merge", http.StatusOK, "application/json",
+			`{"survivor_id": 1, "merges": [], "unknown": 1}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := document.ValidateResponse(tt.method, tt.path, tt.status, tt.contentType, []byte(tt.body))
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sendify_test/shipment/validation"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const refPrefix = "#/components/schemas/"
+
+// Schema is subset of OpenAPI 3 schema object, schema without type accepts
+// any value
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Nullable             bool               `json:"nullable"`
+	Enum                 []interface{}      `json:"enum"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Pattern              string             `json:"pattern"`
+	Items                *Schema            `json:"items"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *Additional        `json:"additionalProperties"`
+
+	// resolved is schema the reference points to
+	resolved *Schema
+	pattern  *regexp.Regexp
+}
+
+// Additional is either boolean or schema of properties missing in
+// Properties, properties are allowed unless it's false
+type Additional struct {
+	Forbidden bool
+	Schema    *Schema
+}
+
+func (a *Additional) UnmarshalJSON(data []byte) error {
+	var allowed bool
+	if err := json.Unmarshal(data, &allowed); err == nil {
+		a.Forbidden = !allowed
+		return nil
+	}
+	return json.Unmarshal(data, &a.Schema)
+}
+
+// resolve links references to components and compiles patterns
+func (d *Document) resolve(schema *Schema) error {
+	if schema == nil {
+		return nil
+	}
+
+	if schema.Ref != "" {
+		target, ok := d.Components.Schemas[strings.TrimPrefix(schema.Ref, refPrefix)]
+		if !strings.HasPrefix(schema.Ref, refPrefix) || !ok {
+			return fmt.Errorf("unknown reference %s", schema.Ref)
+		}
+		schema.resolved = target
+		return nil
+	}
+
+	if schema.Pattern != "" {
+		pattern, err := regexp.Compile(schema.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", schema.Pattern, err)
+		}
+		schema.pattern = pattern
+	}
+	if err := d.resolve(schema.Items); err != nil {
+		return err
+	}
+	for _, property := range schema.Properties {
+		if err := d.resolve(property); err != nil {
+			return err
+		}
+	}
+	if schema.AdditionalProperties != nil {
+		return d.resolve(schema.AdditionalProperties.Schema)
+	}
+	return nil
+}
+
+// target returns schema the reference points to, or schema itself
+func (s *Schema) target() *Schema {
+	for s.resolved != nil {
+		s = s.resolved
+	}
+	return s
+}
+
+// PropertyNames returns sorted names of properties of the schema
+func (s *Schema) PropertyNames() []string {
+	s = s.target()
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validate adds errors of value decoded from JSON, pointer is JSON pointer
+// of the value
+func (s *Schema) validate(v *validation.Validator, pointer string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s = s.target()
+
+	if value == nil {
+		if s.Type != "" && !s.Nullable {
+			v.AddError(pointer, validation.CodeRequired, "must not be null")
+		}
+		return
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		v.AddError(pointer, validation.CodeUnknownValue, fmt.Sprintf("must be one of %s", formatEnum(s.Enum)))
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			v.AddError(pointer, validation.CodeInvalidFormat, "must be object")
+			return
+		}
+		s.validateObject(v, pointer, object)
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			v.AddError(pointer, validation.CodeInvalidFormat, "must be array")
+			return
+		}
+		for i, item := range items {
+			s.Items.validate(v, pointer+"/"+strconv.Itoa(i), item)
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			v.AddError(pointer, validation.CodeInvalidFormat, "must be string")
+			return
+		}
+		s.validateString(v, pointer, text)
+	case "integer", "number":
+		number, ok := value.(float64)
+		if !ok || (s.Type == "integer" && number != math.Trunc(number)) {
+			v.AddError(pointer, validation.CodeInvalidFormat, "must be "+s.Type)
+			return
+		}
+		if (s.Minimum != nil && number < *s.Minimum) || (s.Maximum != nil && number > *s.Maximum) {
+			v.AddError(pointer, validation.CodeOutOfRange, "must be "+s.formatRange())
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			v.AddError(pointer, validation.CodeInvalidFormat, "must be boolean")
+		}
+	}
+}
+
+func (s *Schema) validateObject(v *validation.Validator, pointer string, object map[string]interface{}) {
+	for _, name := range s.Required {
+		if _, ok := object[name]; !ok {
+			v.AddError(pointer+"/"+escapePointer(name), validation.CodeRequired, "is required")
+		}
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fieldPointer := pointer + "/" + escapePointer(name)
+		if property, ok := s.Properties[name]; ok {
+			property.validate(v, fieldPointer, object[name])
+		} else if s.AdditionalProperties != nil && s.AdditionalProperties.Forbidden {
+			v.AddError(fieldPointer, validation.CodeNotAllowed, "unknown field")
+		} else if s.AdditionalProperties != nil {
+			s.AdditionalProperties.Schema.validate(v, fieldPointer, object[name])
+		}
+	}
+}
+
+func (s *Schema) validateString(v *validation.Validator, pointer string, text string) {
+	length := len([]rune(text))
+	if s.MinLength != nil && length < *s.MinLength {
+		v.AddError(pointer, validation.CodeTooShort, fmt.Sprintf("must be at least %d characters", *s.MinLength))
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		v.AddError(pointer, validation.CodeTooLong, fmt.Sprintf("must be at most %d characters", *s.MaxLength))
+	}
+	if s.pattern != nil && !s.pattern.MatchString(text) {
+		v.AddError(pointer, validation.CodeInvalidCharacters, "must match "+s.Pattern)
+	}
+	if s.Format == "date-time" {
+		if _, err := time.Parse(time.RFC3339Nano, text); err != nil {
+			v.AddError(pointer, validation.CodeInvalidFormat, "must be RFC 3339 date-time")
+		}
+	}
+}
+
+func (s *Schema) formatRange() string {
+	switch {
+	case s.Minimum != nil && s.Maximum != nil:
+		return fmt.Sprintf("between %v and %v", *s.Minimum, *s.Maximum)
+	case s.Minimum != nil:
+		return fmt.Sprintf("at least %v", *s.Minimum)
+	default:
+		return fmt.Sprintf("at most %v", *s.Maximum)
+	}
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, allowed := range enum {
+		if reflect.DeepEqual(allowed, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatEnum(enum []interface{}) string {
+	values := make([]string, 0, len(enum))
+	for _, value := range enum {
+		values = append(values, fmt.Sprint(value))
+	}
+	return strings.Join(values, ", ")
+}
+
+// escapePointer escapes name for JSON pointer (RFC 6901)
+func escapePointer(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
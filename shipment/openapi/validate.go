@@ -0,0 +1,139 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sendify_test/shipment/validation"
+	"strconv"
+	"strings"
+)
+
+// ValidateRequest checks parameters and JSON body of the request against
+// operation serving it, requests of undocumented operations are left to
+// router. Body is read and replaced, so handlers can read it again.
+// Errors are validation.Errors with pointers "/path/<name>" and
+// "/query/<name>" for parameters and pointers into the body otherwise
+func (d *Document) ValidateRequest(r *http.Request) error {
+	operation, pathParams, ok := d.Find(r.Method, r.URL.Path)
+	if !ok {
+		return nil
+	}
+
+	v := validation.NewValidator()
+	query := r.URL.Query()
+	for _, parameter := range operation.Parameters {
+		var (
+			value   string
+			present bool
+		)
+		switch parameter.In {
+		case "path":
+			value, present = pathParams[parameter.Name]
+		case "query":
+			value = query.Get(parameter.Name)
+			present = value != ""
+		default:
+			continue
+		}
+
+		pointer := "/" + parameter.In + "/" + escapePointer(parameter.Name)
+		if !present {
+			if parameter.Required {
+				v.AddError(pointer, validation.CodeRequired, "is required")
+			}
+			continue
+		}
+		parameter.Schema.validate(v, pointer, parseParameter(parameter.Schema, value))
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	if operation.RequestBody == nil {
+		return nil
+	}
+	mediaType, ok := operation.RequestBody.Content["application/json"]
+	if !ok {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read body: %w", err)
+	}
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if operation.RequestBody.Required {
+			v.AddError("", validation.CodeRequired, "body is required")
+		}
+		return v.Err()
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+	mediaType.Schema.validate(v, "", value)
+	return v.Err()
+}
+
+// ValidateResponse checks JSON body of the response against operation
+// serving method and path, e.g. "GET" and "/shipment/1". It fails on
+// status or content type missing in the operation, bodies of other types
+// aren't checked
+func (d *Document) ValidateResponse(method, path string, status int, contentType string, body []byte) error {
+	operation, _, ok := d.Find(method, path)
+	if !ok {
+		return fmt.Errorf("operation %s %s isn't documented", method, path)
+	}
+
+	response, ok := operation.Responses[strconv.Itoa(status)]
+	if !ok {
+		return fmt.Errorf("status %d of %s %s isn't documented", status, method, path)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("invalid content type %q: %w", contentType, err)
+	}
+	content, ok := response.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("content type %s of status %d of %s %s isn't documented", mediaType, status, method, path)
+	}
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+	v := validation.NewValidator()
+	content.Schema.validate(v, "", value)
+	return v.Err()
+}
+
+// parseParameter converts value of parameter to type of its schema, so it's
+// validated as JSON value. Value is left as is when it can't be converted
+func parseParameter(schema *Schema, value string) interface{} {
+	if schema == nil {
+		return value
+	}
+	switch schema.target().Type {
+	case "integer", "number":
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			return number
+		}
+	case "boolean":
+		if boolean, err := strconv.ParseBool(value); err == nil {
+			return boolean
+		}
+	}
+	return value
+}
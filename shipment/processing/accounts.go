@@ -0,0 +1,95 @@
+package processing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sendify_test/shipment/auth"
+	"sendify_test/shipment/errs"
+	"sendify_test/shipment/models"
+)
+
+// ErrInvalidAPIKey is returned when API key is unknown or revoked
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// ErrAPIKeyNotFound is returned when there is no active API key to revoke
+var ErrAPIKeyNotFound = errs.New(errs.NotFound, "API key not found")
+
+// AuthenticateAPIKey returns caller the API key belongs to
+func (s service) AuthenticateAPIKey(ctx context.Context, key string) (auth.Caller, error) {
+	if key == "" {
+		return auth.Caller{}, ErrInvalidAPIKey
+	}
+
+	apiKey, err := s.accountsRepo.GetActiveAPIKeyByHash(ctx, auth.HashAPIKey(key))
+	if errs.Is(err, errs.NotFound) {
+		return auth.Caller{}, ErrInvalidAPIKey
+	} else if err != nil {
+		return auth.Caller{}, err
+	}
+
+	return auth.Caller{
+		AccountID: apiKey.AccountID,
+		Role:      auth.Role(apiKey.Role),
+		APIKeyID:  apiKey.ID,
+	}, nil
+}
+
+func (s service) GetAllAccounts(ctx context.Context) (models.Accounts, error) {
+	return s.accountsRepo.GetAllAccounts(ctx)
+}
+
+// CreateAccount creates account with its first API key having admin role,
+// returns the key itself, which is not stored
+func (s service) CreateAccount(ctx context.Context, name string) (models.Account, models.APIKey, string, error) {
+	if name == "" {
+		return models.Account{}, models.APIKey{}, "", errs.New(errs.Validation, "empty account name")
+	}
+
+	account := models.Account{Name: name}
+	if err := s.accountsRepo.InsertAccount(ctx, &account); err != nil {
+		return models.Account{}, models.APIKey{}, "", err
+	}
+
+	apiKey, key, err := s.CreateAPIKey(ctx, account.ID, "default", auth.RoleAdmin)
+	if err != nil {
+		return models.Account{}, models.APIKey{}, "", err
+	}
+
+	return account, apiKey, key, nil
+}
+
+// CreateAPIKey issues new API key of the account with the role, returns
+// the key itself, which is not stored
+func (s service) CreateAPIKey(ctx context.Context, accountID int, name string, role auth.Role) (models.APIKey, string, error) {
+	if _, ok := auth.ParseRole(string(role)); !ok {
+		return models.APIKey{}, "", errs.New(errs.Validation, fmt.Sprintf("unknown role %q", role))
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+
+	apiKey := models.APIKey{
+		AccountID: accountID,
+		Name:      name,
+		Role:      string(role),
+		Prefix:    prefix,
+		Hash:      hash,
+	}
+	if err := s.accountsRepo.InsertAPIKey(ctx, &apiKey); err != nil {
+		return models.APIKey{}, "", err
+	}
+
+	return apiKey, key, nil
+}
+
+func (s service) RevokeAPIKey(ctx context.Context, id int) error {
+	err := s.accountsRepo.RevokeAPIKey(ctx, id)
+	if errs.Is(err, errs.NotFound) {
+		return ErrAPIKeyNotFound
+	}
+
+	return err
+}
@@ -0,0 +1,152 @@
+package processing
+
+import (
+	"sendify_test/shipment/models"
+	"sort"
+	"strings"
+)
+
+const (
+	// duplicateScoreThreshold is minimal score of customers pair to be
+	// reported as duplicate candidate
+	duplicateScoreThreshold = 0.8
+
+	nameWeight    = 0.5
+	emailWeight   = 0.3
+	addressWeight = 0.2
+)
+
+// findDuplicateCandidates compares customers sharing email or address and
+// returns pairs which are likely the same person, sorted by score
+func findDuplicateCandidates(customers models.Customers) models.DuplicateCandidates {
+	// only customers sharing email or address are compared, so the job
+	// doesn't have to compare every pair of customers
+	blocks := map[string][]int{}
+	for i, customer := range customers {
+		emailKey := "email:" + models.NormalizeText(customer.Email)
+		addressKey := "address:" + models.NormalizeText(customer.CountryCode+" "+customer.Address.String())
+		blocks[emailKey] = append(blocks[emailKey], i)
+		blocks[addressKey] = append(blocks[addressKey], i)
+	}
+
+	type pair struct{ first, second int }
+	compared := map[pair]bool{}
+
+	var candidates models.DuplicateCandidates
+	for _, block := range blocks {
+		for i := 0; i < len(block); i++ {
+			for j := i + 1; j < len(block); j++ {
+				p := pair{block[i], block[j]}
+				if compared[p] {
+					continue
+				}
+				compared[p] = true
+
+				first, second := customers[p.first], customers[p.second]
+				score, reasons := duplicateScore(first, second)
+				if score < duplicateScoreThreshold {
+					continue
+				}
+
+				candidates = append(candidates, models.DuplicateCandidate{
+					First:   first,
+					Second:  second,
+					Score:   score,
+					Reasons: reasons,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		if candidates[i].First.ID != candidates[j].First.ID {
+			return candidates[i].First.ID < candidates[j].First.ID
+		}
+		return candidates[i].Second.ID < candidates[j].Second.ID
+	})
+	return candidates
+}
+
+// duplicateScore returns weighted similarity of customers in [0, 1] range
+// and list of reasons describing it
+func duplicateScore(first, second models.Customer) (float64, []string) {
+	var reasons []string
+
+	nameSimilarity := similarity(models.NormalizeText(first.Name), models.NormalizeText(second.Name))
+	if nameSimilarity == 1 {
+		reasons = append(reasons, "same name")
+	} else if nameSimilarity >= duplicateScoreThreshold {
+		reasons = append(reasons, "similar name")
+	}
+
+	var emailSimilarity float64
+	if strings.EqualFold(first.Email, second.Email) {
+		emailSimilarity = 1
+		reasons = append(reasons, "same email")
+	}
+
+	addressSimilarity := similarity(
+		models.NormalizeText(first.Address.String()),
+		models.NormalizeText(second.Address.String()),
+	)
+	if first.CountryCode != second.CountryCode {
+		addressSimilarity = 0
+	}
+	if addressSimilarity == 1 {
+		reasons = append(reasons, "same address")
+	} else if addressSimilarity >= duplicateScoreThreshold {
+		reasons = append(reasons, "similar address")
+	}
+
+	score := nameWeight*nameSimilarity + emailWeight*emailSimilarity + addressWeight*addressSimilarity
+	return score, reasons
+}
+
+// similarity returns 1 - normalized Levenshtein distance of strings
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	maxLength := len(ra)
+	if len(rb) > maxLength {
+		maxLength = len(rb)
+	}
+	if maxLength == 0 {
+		return 1
+	}
+
+	return 1 - float64(levenshtein(ra, rb))/float64(maxLength)
+}
+
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+func min(values ...int) int {
+	result := values[0]
+	for _, v := range values[1:] {
+		if v < result {
+			result = v
+		}
+	}
+	return result
+}
@@ -0,0 +1,42 @@
+package processing
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sendify_test/shipment/models"
+	"testing"
+)
+
+func TestFindDuplicateCandidates(t *testing.T) {
+	gothenburg := models.PostalAddress{StreetLines: models.StreetLines{"Volrat Thamsgatan"}, HouseNumber: "4", PostalCode: "41260", City: "Göteborg"}
+	kharkiv := models.PostalAddress{StreetLines: models.StreetLines{"Prospect Nauki"}, HouseNumber: "14", PostalCode: "61166", City: "Kharkiv"}
+
+	customers := models.Customers{
+		{ID: 1, Name: "Daniel Svensson", Email: "daniel@sendify.se", Address: gothenburg, CountryCode: "SE"},
+		{ID: 2, Name: "Daniel  Svenson", Email: "Daniel@sendify.se", Address: gothenburg, CountryCode: "SE"},
+		{ID: 3, Name: "Anna Svensson", Email: "anna@sendify.se", Address: gothenburg, CountryCode: "SE"},
+		{ID: 4, Name: "Nikita", Email: "nicitch.astrashkov@gmail.com", Address: kharkiv, CountryCode: "UA"},
+		{ID: 5, Name: "Daniel Svensson", Email: "daniel@sendify.se", Address: kharkiv, CountryCode: "UA"},
+	}
+
+	candidates := findDuplicateCandidates(customers)
+	if !assert.Len(t, candidates, 2) {
+		return
+	}
+
+	assert.Equal(t, 1, candidates[0].First.ID)
+	assert.Equal(t, 2, candidates[0].Second.ID)
+	assert.Equal(t, []string{"similar name", "same email", "same address"}, candidates[0].Reasons)
+	assert.InDelta(t, 0.97, candidates[0].Score, 0.01)
+
+	assert.Equal(t, 1, candidates[1].First.ID)
+	assert.Equal(t, 5, candidates[1].Second.ID)
+	assert.Equal(t, []string{"same name", "same email"}, candidates[1].Reasons)
+	assert.InDelta(t, 0.8, candidates[1].Score, 0.01)
+}
+
+func TestSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, similarity("", ""))
+	assert.Equal(t, 1.0, similarity("björn", "björn"))
+	assert.Equal(t, 0.8, similarity("björn", "bjorn"))
+	assert.Equal(t, 0.0, similarity("abc", "xyz"))
+}
@@ -0,0 +1,40 @@
+package processing
+
+import (
+	"context"
+	"fmt"
+	repo "sendify_test/shipment/db"
+	"sendify_test/shipment/errs"
+)
+
+// ErrMatchKeysPending is returned while match keys of customers stored before
+// they became blind indexes aren't recomputed, lookups by them would miss
+// customers and duplicate them
+var ErrMatchKeysPending = errs.New(errs.Unavailable, "match keys of customers are pending, run rotate-keys")
+
+// CheckReadiness reports whether DB is reachable, its schema is migrated to
+// the version service relies on and match keys of customers are recomputed
+func (s service) CheckReadiness(ctx context.Context) error {
+	if err := s.healthRepo.Ping(ctx); err != nil {
+		return errs.Wrap(errs.Unavailable, "database is unreachable", err)
+	}
+
+	version, err := s.healthRepo.GetSchemaVersion(ctx)
+	if err != nil {
+		return errs.Wrap(errs.Unavailable, "failed to check schema version", err)
+	}
+	if version < repo.SchemaVersion {
+		return errs.New(errs.Unavailable,
+			fmt.Sprintf("database schema version %d is older than required %d", version, repo.SchemaVersion))
+	}
+
+	pending, err := s.healthRepo.CountPendingMatchKeys(ctx)
+	if err != nil {
+		return errs.Wrap(errs.Unavailable, "failed to check match keys of customers", err)
+	}
+	if pending > 0 {
+		return ErrMatchKeysPending
+	}
+
+	return nil
+}
@@ -1,45 +1,131 @@
 package processing
 
 import (
-	"errors"
-	"github.com/jinzhu/gorm"
+	"context"
+	"sendify_test/shipment/auth"
 	repo "sendify_test/shipment/db"
+	"sendify_test/shipment/errs"
+	"sendify_test/shipment/metrics"
 	"sendify_test/shipment/models"
+	"time"
 )
 
+// ErrSavedAddressNotFound is returned when saved address is missing in
+// address book of the account
+var ErrSavedAddressNotFound = errs.New(errs.NotFound, "saved address not found")
+
+// ErrCustomerNotFound is returned when customer is missing in the account
+var ErrCustomerNotFound = errs.New(errs.NotFound, "customer not found")
+
+// ErrShipmentNotFound is returned when shipment is missing in the account
+var ErrShipmentNotFound = errs.New(errs.NotFound, "shipment not found")
+
+// TimeoutError is returned when DB query of the operation exceeds its
+// deadline, see repo.SetQueryTimeouts
+type TimeoutError = repo.TimeoutError
+
 type service struct {
-	customersRepo *repo.CustomersRepo
-	shipmentsRepo *repo.ShipmentsRepo
+	transactor      repo.UnitOfWork
+	customersRepo   repo.CustomerRepository
+	shipmentsRepo   repo.ShipmentRepository
+	addressBookRepo repo.AddressBookRepository
+	accountsRepo    repo.AccountRepository
+	auditRepo       repo.AuditRepository
+	healthRepo      repo.HealthRepository
 }
 
 type Service interface {
-	GetShipmentDetailsByID(id int) (models.Shipment, error)
-	CreateNewShipment(shipment models.Shipment) error
-	GetAllShipments() (models.Shipments, error)
+	GetShipmentDetailsByID(ctx context.Context, accountID, id int) (models.Shipment, error)
+	CreateNewShipment(ctx context.Context, actor models.Actor, shipment models.Shipment) error
+	GetAllShipments(ctx context.Context, accountID int) (models.Shipments, error)
+	ResolveSavedAddresses(ctx context.Context, shipment models.Shipment) (models.Shipment, error)
+
+	GetAddressBook(ctx context.Context, accountID int) (models.SavedAddresses, error)
+	GetSavedAddress(ctx context.Context, accountID, id int) (models.SavedAddress, error)
+	CreateSavedAddress(ctx context.Context, actor models.Actor, address models.SavedAddress) (models.SavedAddress, error)
+	UpdateSavedAddress(ctx context.Context, actor models.Actor, address models.SavedAddress) (models.SavedAddress, error)
+	DeleteSavedAddress(ctx context.Context, actor models.Actor, accountID, id int) error
+
+	FindDuplicateCustomers(ctx context.Context, accountID int) (models.DuplicateCandidates, error)
+	MergeCustomers(ctx context.Context, actor models.Actor, accountID int, request models.MergeRequest) ([]models.CustomerMerge, error)
+	ExportCustomerData(ctx context.Context, accountID, id int) (models.CustomerDataExport, error)
+	EraseCustomer(ctx context.Context, actor models.Actor, accountID, id int) (models.Customer, error)
+	ApplyRetentionPolicy(ctx context.Context, years int) (int, error)
+	ReencryptPersonalData(ctx context.Context) (customers, addresses int, collisions models.DuplicateCandidates, err error)
+
+	AuthenticateAPIKey(ctx context.Context, key string) (auth.Caller, error)
+	GetAllAccounts(ctx context.Context) (models.Accounts, error)
+	CreateAccount(ctx context.Context, name string) (models.Account, models.APIKey, string, error)
+	CreateAPIKey(ctx context.Context, accountID int, name string, role auth.Role) (models.APIKey, string, error)
+	RevokeAPIKey(ctx context.Context, id int) error
+
+	GetAuditLog(ctx context.Context, accountID int, entity string, entityID, limit int) (models.AuditEntries, error)
+
+	CheckReadiness(ctx context.Context) error
 }
 
 func NewService(
-	shipmentsRepo *repo.ShipmentsRepo,
-	customersRepo *repo.CustomersRepo,
+	transactor repo.UnitOfWork,
+	shipmentsRepo repo.ShipmentRepository,
+	customersRepo repo.CustomerRepository,
+	addressBookRepo repo.AddressBookRepository,
+	accountsRepo repo.AccountRepository,
+	auditRepo repo.AuditRepository,
+	healthRepo repo.HealthRepository,
 ) Service {
-	return &service{
-		shipmentsRepo: shipmentsRepo,
-		customersRepo: customersRepo,
+	svc := &service{
+		transactor:      transactor,
+		shipmentsRepo:   shipmentsRepo,
+		customersRepo:   customersRepo,
+		addressBookRepo: addressBookRepo,
+		accountsRepo:    accountsRepo,
+		auditRepo:       auditRepo,
+		healthRepo:      healthRepo,
 	}
+
+	// spans are dropped unless tracing is set up, see tracing.Setup
+	return tracedService{next: svc}
 }
 
-func (s service) GetShipmentDetailsByID(id int) (models.Shipment, error) {
-	shipment, err := s.shipmentsRepo.GetShipmentByID(id)
+// transaction runs fn with copy of service which repos are bound to
+// transaction, so mutations and their audit log entries are committed together
+func (s service) transaction(ctx context.Context, fn func(tx service) error) error {
+	return s.transactor.Transaction(ctx, func(tx repo.Repositories) error {
+		s.shipmentsRepo = tx.Shipments
+		s.customersRepo = tx.Customers
+		s.addressBookRepo = tx.AddressBook
+		s.auditRepo = tx.Audit
+		return fn(s)
+	})
+}
+
+// audit appends entry about the action to audit log, before and after are
+// states of the entity, nil for missing one
+func (s service) audit(ctx context.Context, actor models.Actor, accountID int, action, entity string, entityID int, before, after interface{}) error {
+	entry, err := models.NewAuditEntry(actor, accountID, action, entity, entityID, before, after)
 	if err != nil {
+		return err
+	}
+
+	return s.auditRepo.InsertEntry(ctx, entry)
+}
+
+// GetShipmentDetailsByID retrieves shipment of the account with details of
+// its customers, shipments of other accounts are reported as missing ones
+func (s service) GetShipmentDetailsByID(ctx context.Context, accountID, id int) (models.Shipment, error) {
+	shipment, err := s.shipmentsRepo.GetShipmentByID(ctx, accountID, id)
+	if errs.Is(err, errs.NotFound) {
+		return models.Shipment{}, ErrShipmentNotFound
+	} else if err != nil {
 		return models.Shipment{}, err
 	}
 
-	fromCustomer, err := s.customersRepo.GetCustomerByID(shipment.FromID)
+	fromCustomer, err := s.customersRepo.GetCustomerByID(ctx, accountID, shipment.FromID)
 	if err != nil {
 		return models.Shipment{}, err
 	}
 
-	toCustomer, err := s.customersRepo.GetCustomerByID(shipment.ToID)
+	toCustomer, err := s.customersRepo.GetCustomerByID(ctx, accountID, shipment.ToID)
 	if err != nil {
 		return models.Shipment{}, err
 	}
@@ -49,39 +135,57 @@ func (s service) GetShipmentDetailsByID(id int) (models.Shipment, error) {
 	return shipment, nil
 }
 
-func (s service) CreateNewShipment(shipment models.Shipment) error {
-	fromCustomer, err := s.getOrCreateCustomer(shipment.From)
-	if err != nil {
-		return err
-	}
+// CreateNewShipment creates shipment in shipment.AccountID account, its
+// customers are created in the same account if missing
+func (s service) CreateNewShipment(ctx context.Context, actor models.Actor, shipment models.Shipment) error {
+	shipment.From.AccountID = shipment.AccountID
+	shipment.To.AccountID = shipment.AccountID
+
+	err := s.transaction(ctx, func(tx service) error {
+		fromCustomer, err := tx.getOrCreateCustomer(ctx, actor, shipment.From)
+		if err != nil {
+			return err
+		}
+
+		shipment.FromID = fromCustomer.ID
+
+		toCustomer, err := tx.getOrCreateCustomer(ctx, actor, shipment.To)
+		if err != nil {
+			return err
+		}
+
+		shipment.ToID = toCustomer.ID
 
-	shipment.FromID = fromCustomer.ID
+		shipment.FormPrice()
 
-	toCustomer, err := s.getOrCreateCustomer(shipment.To)
+		if err := tx.shipmentsRepo.InsertShipment(ctx, &shipment); err != nil {
+			return err
+		}
+
+		return tx.audit(ctx, actor, shipment.AccountID, models.AuditCreate, models.EntityShipment, shipment.ID,
+			nil, shipment.AuditRecord())
+	})
 	if err != nil {
 		return err
 	}
 
-	shipment.ToID = toCustomer.ID
-
-	shipment.FormPrice()
-
-	return s.shipmentsRepo.InsertShipment(shipment)
+	metrics.ShipmentCreated(shipment.From.CountryCode, shipment.Price.Amount)
+	return nil
 }
 
-func (s service) GetAllShipments() (models.Shipments, error) {
-	rawShipments, err := s.shipmentsRepo.GetAllShipments()
+func (s service) GetAllShipments(ctx context.Context, accountID int) (models.Shipments, error) {
+	rawShipments, err := s.shipmentsRepo.GetAllShipments(ctx, accountID)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(rawShipments) == 0 {
-		return nil, errors.New("no shipments in table yet")
+		return models.Shipments{}, nil
 	}
 
 	customerIDs := rawShipments.GetCustomerIDs()
 
-	customers, err := s.customersRepo.GetCustomersByIDs(customerIDs)
+	customers, err := s.customersRepo.GetCustomersByIDs(ctx, accountID, customerIDs)
 	if err != nil {
 		return nil, err
 	}
@@ -101,17 +205,309 @@ func (s service) GetAllShipments() (models.Shipments, error) {
 	return shipments, nil
 }
 
-func (s service) getOrCreateCustomer(customer models.Customer) (models.Customer, error) {
-	err := s.customersRepo.CheckIfCustomerPresentAndReturn(&customer)
+// ResolveSavedAddresses replaces "from" and "to" customers referring to
+// saved address from address book of shipment.AccountID by its details
+func (s service) ResolveSavedAddresses(ctx context.Context, shipment models.Shipment) (models.Shipment, error) {
+	from, err := s.resolveSavedAddress(ctx, shipment.AccountID, shipment.From)
+	if err != nil {
+		return models.Shipment{}, err
+	}
+
+	to, err := s.resolveSavedAddress(ctx, shipment.AccountID, shipment.To)
+	if err != nil {
+		return models.Shipment{}, err
+	}
+
+	shipment.From = from
+	shipment.To = to
+	return shipment, nil
+}
+
+func (s service) resolveSavedAddress(ctx context.Context, accountID int, customer models.Customer) (models.Customer, error) {
+	if customer.AddressID == 0 {
+		return customer, nil
+	}
+
+	address, err := s.GetSavedAddress(ctx, accountID, customer.AddressID)
+	if errs.Is(err, errs.NotFound) {
+		return models.Customer{}, errs.Wrap(errs.Validation, "saved address not found", err)
+	} else if err != nil {
+		return models.Customer{}, err
+	}
+
+	return address.Customer(), nil
+}
+
+func (s service) GetAddressBook(ctx context.Context, accountID int) (models.SavedAddresses, error) {
+	return s.addressBookRepo.GetAddressesByAccountID(ctx, accountID)
+}
+
+func (s service) GetSavedAddress(ctx context.Context, accountID, id int) (models.SavedAddress, error) {
+	address, err := s.addressBookRepo.GetAddressByID(ctx, id)
+	if errs.Is(err, errs.NotFound) {
+		return models.SavedAddress{}, ErrSavedAddressNotFound
+	} else if err != nil {
+		return models.SavedAddress{}, err
+	}
+
+	// addresses of other accounts are reported as missing ones
+	if address.AccountID != accountID {
+		return models.SavedAddress{}, ErrSavedAddressNotFound
+	}
+
+	return address, nil
+}
+
+func (s service) CreateSavedAddress(ctx context.Context, actor models.Actor, address models.SavedAddress) (models.SavedAddress, error) {
+	err := s.transaction(ctx, func(tx service) error {
+		if err := tx.addressBookRepo.InsertAddress(ctx, &address); err != nil {
+			return err
+		}
+
+		return tx.audit(ctx, actor, address.AccountID, models.AuditCreate, models.EntitySavedAddress, address.ID,
+			nil, address)
+	})
+	if err != nil {
+		return models.SavedAddress{}, err
+	}
+
+	return address, nil
+}
+
+func (s service) UpdateSavedAddress(ctx context.Context, actor models.Actor, address models.SavedAddress) (models.SavedAddress, error) {
+	var updated models.SavedAddress
+	err := s.transaction(ctx, func(tx service) error {
+		before, err := tx.GetSavedAddress(ctx, address.AccountID, address.ID)
+		if err != nil {
+			return err
+		}
+
+		err = tx.addressBookRepo.UpdateAddress(ctx, address)
+		if errs.Is(err, errs.NotFound) {
+			return ErrSavedAddressNotFound
+		} else if err != nil {
+			return err
+		}
+
+		updated, err = tx.addressBookRepo.GetAddressByID(ctx, address.ID)
+		if err != nil {
+			return err
+		}
+
+		return tx.audit(ctx, actor, address.AccountID, models.AuditUpdate, models.EntitySavedAddress, address.ID,
+			before, updated)
+	})
+	if err != nil {
+		return models.SavedAddress{}, err
+	}
+
+	return updated, nil
+}
+
+func (s service) DeleteSavedAddress(ctx context.Context, actor models.Actor, accountID, id int) error {
+	return s.transaction(ctx, func(tx service) error {
+		before, err := tx.GetSavedAddress(ctx, accountID, id)
+		if err != nil {
+			return err
+		}
+
+		err = tx.addressBookRepo.DeleteAddress(ctx, accountID, id)
+		if errs.Is(err, errs.NotFound) {
+			return ErrSavedAddressNotFound
+		} else if err != nil {
+			return err
+		}
+
+		return tx.audit(ctx, actor, accountID, models.AuditDelete, models.EntitySavedAddress, id, before, nil)
+	})
+}
+
+// FindDuplicateCustomers lists pairs of customers which are likely the same
+// person, e.g. with same email and similar name
+func (s service) FindDuplicateCustomers(ctx context.Context, accountID int) (models.DuplicateCandidates, error) {
+	customers, err := s.customersRepo.GetAllCustomers(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	return findDuplicateCandidates(customers), nil
+}
+
+// MergeCustomers merges duplicate customers into surviving one
+func (s service) MergeCustomers(ctx context.Context, actor models.Actor, accountID int, request models.MergeRequest) ([]models.CustomerMerge, error) {
+	var merges []models.CustomerMerge
+	err := s.transaction(ctx, func(tx service) error {
+		var err error
+		merges, err = tx.customersRepo.MergeCustomers(ctx, accountID, request.SurvivorID, request.DuplicateIDs)
+		if errs.Is(err, errs.NotFound) {
+			return ErrCustomerNotFound
+		} else if err != nil {
+			return err
+		}
+
+		for _, merge := range merges {
+			err := tx.audit(ctx, actor, accountID, models.AuditMerge, models.EntityCustomer, merge.MergedID, nil,
+				map[string]interface{}{
+					"survivor_id":     merge.SurvivorID,
+					"shipments_moved": merge.ShipmentsMoved,
+				})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return merges, nil
+}
+
+// ExportCustomerData collects all personal data of the customer
+func (s service) ExportCustomerData(ctx context.Context, accountID, id int) (models.CustomerDataExport, error) {
+	customer, err := s.customersRepo.GetCustomerByID(ctx, accountID, id)
+	if errs.Is(err, errs.NotFound) {
+		return models.CustomerDataExport{}, ErrCustomerNotFound
+	} else if err != nil {
+		return models.CustomerDataExport{}, err
+	}
+
+	shipments, err := s.shipmentsRepo.GetShipmentsByCustomerID(ctx, accountID, id)
+	if err != nil {
+		return models.CustomerDataExport{}, err
+	}
+
+	merges, err := s.customersRepo.GetMergesBySurvivorID(ctx, id)
+	if err != nil {
+		return models.CustomerDataExport{}, err
+	}
+
+	export := models.CustomerDataExport{
+		Customer:   customer,
+		Shipments:  []models.CustomerShipment{},
+		Merges:     merges,
+		ExportedAt: time.Now(),
+	}
+	for _, shipment := range shipments {
+		export.Shipments = append(export.Shipments, models.NewCustomerShipment(shipment, id))
+	}
+	if export.Merges == nil {
+		export.Merges = []models.CustomerMerge{}
+	}
+
+	return export, nil
+}
+
+// EraseCustomer pseudonymises personal data of the customer, shipments of
+// the customer are kept as financial records
+func (s service) EraseCustomer(ctx context.Context, actor models.Actor, accountID, id int) (models.Customer, error) {
+	customer, err := s.customersRepo.GetCustomerByID(ctx, accountID, id)
+	if errs.Is(err, errs.NotFound) {
+		return models.Customer{}, ErrCustomerNotFound
+	} else if err != nil {
+		return models.Customer{}, err
+	}
+
+	if customer.ErasedAt != nil {
+		return customer, nil
+	}
+
+	erased := customer.Pseudonymized(time.Now())
+	err = s.eraseCustomer(ctx, actor, customer, erased)
+	if errs.Is(err, errs.NotFound) {
+		return models.Customer{}, ErrCustomerNotFound
+	} else if err != nil {
+		return models.Customer{}, err
+	}
+
+	return erased, nil
+}
+
+func (s service) eraseCustomer(ctx context.Context, actor models.Actor, customer, erased models.Customer) error {
+	return s.transaction(ctx, func(tx service) error {
+		if err := tx.customersRepo.EraseCustomer(ctx, erased); err != nil {
+			return err
+		}
+
+		return tx.audit(ctx, actor, customer.AccountID, models.AuditErase, models.EntityCustomer, customer.ID,
+			customer, erased)
+	})
+}
+
+// ApplyRetentionPolicy pseudonymises customers without shipments for the
+// specified number of years, returns number of erased customers
+func (s service) ApplyRetentionPolicy(ctx context.Context, years int) (int, error) {
+	if years <= 0 {
+		return 0, errs.New(errs.Validation, "retention period must be positive")
+	}
+
+	customers, err := s.customersRepo.GetCustomersForRetention(ctx, time.Now().AddDate(-years, 0, 0))
+	if err != nil {
+		return 0, err
+	}
+
+	actor := models.SystemActor("retention")
+	for i, customer := range customers {
+		if err := s.eraseCustomer(ctx, actor, customer, customer.Pseudonymized(time.Now())); err != nil {
+			return i, err
+		}
+	}
+
+	return len(customers), nil
+}
+
+// ReencryptPersonalData re-encrypts personal data of customers and saved
+// addresses by active master key, returns numbers of updated records and
+// pairs of customers getting the same match key, which are to be merged
+func (s service) ReencryptPersonalData(ctx context.Context) (customers, addresses int, collisions models.DuplicateCandidates, err error) {
+	customers, collisions, err = s.customersRepo.ReencryptCustomers(ctx)
+	if err != nil {
+		return customers, 0, collisions, err
+	}
+
+	addresses, err = s.addressBookRepo.ReencryptAddresses(ctx)
+	return customers, addresses, collisions, err
+}
+
+// getOrCreateCustomer should be called in transaction
+func (s service) getOrCreateCustomer(ctx context.Context, actor models.Actor, customer models.Customer) (models.Customer, error) {
+	phone := customer.Phone
+	err := s.customersRepo.CheckIfCustomerPresentAndReturn(ctx, &customer)
 	if err == nil {
+		// phone is not a part of customer identity, the latest one is kept
+		if phone != "" && phone != customer.Phone {
+			if err := s.customersRepo.UpdateCustomerPhone(ctx, customer.ID, phone); err != nil {
+				return models.Customer{}, err
+			}
+
+			before := customer
+			customer.Phone = phone
+			err := s.audit(ctx, actor, customer.AccountID, models.AuditUpdate, models.EntityCustomer, customer.ID,
+				before, customer)
+			if err != nil {
+				return models.Customer{}, err
+			}
+		}
 		return customer, nil
-	} else if err != gorm.ErrRecordNotFound {
+	} else if !errs.Is(err, errs.NotFound) {
 		return models.Customer{}, err
 	}
 
-	if err := s.customersRepo.InsertAndReturnCustomer(&customer); err != nil {
+	if err := s.customersRepo.InsertAndReturnCustomer(ctx, &customer); err != nil {
+		return models.Customer{}, err
+	}
+
+	err = s.audit(ctx, actor, customer.AccountID, models.AuditCreate, models.EntityCustomer, customer.ID, nil, customer)
+	if err != nil {
 		return models.Customer{}, err
 	}
 
 	return customer, nil
 }
+
+// GetAuditLog retrieves the latest audit log entries of the account about
+// the entity, entityID 0 stands for all entities of the kind
+func (s service) GetAuditLog(ctx context.Context, accountID int, entity string, entityID, limit int) (models.AuditEntries, error) {
+	return s.auditRepo.GetEntries(ctx, accountID, entity, entityID, limit)
+}
@@ -0,0 +1,130 @@
+package processing
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	repo "sendify_test/shipment/db"
+	"sendify_test/shipment/models"
+	"testing"
+)
+
+// newMemoryService returns service with in-memory repos and transactions
+// and a shipment between two customers, methods using accounts or health
+// checks must not be called
+func newMemoryService(t *testing.T) (service, models.Shipment) {
+	ctx := context.Background()
+	shipmentsRepo := repo.NewMemoryShipmentsRepo()
+	customersRepo := repo.NewMemoryCustomersRepo(shipmentsRepo)
+	addressBookRepo := repo.NewMemoryAddressBookRepo()
+	auditRepo := repo.NewMemoryAuditRepo()
+
+	from := models.Customer{AccountID: 1, Name: "Daniel Svensson", Email: "daniel@sendify.se", CountryCode: "SE"}
+	to := models.Customer{AccountID: 1, Name: "Anna Svensson", Email: "anna@sendify.se", CountryCode: "SE"}
+	for _, customer := range []*models.Customer{&from, &to} {
+		if err := customersRepo.InsertAndReturnCustomer(ctx, customer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	shipment := models.Shipment{AccountID: 1, Weight: 10, Parcels: models.Parcels{{Weight: 10}},
+		Price: models.Price{Amount: 100, Currency: models.PriceCurrency}, FromID: from.ID, ToID: to.ID}
+	if err := shipmentsRepo.InsertShipment(ctx, &shipment); err != nil {
+		t.Fatal(err)
+	}
+
+	return service{
+		transactor:      repo.NewMemoryTransactor(shipmentsRepo, customersRepo, addressBookRepo, auditRepo),
+		shipmentsRepo:   shipmentsRepo,
+		customersRepo:   customersRepo,
+		addressBookRepo: addressBookRepo,
+		auditRepo:       auditRepo,
+	}, shipment
+}
+
+func TestService_GetAllShipments(t *testing.T) {
+	s, shipment := newMemoryService(t)
+
+	shipments, err := s.GetAllShipments(context.Background(), 1)
+	if assert.NoError(t, err) && assert.Len(t, shipments, 1) {
+		assert.Equal(t, shipment.ID, shipments[0].ID)
+		assert.Equal(t, "Daniel Svensson", shipments[0].From.Name)
+	}
+
+	// account without shipments has empty list of them
+	shipments, err = s.GetAllShipments(context.Background(), 2)
+	if assert.NoError(t, err) {
+		assert.NotNil(t, shipments)
+		assert.Empty(t, shipments)
+	}
+}
+
+func TestService_GetShipmentDetailsByID(t *testing.T) {
+	s, shipment := newMemoryService(t)
+
+	details, err := s.GetShipmentDetailsByID(context.Background(), 1, shipment.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, shipment.ID, details.ID)
+		assert.Equal(t, "Daniel Svensson", details.From.Name)
+		assert.Equal(t, "Anna Svensson", details.To.Name)
+	}
+
+	_, err = s.GetShipmentDetailsByID(context.Background(), 2, shipment.ID)
+	assert.Equal(t, ErrShipmentNotFound, err)
+}
+
+func TestService_CreateNewShipment(t *testing.T) {
+	ctx := context.Background()
+	s, shipment := newMemoryService(t)
+	actor := models.Actor{ID: "key:1"}
+
+	// sender is present customer with new phone, recipient is a new one
+	err := s.CreateNewShipment(ctx, actor, models.Shipment{
+		AccountID: 1,
+		Weight:    5,
+		Parcels:   models.Parcels{{Weight: 5}},
+		From:      models.Customer{Name: "Daniel Svensson", Email: "daniel@sendify.se", Phone: "+46701234567", CountryCode: "SE"},
+		To:        models.Customer{Name: "Erik Johansson", Email: "erik@sendify.se", CountryCode: "DK"},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	shipments, err := s.GetAllShipments(ctx, 1)
+	if assert.NoError(t, err) && assert.Len(t, shipments, 2) {
+		created := shipments[1]
+		assert.Equal(t, shipment.FromID, created.FromID)
+		assert.Equal(t, "+46701234567", created.From.Phone)
+		assert.Equal(t, "Erik Johansson", created.To.Name)
+		assert.NotZero(t, created.Price.Amount)
+		if assert.Len(t, created.Parcels, 1) {
+			assert.Equal(t, created.ID, created.Parcels[0].ShipmentID)
+		}
+
+		entries, err := s.GetAuditLog(ctx, 1, models.EntityShipment, created.ID, 10)
+		if assert.NoError(t, err) && assert.Len(t, entries, 1) {
+			assert.Equal(t, models.AuditCreate, entries[0].Action)
+			assert.Equal(t, actor.ID, entries[0].Actor)
+		}
+	}
+
+	entries, err := s.GetAuditLog(ctx, 1, models.EntityCustomer, 0, 10)
+	if assert.NoError(t, err) && assert.Len(t, entries, 2) {
+		assert.Equal(t, models.AuditCreate, entries[0].Action)
+		assert.Equal(t, models.AuditUpdate, entries[1].Action)
+		assert.Equal(t, shipment.FromID, entries[1].EntityID)
+	}
+}
+
+func TestService_ExportCustomerData(t *testing.T) {
+	s, shipment := newMemoryService(t)
+
+	export, err := s.ExportCustomerData(context.Background(), 1, shipment.ToID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "anna@sendify.se", export.Customer.Email)
+		assert.Len(t, export.Shipments, 1)
+		assert.Empty(t, export.Merges)
+	}
+
+	_, err = s.ExportCustomerData(context.Background(), 2, shipment.ToID)
+	assert.Equal(t, ErrCustomerNotFound, err)
+}
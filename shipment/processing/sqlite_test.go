@@ -0,0 +1,193 @@
+//go:build cgo
+// +build cgo
+
+package processing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	repo "sendify_test/shipment/db"
+	"sendify_test/shipment/encryption"
+	"sendify_test/shipment/errs"
+	"sendify_test/shipment/models"
+	"testing"
+)
+
+// newSQLiteService returns service against migrated SQLite database in
+// temporary file, e.g. address book changes need transactions and audit log,
+// which in-memory repos don't have
+func newSQLiteService(t *testing.T) (service, *gorm.DB) {
+	db, err := gorm.Open(repo.DialectSQLite, filepath.Join(t.TempDir(), "shipment.db")+"?_foreign_keys=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.LogMode(false)
+	db.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := repo.NewMigrator(db.DB(), repo.DialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	keyring, err := encryption.NewKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}, bytes.Repeat([]byte{2}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return service{
+		transactor:      repo.NewTransactor(db, keyring),
+		customersRepo:   repo.NewCustomersRepo(db, keyring),
+		shipmentsRepo:   repo.NewShipmentsRepo(db),
+		addressBookRepo: repo.NewAddressBookRepo(db, keyring),
+		auditRepo:       repo.NewAuditRepo(db),
+		healthRepo:      repo.NewHealthRepo(db),
+	}, db
+}
+
+func createSavedAddress(t *testing.T, s service, accountID int, label string) models.SavedAddress {
+	address, err := s.CreateSavedAddress(context.Background(), models.Actor{ID: "key:1"}, models.SavedAddress{
+		AccountID:   accountID,
+		Label:       label,
+		Name:        "Daniel Svensson",
+		Email:       "daniel@sendify.se",
+		Phone:       "+46701234567",
+		CountryCode: "SE",
+		Address:     models.PostalAddress{StreetLines: models.StreetLines{"Drottninggatan 1"}, PostalCode: "111 51", City: "Stockholm"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return address
+}
+
+func TestService_ResolveSavedAddresses(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newSQLiteService(t)
+	address := createSavedAddress(t, s, 1, "Office")
+	other := createSavedAddress(t, s, 2, "Office")
+
+	to := models.Customer{AccountID: 1, Name: "Anna Svensson", Email: "anna@sendify.se", CountryCode: "DK"}
+	shipment, err := s.ResolveSavedAddresses(ctx, models.Shipment{
+		AccountID: 1,
+		From:      models.Customer{AddressID: address.ID},
+		To:        to,
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, address.Customer(), shipment.From)
+		assert.Equal(t, to, shipment.To)
+	}
+
+	// saved address of other account is reported just like missing one
+	for _, id := range []int{address.ID + other.ID, other.ID} {
+		_, err := s.ResolveSavedAddresses(ctx, models.Shipment{AccountID: 1, From: models.Customer{AddressID: id}, To: to})
+		assert.True(t, errs.Is(err, errs.Validation), "address %d: %v", id, err)
+		assert.True(t, errors.Is(err, ErrSavedAddressNotFound), "address %d: %v", id, err)
+		assert.Equal(t, "saved address not found", errs.Message(err))
+	}
+}
+
+func TestService_GetSavedAddress(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newSQLiteService(t)
+	address := createSavedAddress(t, s, 1, "Office")
+
+	saved, err := s.GetSavedAddress(ctx, 1, address.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Daniel Svensson", saved.Name)
+		assert.Equal(t, "Stockholm", saved.Address.City)
+	}
+
+	_, err = s.GetSavedAddress(ctx, 2, address.ID)
+	assert.Equal(t, ErrSavedAddressNotFound, err)
+
+	_, err = s.GetSavedAddress(ctx, 1, address.ID+100)
+	assert.Equal(t, ErrSavedAddressNotFound, err)
+}
+
+func TestService_UpdateSavedAddress(t *testing.T) {
+	ctx := context.Background()
+	s, db := newSQLiteService(t)
+	address := createSavedAddress(t, s, 1, "Office")
+	actor := models.Actor{ID: "key:1"}
+
+	address.Label = "Warehouse"
+	address.Address.City = "Uppsala"
+	updated, err := s.UpdateSavedAddress(ctx, actor, address)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Warehouse", updated.Label)
+		assert.Equal(t, "Uppsala", updated.Address.City)
+		assert.Equal(t, "Daniel Svensson", updated.Name)
+	}
+
+	entries, err := repo.NewAuditRepo(db).GetEntries(ctx, 1, models.EntitySavedAddress, address.ID, 10)
+	if assert.NoError(t, err) && assert.Len(t, entries, 2) {
+		assert.Equal(t, models.AuditUpdate, entries[0].Action)
+		assert.Equal(t, models.AuditCreate, entries[1].Action)
+	}
+
+	// other account can't change the address
+	other := address
+	other.AccountID = 2
+	other.Label = "Stolen"
+	_, err = s.UpdateSavedAddress(ctx, actor, other)
+	assert.Equal(t, ErrSavedAddressNotFound, err)
+
+	saved, err := s.GetSavedAddress(ctx, 1, address.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Warehouse", saved.Label)
+	}
+}
+
+func TestService_DeleteSavedAddress(t *testing.T) {
+	ctx := context.Background()
+	s, db := newSQLiteService(t)
+	address := createSavedAddress(t, s, 1, "Office")
+	actor := models.Actor{ID: "key:1"}
+
+	// other account can't delete the address
+	assert.Equal(t, ErrSavedAddressNotFound, s.DeleteSavedAddress(ctx, actor, 2, address.ID))
+
+	if assert.NoError(t, s.DeleteSavedAddress(ctx, actor, 1, address.ID)) {
+		_, err := s.GetSavedAddress(ctx, 1, address.ID)
+		assert.Equal(t, ErrSavedAddressNotFound, err)
+	}
+
+	entries, err := repo.NewAuditRepo(db).GetEntries(ctx, 1, models.EntitySavedAddress, address.ID, 10)
+	if assert.NoError(t, err) && assert.Len(t, entries, 2) {
+		assert.Equal(t, models.AuditDelete, entries[0].Action)
+	}
+
+	assert.Equal(t, ErrSavedAddressNotFound, s.DeleteSavedAddress(ctx, actor, 1, address.ID))
+}
+
+func TestService_CheckReadiness(t *testing.T) {
+	ctx := context.Background()
+	s, db := newSQLiteService(t)
+
+	assert.NoError(t, s.CheckReadiness(ctx))
+
+	customer := models.Customer{AccountID: 1, Name: "Anna Svensson", Email: "anna@sendify.se", CountryCode: "SE"}
+	if err := s.customersRepo.InsertAndReturnCustomer(ctx, &customer); err != nil {
+		t.Fatal(err)
+	}
+	err := db.Exec("INSERT INTO customer_match_key_backfill (customer_id) VALUES (?)", customer.ID).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ErrMatchKeysPending, s.CheckReadiness(ctx))
+
+	// match keys of deleted customers are not pending
+	if err := db.Exec("DELETE FROM customers WHERE id = ?", customer.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, s.CheckReadiness(ctx))
+}
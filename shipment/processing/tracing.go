@@ -0,0 +1,177 @@
+package processing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"sendify_test/shipment/auth"
+	"sendify_test/shipment/models"
+	"sendify_test/shipment/tracing"
+)
+
+// tracedService starts span of each operation of the service, spans of
+// repo methods are children of it
+type tracedService struct {
+	next Service
+}
+
+func start(ctx context.Context, operation string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "processing."+operation, trace.WithAttributes(attributes...))
+}
+
+func accountAttribute(accountID int) attribute.KeyValue {
+	return attribute.Int("account_id", accountID)
+}
+
+func (s tracedService) GetShipmentDetailsByID(ctx context.Context, accountID, id int) (models.Shipment, error) {
+	ctx, span := start(ctx, "GetShipmentDetailsByID", accountAttribute(accountID), attribute.Int("shipment_id", id))
+	shipment, err := s.next.GetShipmentDetailsByID(ctx, accountID, id)
+	tracing.End(span, err)
+	return shipment, err
+}
+
+func (s tracedService) CreateNewShipment(ctx context.Context, actor models.Actor, shipment models.Shipment) error {
+	ctx, span := start(ctx, "CreateNewShipment", accountAttribute(shipment.AccountID))
+	err := s.next.CreateNewShipment(ctx, actor, shipment)
+	tracing.End(span, err)
+	return err
+}
+
+func (s tracedService) GetAllShipments(ctx context.Context, accountID int) (models.Shipments, error) {
+	ctx, span := start(ctx, "GetAllShipments", accountAttribute(accountID))
+	shipments, err := s.next.GetAllShipments(ctx, accountID)
+	tracing.End(span, err)
+	return shipments, err
+}
+
+func (s tracedService) ResolveSavedAddresses(ctx context.Context, shipment models.Shipment) (models.Shipment, error) {
+	ctx, span := start(ctx, "ResolveSavedAddresses", accountAttribute(shipment.AccountID))
+	shipment, err := s.next.ResolveSavedAddresses(ctx, shipment)
+	tracing.End(span, err)
+	return shipment, err
+}
+
+func (s tracedService) GetAddressBook(ctx context.Context, accountID int) (models.SavedAddresses, error) {
+	ctx, span := start(ctx, "GetAddressBook", accountAttribute(accountID))
+	addresses, err := s.next.GetAddressBook(ctx, accountID)
+	tracing.End(span, err)
+	return addresses, err
+}
+
+func (s tracedService) GetSavedAddress(ctx context.Context, accountID, id int) (models.SavedAddress, error) {
+	ctx, span := start(ctx, "GetSavedAddress", accountAttribute(accountID), attribute.Int("address_id", id))
+	address, err := s.next.GetSavedAddress(ctx, accountID, id)
+	tracing.End(span, err)
+	return address, err
+}
+
+func (s tracedService) CreateSavedAddress(ctx context.Context, actor models.Actor, address models.SavedAddress) (models.SavedAddress, error) {
+	ctx, span := start(ctx, "CreateSavedAddress", accountAttribute(address.AccountID))
+	address, err := s.next.CreateSavedAddress(ctx, actor, address)
+	tracing.End(span, err)
+	return address, err
+}
+
+func (s tracedService) UpdateSavedAddress(ctx context.Context, actor models.Actor, address models.SavedAddress) (models.SavedAddress, error) {
+	ctx, span := start(ctx, "UpdateSavedAddress", accountAttribute(address.AccountID), attribute.Int("address_id", address.ID))
+	address, err := s.next.UpdateSavedAddress(ctx, actor, address)
+	tracing.End(span, err)
+	return address, err
+}
+
+func (s tracedService) DeleteSavedAddress(ctx context.Context, actor models.Actor, accountID, id int) error {
+	ctx, span := start(ctx, "DeleteSavedAddress", accountAttribute(accountID), attribute.Int("address_id", id))
+	err := s.next.DeleteSavedAddress(ctx, actor, accountID, id)
+	tracing.End(span, err)
+	return err
+}
+
+func (s tracedService) FindDuplicateCustomers(ctx context.Context, accountID int) (models.DuplicateCandidates, error) {
+	ctx, span := start(ctx, "FindDuplicateCustomers", accountAttribute(accountID))
+	candidates, err := s.next.FindDuplicateCustomers(ctx, accountID)
+	tracing.End(span, err)
+	return candidates, err
+}
+
+func (s tracedService) MergeCustomers(ctx context.Context, actor models.Actor, accountID int, request models.MergeRequest) ([]models.CustomerMerge, error) {
+	ctx, span := start(ctx, "MergeCustomers", accountAttribute(accountID), attribute.Int("customer_id", request.SurvivorID))
+	merges, err := s.next.MergeCustomers(ctx, actor, accountID, request)
+	tracing.End(span, err)
+	return merges, err
+}
+
+func (s tracedService) ExportCustomerData(ctx context.Context, accountID, id int) (models.CustomerDataExport, error) {
+	ctx, span := start(ctx, "ExportCustomerData", accountAttribute(accountID), attribute.Int("customer_id", id))
+	export, err := s.next.ExportCustomerData(ctx, accountID, id)
+	tracing.End(span, err)
+	return export, err
+}
+
+func (s tracedService) EraseCustomer(ctx context.Context, actor models.Actor, accountID, id int) (models.Customer, error) {
+	ctx, span := start(ctx, "EraseCustomer", accountAttribute(accountID), attribute.Int("customer_id", id))
+	customer, err := s.next.EraseCustomer(ctx, actor, accountID, id)
+	tracing.End(span, err)
+	return customer, err
+}
+
+func (s tracedService) ApplyRetentionPolicy(ctx context.Context, years int) (int, error) {
+	ctx, span := start(ctx, "ApplyRetentionPolicy", attribute.Int("retention_years", years))
+	erased, err := s.next.ApplyRetentionPolicy(ctx, years)
+	tracing.End(span, err)
+	return erased, err
+}
+
+func (s tracedService) ReencryptPersonalData(ctx context.Context) (int, int, models.DuplicateCandidates, error) {
+	ctx, span := start(ctx, "ReencryptPersonalData")
+	customers, addresses, collisions, err := s.next.ReencryptPersonalData(ctx)
+	tracing.End(span, err)
+	return customers, addresses, collisions, err
+}
+
+func (s tracedService) AuthenticateAPIKey(ctx context.Context, key string) (auth.Caller, error) {
+	ctx, span := start(ctx, "AuthenticateAPIKey")
+	caller, err := s.next.AuthenticateAPIKey(ctx, key)
+	tracing.End(span, err)
+	return caller, err
+}
+
+func (s tracedService) GetAllAccounts(ctx context.Context) (models.Accounts, error) {
+	ctx, span := start(ctx, "GetAllAccounts")
+	accounts, err := s.next.GetAllAccounts(ctx)
+	tracing.End(span, err)
+	return accounts, err
+}
+
+func (s tracedService) CreateAccount(ctx context.Context, name string) (models.Account, models.APIKey, string, error) {
+	ctx, span := start(ctx, "CreateAccount")
+	account, apiKey, key, err := s.next.CreateAccount(ctx, name)
+	tracing.End(span, err)
+	return account, apiKey, key, err
+}
+
+func (s tracedService) CreateAPIKey(ctx context.Context, accountID int, name string, role auth.Role) (models.APIKey, string, error) {
+	ctx, span := start(ctx, "CreateAPIKey", accountAttribute(accountID))
+	apiKey, key, err := s.next.CreateAPIKey(ctx, accountID, name, role)
+	tracing.End(span, err)
+	return apiKey, key, err
+}
+
+func (s tracedService) RevokeAPIKey(ctx context.Context, id int) error {
+	ctx, span := start(ctx, "RevokeAPIKey", attribute.Int("api_key_id", id))
+	err := s.next.RevokeAPIKey(ctx, id)
+	tracing.End(span, err)
+	return err
+}
+
+func (s tracedService) GetAuditLog(ctx context.Context, accountID int, entity string, entityID, limit int) (models.AuditEntries, error) {
+	ctx, span := start(ctx, "GetAuditLog", accountAttribute(accountID), attribute.String("entity", entity))
+	entries, err := s.next.GetAuditLog(ctx, accountID, entity, entityID, limit)
+	tracing.End(span, err)
+	return entries, err
+}
+
+// CheckReadiness is not traced, it's called by probes of orchestrator and
+// on start only
+func (s tracedService) CheckReadiness(ctx context.Context) error {
+	return s.next.CheckReadiness(ctx)
+}
@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Count requests per Period, bucket of Count tokens is refilled
+// evenly over Period, so bursts of up to Count requests are allowed
+type Limit struct {
+	Count  int
+	Period time.Duration
+}
+
+var periods = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+}
+
+// ParseLimit parses limit in "<count>/<s|m|h>" format, e.g. "100/m"
+func ParseLimit(value string) (Limit, error) {
+	parts := strings.SplitN(strings.TrimSpace(value), "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("limit %q must be in <count>/<s|m|h> format", value)
+	}
+
+	count, err := strconv.Atoi(parts[0])
+	if err != nil || count <= 0 {
+		return Limit{}, fmt.Errorf("invalid count of limit %q", value)
+	}
+
+	period, ok := periods[parts[1]]
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid period of limit %q", value)
+	}
+
+	return Limit{Count: count, Period: period}, nil
+}
+
+// ParseRouteLimits parses limits of routes in "<METHOD> <path template>=<limit>"
+// format, e.g. "POST /shipment=10/m"
+func ParseRouteLimits(values []string) (map[string]Limit, error) {
+	limits := map[string]Limit{}
+	for _, value := range values {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+
+		eq := strings.LastIndex(value, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("route limit %q must be in <METHOD> <path>=<limit> format", value)
+		}
+
+		limit, err := ParseLimit(value[eq+1:])
+		if err != nil {
+			return nil, err
+		}
+		limits[strings.Join(strings.Fields(value[:eq]), " ")] = limit
+	}
+
+	return limits, nil
+}
+
+func (l Limit) String() string {
+	for name, period := range periods {
+		if l.Period == period {
+			return fmt.Sprintf("%d/%s", l.Count, name)
+		}
+	}
+	return fmt.Sprintf("%d/%s", l.Count, l.Period)
+}
+
+// interval returns time to refill one token
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Count)
+}
@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"github.com/gorilla/mux"
+	"math"
+	"net"
+	"net/http"
+	"regexp"
+	"sendify_test/shipment/logging"
+	"sendify_test/shipment/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyFunc returns key requests are limited by, e.g. client IP, requests are
+// not limited if it reports false
+type KeyFunc func(r *http.Request) (string, bool)
+
+// Limiter limits requests per key by token buckets, limit of the route is
+// used if configured, otherwise requests to all routes share default limit
+type Limiter struct {
+	store        Store
+	defaultLimit Limit
+	routeLimits  map[string]Limit
+	now          func() time.Time
+}
+
+// NewLimiter returns limiter, routeLimits are keyed by "<METHOD> <path
+// template>" of mux routes, e.g. "GET /shipment/{id:[0-9]+}"
+func NewLimiter(store Store, defaultLimit Limit, routeLimits map[string]Limit) *Limiter {
+	return &Limiter{
+		store:        store,
+		defaultLimit: defaultLimit,
+		routeLimits:  routeLimits,
+		now:          time.Now,
+	}
+}
+
+// Middleware limits requests per key, responds with 429 Too Many Requests
+// when limit is exceeded. Should be used on mux router, so route is matched
+func (l *Limiter) Middleware(keyFunc KeyFunc) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := keyFunc(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			route, limit := l.limit(r)
+			result, err := l.store.Take(key+"|"+route, limit, l.now())
+			if err != nil {
+				// requests are not rejected because of limiter failures
+				logging.FromContext(r.Context()).Error("Failed to check rate limit", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit.Count))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+			if !result.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				models.PrintHTTPResult(w, http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// versionPrefix matches version of the API in path templates, e.g. "/v1"
+var versionPrefix = regexp.MustCompile(`^/v[0-9]+/`)
+
+// limit returns route name and its limit, "*" stands for routes without
+// own limit. Version of the API is not a part of route name, so versions of
+// the route share its limit
+func (l *Limiter) limit(r *http.Request) (string, Limit) {
+	if route := mux.CurrentRoute(r); route != nil && len(l.routeLimits) > 0 {
+		if template, err := route.GetPathTemplate(); err == nil {
+			name := r.Method + " " + versionPrefix.ReplaceAllString(template, "/")
+			if limit, ok := l.routeLimits[name]; ok {
+				return name, limit
+			}
+		}
+	}
+	return "*", l.defaultLimit
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// ClientIP returns KeyFunc limiting requests by client IP, the last address
+// of X-Forwarded-For header is used if service is behind trusted proxy
+func ClientIP(trustForwardedFor bool) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		if trustForwardedFor {
+			if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+				addresses := strings.Split(forwarded, ",")
+				return "ip:" + strings.TrimSpace(addresses[len(addresses)-1]), true
+			}
+		}
+
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		return "ip:" + host, true
+	}
+}
@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ParseLimit("100/m")
+	if assert.NoError(t, err) {
+		assert.Equal(t, Limit{Count: 100, Period: time.Minute}, limit)
+		assert.Equal(t, "100/m", limit.String())
+	}
+
+	for _, value := range []string{"", "100", "0/s", "-1/s", "ten/s", "10/d"} {
+		_, err := ParseLimit(value)
+		assert.Error(t, err, value)
+	}
+
+	limits, err := ParseRouteLimits([]string{"POST  /shipment=10/m", "GET /shipment/{id:[0-9]+}=5/s", ""})
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]Limit{
+			"POST /shipment":            {Count: 10, Period: time.Minute},
+			"GET /shipment/{id:[0-9]+}": {Count: 5, Period: time.Second},
+		}, limits)
+	}
+
+	_, err = ParseRouteLimits([]string{"POST /shipment"})
+	assert.Error(t, err)
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Count: 2, Period: 10 * time.Second}
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	result, _ := store.Take("a", limit, now)
+	assert.Equal(t, Result{Allowed: true, Remaining: 1, ResetAfter: 5 * time.Second}, result)
+
+	result, _ = store.Take("a", limit, now)
+	assert.Equal(t, Result{Allowed: true, Remaining: 0, ResetAfter: 10 * time.Second}, result)
+
+	result, _ = store.Take("a", limit, now.Add(time.Second))
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 4*time.Second, result.RetryAfter)
+
+	// other keys have own buckets
+	result, _ = store.Take("b", limit, now.Add(time.Second))
+	assert.True(t, result.Allowed)
+
+	// token is refilled in Period / Count
+	result, _ = store.Take("a", limit, now.Add(5*time.Second))
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	// full buckets are swept
+	store.Take("c", limit, now.Add(time.Hour))
+	assert.Len(t, store.buckets, 1)
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	limiter := NewLimiter(NewMemoryStore(), Limit{Count: 3, Period: time.Minute}, map[string]Limit{
+		"POST /shipment": {Count: 1, Period: time.Minute},
+	})
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	router := mux.NewRouter()
+	router.Use(limiter.Middleware(ClientIP(true)))
+	ok := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }
+	router.HandleFunc("/shipment", ok).Methods(http.MethodPost)
+	router.HandleFunc("/v1/shipment", ok).Methods(http.MethodPost)
+	router.HandleFunc("/shipment/{id:[0-9]+}", ok).Methods(http.MethodGet)
+	router.HandleFunc("/shipment/list", ok).Methods(http.MethodGet)
+
+	request := func(method, path, forwardedFor string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		r.RemoteAddr = "10.0.0.1:5000"
+		if forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := request(http.MethodPost, "/shipment", "192.0.2.1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "60", w.Header().Get("X-RateLimit-Reset"))
+
+	w = request(http.MethodPost, "/shipment", "203.0.113.7, 192.0.2.1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error": "rate limit exceeded"}`, w.Body.String())
+
+	// versions of the route share its limit
+	w = request(http.MethodPost, "/v1/shipment", "192.0.2.1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// other IP has own bucket
+	w = request(http.MethodPost, "/shipment", "192.0.2.2")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// routes without own limit share default one
+	for i, path := range []string{"/shipment/1", "/shipment/list", "/shipment/2"} {
+		w = request(http.MethodGet, path, "192.0.2.1")
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.Equal(t, "3", w.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, strconv.Itoa(2-i), w.Header().Get("X-RateLimit-Remaining"))
+	}
+	w = request(http.MethodGet, "/shipment/3", "192.0.2.1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "20", w.Header().Get("Retry-After"))
+
+	now = now.Add(20 * time.Second)
+	w = request(http.MethodGet, "/shipment/3", "192.0.2.1")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Result is state of the bucket after taking a token
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// ResetAfter is time until bucket is full again
+	ResetAfter time.Duration
+	// RetryAfter is time until next token, set when request is not allowed
+	RetryAfter time.Duration
+}
+
+// Store keeps token buckets, implementations backed by shared storage (e.g.
+// Redis) allow to limit requests across service instances
+type Store interface {
+	// Take takes token from bucket by key, bucket is created full
+	Take(key string, limit Limit, now time.Time) (Result, error)
+}
+
+// sweepInterval is how often MemoryStore removes full buckets
+const sweepInterval = time.Minute
+
+// MemoryStore is Store keeping buckets in memory of the instance
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	sweptAt time.Time
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	limit     Limit
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: map[string]*bucket{},
+	}
+}
+
+func (s *MemoryStore) Take(key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.sweptAt) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok || b.limit != limit {
+		b = &bucket{tokens: float64(limit.Count), updatedAt: now, limit: limit}
+		s.buckets[key] = b
+	}
+	b.refill(now)
+
+	result := Result{Allowed: b.tokens >= 1}
+	if result.Allowed {
+		b.tokens--
+	} else {
+		result.RetryAfter = time.Duration((1 - b.tokens) * float64(limit.interval()))
+	}
+	result.Remaining = int(b.tokens)
+	result.ResetAfter = time.Duration((float64(limit.Count) - b.tokens) * float64(limit.interval()))
+
+	return result, nil
+}
+
+// sweep removes buckets which are full by now, they are equal to new ones
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Count) {
+			delete(s.buckets, key)
+		}
+	}
+	s.sweptAt = now
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updatedAt)
+	if elapsed <= 0 {
+		return
+	}
+
+	b.tokens += float64(elapsed) / float64(b.limit.interval())
+	if b.tokens > float64(b.limit.Count) {
+		b.tokens = float64(b.limit.Count)
+	}
+	b.updatedAt = now
+}
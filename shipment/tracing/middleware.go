@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"sendify_test/shipment/httputil"
+	"sendify_test/shipment/logging"
+)
+
+// Middleware starts span of each request as child of one passed by caller in
+// traceparent header, trace ID is added to log entries of the request.
+// Should be used on mux router, so route is matched
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		ctx, span := Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethodKey.String(r.Method),
+				semconv.HTTPRouteKey.String(route),
+				semconv.HTTPTargetKey.String(r.URL.RequestURI()),
+			))
+		defer span.End()
+
+		if spanContext := span.SpanContext(); spanContext.HasTraceID() {
+			ctx = logging.With(ctx, "trace_id", spanContext.TraceID().String())
+		}
+
+		recorder := httputil.NewStatusRecorder(w)
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(recorder.Status()))
+		if recorder.Status() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.Status()))
+		}
+	})
+}
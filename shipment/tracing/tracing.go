@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies spans of the service
+const instrumentationName = "sendify_test/shipment"
+
+// Exporters of spans
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Config of tracing, OTLP exporter is configured by standard
+// OTEL_EXPORTER_OTLP_* environment variables, e.g. OTEL_EXPORTER_OTLP_ENDPOINT
+type Config struct {
+	ServiceName string
+	Exporter    string
+	// SampleRatio is ratio of sampled traces started by the service, traces
+	// started by callers are sampled as decided by them
+	SampleRatio float64
+}
+
+// Setup installs global tracer provider exporting spans by configured
+// exporter and W3C trace context propagator. Returned function flushes
+// spans and should be called before exit
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New()
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q, expected one of none, otlp, stdout", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := NewProvider(cfg.ServiceName, cfg.SampleRatio, sdktrace.NewBatchSpanProcessor(exporter))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// NewProvider returns tracer provider passing spans to the processor, e.g.
+// one with in-memory exporter in tests
+func NewProvider(serviceName string, sampleRatio float64, processor sdktrace.SpanProcessor) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+		sdktrace.WithSpanProcessor(processor),
+	)
+}
+
+// Start starts span of the operation as child of span in context
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End ends span, marking it failed if err is not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
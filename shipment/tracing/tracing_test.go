@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func setupInMemory(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(NewProvider("test", 1, sdktrace.NewSimpleSpanProcessor(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(trace.NewNoopTracerProvider()) })
+	return exporter
+}
+
+func TestMiddleware(t *testing.T) {
+	exporter := setupInMemory(t)
+
+	router := mux.NewRouter()
+	router.Use(Middleware)
+	router.HandleFunc("/shipment/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "processing.GetShipmentDetailsByID")
+		End(span, errors.New("connection refused"))
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/shipment/1", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := exporter.GetSpans()
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+
+	child, server := spans[0], spans[1]
+	assert.Equal(t, "GET /shipment/{id:[0-9]+}", server.Name)
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+	assert.Contains(t, server.Attributes, attribute.Int("http.status_code", http.StatusInternalServerError))
+	assert.Equal(t, codes.Error, server.Status.Code)
+
+	assert.Equal(t, "processing.GetShipmentDetailsByID", child.Name)
+	assert.Equal(t, server.SpanContext.SpanID(), child.Parent.SpanID())
+	assert.Equal(t, codes.Error, child.Status.Code)
+	assert.Equal(t, "connection refused", child.Status.Description)
+}
+
+func TestSetup(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Config{ServiceName: "test", Exporter: ExporterNone})
+	if assert.NoError(t, err) {
+		assert.NoError(t, shutdown(context.Background()))
+	}
+
+	_, err = Setup(context.Background(), Config{ServiceName: "test", Exporter: "zipkin"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,168 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/net/idna"
+	"net/mail"
+	"strings"
+	"sync"
+)
+
+// ErrDomainBlocked is returned by domain checkers for domains which emails
+// are not accepted
+var ErrDomainBlocked = errors.New("email domain is not allowed")
+
+// DomainChecker checks domain of email addresses, e.g. for MX records
+// presence or against disposable domains blocklist
+type DomainChecker interface {
+	// CheckDomain returns error if emails of the domain (in ASCII form)
+	// should not be accepted
+	CheckDomain(domain string) error
+}
+
+var domainChecker DomainChecker
+
+// SetDomainChecker sets checker used for email domains, nil disables
+// domain checks, should be called before serving requests
+func SetDomainChecker(checker DomainChecker) {
+	domainChecker = checker
+}
+
+var emailIDNA = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.StrictDomainName(true),
+	idna.VerifyDNSLength(true),
+)
+
+// NormalizeEmail parses email address (RFC 5322 addr-spec) and returns it
+// with lowercase domain converted to ASCII (IDNA), local part is kept as is
+func NormalizeEmail(email string) (string, error) {
+	if strings.ContainsAny(email, "<>") {
+		return "", errors.New("email must not contain display name")
+	}
+
+	address, err := mail.ParseAddress(email)
+	if err != nil {
+		return "", err
+	}
+	if address.Name != "" {
+		return "", errors.New("email must not contain display name")
+	}
+
+	at := strings.LastIndex(address.Address, "@")
+	local, domain := address.Address[:at], address.Address[at+1:]
+
+	if strings.HasPrefix(domain, "[") {
+		return "", errors.New("domain literals are not accepted")
+	}
+
+	domain, err = emailIDNA.ToASCII(strings.ToLower(domain))
+	if err != nil {
+		return "", err
+	}
+
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return "", errors.New("email domain must have top level domain")
+	}
+	if tld := labels[len(labels)-1]; len(tld) < 2 || strings.Trim(tld, "0123456789") == "" {
+		return "", fmt.Errorf("invalid top level domain %q", tld)
+	}
+
+	return quoteLocalPart(local) + "@" + domain, nil
+}
+
+// quoteLocalPart quotes local part unquoted by parser if it is not dot-atom
+func quoteLocalPart(local string) string {
+	isDotAtom := !strings.HasPrefix(local, ".") && !strings.HasSuffix(local, ".") &&
+		!strings.Contains(local, "..") && strings.IndexFunc(local, isNotAtext) < 0
+	if isDotAtom {
+		return local
+	}
+
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(local) + `"`
+}
+
+// isNotAtext reports whether character is not allowed in dot-atom, non ASCII
+// characters are allowed (RFC 6532)
+func isNotAtext(r rune) bool {
+	if r >= 0x80 || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+		return false
+	}
+	return !strings.ContainsRune("!#$%&'*+-/=?^_`{|}~.", r)
+}
+
+// checkEmail checks email format and its domain using domain checker
+func checkEmail(email string) (code, message string) {
+	normalized, err := NormalizeEmail(email)
+	if err != nil {
+		return CodeInvalidFormat, "invalid email address"
+	}
+
+	if domainChecker == nil {
+		return "", ""
+	}
+
+	domain := normalized[strings.LastIndex(normalized, "@")+1:]
+	if err := domainChecker.CheckDomain(domain); err != nil {
+		return CodeNotAllowed, err.Error()
+	}
+
+	return "", ""
+}
+
+// CheckEmail checks email of the field, reports whether email is valid
+func (v *Validator) CheckEmail(pointer, rule, email string) bool {
+	if !v.CheckField(pointer, rule, email) || email == "" {
+		return false
+	}
+
+	if code, message := checkEmail(email); code != "" {
+		v.AddError(pointer, code, message)
+		return false
+	}
+	return true
+}
+
+// MemoryDomainChecker is in-memory domain checker, blocks listed domains
+// and their subdomains
+type MemoryDomainChecker struct {
+	mu      sync.RWMutex
+	blocked map[string]error
+}
+
+func NewMemoryDomainChecker(blocked ...string) *MemoryDomainChecker {
+	checker := &MemoryDomainChecker{
+		blocked: map[string]error{},
+	}
+	for _, domain := range blocked {
+		checker.Block(domain, ErrDomainBlocked)
+	}
+	return checker
+}
+
+// Block makes checker return err for domain and its subdomains
+func (c *MemoryDomainChecker) Block(domain string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blocked[strings.ToLower(domain)] = err
+}
+
+func (c *MemoryDomainChecker) CheckDomain(domain string) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for {
+		if err, ok := c.blocked[domain]; ok {
+			return err
+		}
+
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			return nil
+		}
+		domain = domain[dot+1:]
+	}
+}
@@ -0,0 +1,65 @@
+package validation
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		email   string
+		want    string
+		wantErr bool
+	}{
+		{name: "Simple", email: "daniel@sendify.se", want: "daniel@sendify.se"},
+		{name: "Uppercase domain", email: "Daniel@Sendify.SE", want: "Daniel@sendify.se"},
+		{name: "Long TLD", email: "curator@museum.museum", want: "curator@museum.museum"},
+		{name: "Longer TLD", email: "info@example.technology", want: "info@example.technology"},
+		{name: "Plus tag", email: "daniel+test@sendify.se", want: "daniel+test@sendify.se"},
+		{name: "IDN domain", email: "björn@Bücher.de", want: "björn@xn--bcher-kva.de"},
+		{name: "Quoted local part", email: `"daniel sendify"@sendify.se`, want: `"daniel sendify"@sendify.se`},
+		{name: "Display name", email: "Daniel <daniel@sendify.se>", wantErr: true},
+		{name: "Question mark in domain", email: "daniel@sendify?se", wantErr: true},
+		{name: "No domain", email: "daniel@", wantErr: true},
+		{name: "No TLD", email: "daniel@localhost", wantErr: true},
+		{name: "Numeric TLD", email: "daniel@127.0.0.1", wantErr: true},
+		{name: "Domain literal", email: "daniel@[127.0.0.1]", wantErr: true},
+		{name: "Two at signs", email: "daniel@sendify@se", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeEmail(tt.email)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidator_CheckEmail(t *testing.T) {
+	errNoMX := errors.New("email domain has no MX records")
+	checker := NewMemoryDomainChecker("mailinator.com")
+	checker.Block("example.org", errNoMX)
+
+	SetDomainChecker(checker)
+	defer SetDomainChecker(nil)
+
+	v := NewValidator()
+	assert.True(t, v.CheckEmail("/from/email", "customer.email", "Daniel@Sendify.se"))
+	assert.False(t, v.CheckEmail("/to/email", "customer.email", "someone@eu.mailinator.com"))
+	assert.False(t, v.CheckEmail("/other/email", "customer.email", "someone@example.org"))
+	assert.False(t, v.CheckEmail("/invalid/email", "customer.email", "someone@example"))
+
+	assert.Equal(t, Errors{
+		{Pointer: "/to/email", Code: CodeNotAllowed, Message: ErrDomainBlocked.Error()},
+		{Pointer: "/other/email", Code: CodeNotAllowed, Message: errNoMX.Error()},
+		{Pointer: "/invalid/email", Code: CodeInvalidFormat, Message: "invalid email address"},
+	}, v.Err())
+}
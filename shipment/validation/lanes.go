@@ -0,0 +1,54 @@
+package validation
+
+import "strings"
+
+// euMembers are member states of the European Union
+var euMembers = map[string]bool{
+	"AT": true, "BE": true, "BG": true, "CY": true, "CZ": true, "DE": true, "DK": true,
+	"EE": true, "ES": true, "FI": true, "FR": true, "GR": true, "HR": true, "HU": true,
+	"IE": true, "IT": true, "LT": true, "LU": true, "LV": true, "MT": true, "NL": true,
+	"PL": true, "PT": true, "RO": true, "SE": true, "SI": true, "SK": true,
+}
+
+// LaneRule describes requirements of shipments between countries matching
+// "from" and "to" selectors. Selector is either "*" (any country),
+// "EU" (European Union member), country code, e.g. "SE", or any of these
+// negated by "!" prefix, e.g. "!EU"
+type LaneRule struct {
+	From                  string `json:"from"`
+	To                    string `json:"to"`
+	RequireSenderPhone    bool   `json:"require_sender_phone"`
+	RequireRecipientPhone bool   `json:"require_recipient_phone"`
+}
+
+// Matches reports whether shipment between countries belongs to the lane
+func (l LaneRule) Matches(fromCountryCode, toCountryCode string) bool {
+	return matchesCountry(l.From, fromCountryCode) && matchesCountry(l.To, toCountryCode)
+}
+
+func matchesCountry(selector, countryCode string) bool {
+	if strings.HasPrefix(selector, "!") {
+		return !matchesCountry(selector[1:], countryCode)
+	}
+
+	switch selector {
+	case "", "*":
+		return true
+	case "EU":
+		return euMembers[countryCode]
+	default:
+		return strings.EqualFold(selector, countryCode)
+	}
+}
+
+// RequiredPhones reports whether sender and recipient phones are required
+// for shipment between countries by any of lane rules
+func (r *Rules) RequiredPhones(fromCountryCode, toCountryCode string) (sender, recipient bool) {
+	for _, lane := range r.Lanes {
+		if lane.Matches(fromCountryCode, toCountryCode) {
+			sender = sender || lane.RequireSenderPhone
+			recipient = recipient || lane.RequireRecipientPhone
+		}
+	}
+	return sender, recipient
+}
@@ -0,0 +1,34 @@
+package validation
+
+import (
+	"errors"
+	"github.com/nyaruka/phonenumbers"
+)
+
+// NormalizePhone parses phone number and returns it in E.164 format,
+// region (ISO 3166-1 alpha-2) is used for numbers without country code
+func NormalizePhone(phone, region string) (string, error) {
+	number, err := phonenumbers.Parse(phone, region)
+	if err != nil {
+		return "", err
+	}
+	if !phonenumbers.IsValidNumber(number) {
+		return "", errors.New("invalid phone number")
+	}
+
+	return phonenumbers.Format(number, phonenumbers.E164), nil
+}
+
+// CheckPhone checks optional phone number of the field, reports whether
+// phone is valid
+func (v *Validator) CheckPhone(pointer, phone, region string) bool {
+	if phone == "" {
+		return true
+	}
+
+	if _, err := NormalizePhone(phone, region); err != nil {
+		v.AddError(pointer, CodeInvalidFormat, "invalid phone number")
+		return false
+	}
+	return true
+}
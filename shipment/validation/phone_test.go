@@ -0,0 +1,66 @@
+package validation
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		name    string
+		phone   string
+		region  string
+		want    string
+		wantErr bool
+	}{
+		{name: "National Swedish number", phone: "070-123 45 67", region: "SE", want: "+46701234567"},
+		{name: "International number", phone: "+380 50 123 4567", region: "SE", want: "+380501234567"},
+		{name: "International prefix", phone: "00 46 70 123 45 67", region: "SE", want: "+46701234567"},
+		{name: "National Ukrainian number", phone: "050 123 4567", region: "UA", want: "+380501234567"},
+		{name: "Too short", phone: "123", region: "SE", wantErr: true},
+		{name: "Letters", phone: "phone", region: "SE", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizePhone(tt.phone, tt.region)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRules_RequiredPhones(t *testing.T) {
+	rules := &Rules{
+		Lanes: []LaneRule{
+			{From: "*", To: "!EU", RequireRecipientPhone: true},
+			{From: "US", To: "*", RequireSenderPhone: true},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		from, to      string
+		wantSender    bool
+		wantRecipient bool
+	}{
+		{name: "Inside EU", from: "SE", to: "DE"},
+		{name: "EU to non-EU", from: "SE", to: "UA", wantRecipient: true},
+		{name: "Non-EU to EU", from: "NO", to: "SE"},
+		{name: "US to non-EU", from: "US", to: "CA", wantSender: true, wantRecipient: true},
+		{name: "US to EU", from: "US", to: "FR", wantSender: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender, recipient := rules.RequiredPhones(tt.from, tt.to)
+			assert.Equal(t, tt.wantSender, sender)
+			assert.Equal(t, tt.wantRecipient, recipient)
+		})
+	}
+}
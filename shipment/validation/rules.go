@@ -0,0 +1,135 @@
+package validation
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+//go:embed rules.json
+var defaultRulesJSON []byte
+
+// Rule describes constraints of a single string field, lengths are
+// counted in characters, not bytes
+type Rule struct {
+	Required  bool   `json:"required"`
+	MinLength int    `json:"min_length"`
+	MaxLength int    `json:"max_length"`
+	Pattern   string `json:"pattern"`
+
+	pattern *regexp.Regexp
+}
+
+// Rules contains field rules by their names, e.g. "customer.name",
+// and lane rules of shipments
+type Rules struct {
+	Fields map[string]*Rule `json:"fields"`
+	Lanes  []LaneRule       `json:"lanes"`
+}
+
+var currentRules = DefaultRules()
+
+// DefaultRules returns rules built into the service
+func DefaultRules() *Rules {
+	rules, err := parseRules(defaultRulesJSON)
+	if err != nil {
+		panic(fmt.Sprintf("invalid default validation rules: %s", err.Error()))
+	}
+	return rules
+}
+
+// LoadRules reads rules from JSON file, fields missing in the file keep
+// default rules, lanes replace default ones if specified
+func LoadRules(path string) (*Rules, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fileRules, err := parseRules(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid validation rules in %s: %w", path, err)
+	}
+
+	rules := DefaultRules()
+	for field, rule := range fileRules.Fields {
+		rules.Fields[field] = rule
+	}
+	if fileRules.Lanes != nil {
+		rules.Lanes = fileRules.Lanes
+	}
+	return rules, nil
+}
+
+// SetRules replaces rules used by validators, should be called before
+// serving requests
+func SetRules(rules *Rules) {
+	currentRules = rules
+}
+
+// CurrentRules returns rules used by validators
+func CurrentRules() *Rules {
+	return currentRules
+}
+
+func parseRules(data []byte) (*Rules, error) {
+	var rules Rules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+
+	for field, rule := range rules.Fields {
+		if rule == nil {
+			return nil, fmt.Errorf("empty rule of %s", field)
+		}
+		if rule.Pattern == "" {
+			continue
+		}
+
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern of %s: %w", field, err)
+		}
+		rule.pattern = pattern
+	}
+
+	if rules.Fields == nil {
+		rules.Fields = map[string]*Rule{}
+	}
+	return &rules, nil
+}
+
+// Check returns code and message describing violation of the rule,
+// empty code means that value is valid
+func (r Rule) Check(name, value string) (code, message string) {
+	length := utf8.RuneCountInString(value)
+	if length == 0 {
+		if r.Required {
+			return CodeRequired, "empty " + name
+		}
+		return "", ""
+	}
+
+	if r.MaxLength > 0 && length > r.MaxLength {
+		return CodeTooLong, "too long " + name
+	}
+	if length < r.MinLength {
+		return CodeTooShort, "too short " + name
+	}
+	if r.pattern != nil && !r.pattern.MatchString(value) {
+		return CodeInvalidCharacters, name + " contains unacceptable characters"
+	}
+
+	return "", ""
+}
+
+// ruleName returns human readable name of the rule, e.g. "street line"
+// for "address.street_line"
+func ruleName(rule string) string {
+	name := rule[strings.LastIndex(rule, ".")+1:]
+	return strings.ReplaceAll(name, "_", " ")
+}
@@ -0,0 +1,115 @@
+package validation
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultRules_Name(t *testing.T) {
+	rule := DefaultRules().Fields["customer.name"]
+
+	tests := []struct {
+		name    string
+		value   string
+		code    string
+		message string
+	}{
+		{name: "Latin", value: "Daniel"},
+		{name: "Swedish", value: "Björn Åström"},
+		{name: "Combining accent", value: "José"},
+		{name: "Apostrophe", value: "O'Brien"},
+		{name: "Hyphen", value: "Anna-Karin"},
+		{name: "Cyrillic", value: "Микита"},
+		{name: "Thirty runes", value: "Åååååååååååååååååååååååååååååå"},
+		{name: "Empty", value: "", code: CodeRequired, message: "empty name"},
+		{name: "Too long", value: "Åååååååååååååååååååååååååååååån", code: CodeTooLong, message: "too long name"},
+		{name: "Digits", value: "Daniel2", code: CodeInvalidCharacters, message: "name contains unacceptable characters"},
+		{name: "Leading apostrophe", value: "'Daniel", code: CodeInvalidCharacters, message: "name contains unacceptable characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, message := rule.Check("name", tt.value)
+			assert.Equal(t, tt.code, code)
+			assert.Equal(t, tt.message, message)
+		})
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "rules.json")
+	err = ioutil.WriteFile(path, []byte(`{"fields": {"customer.name": {"required": true, "max_length": 5}}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := LoadRules(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, message := rules.Fields["customer.name"].Check("name", "Daniel")
+	assert.Equal(t, "too long name", message)
+	assert.Equal(t, 100, rules.Fields["address.street_line"].MaxLength, "default rules should be kept")
+
+	err = ioutil.WriteFile(path, []byte(`{"fields": {"customer.name": {"pattern": "[a-"}}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = LoadRules(path)
+	assert.Error(t, err)
+}
+
+func TestValidator(t *testing.T) {
+	v := NewValidator()
+
+	assert.True(t, v.CheckField("/from/name", "customer.name", "Björn"))
+	assert.False(t, v.CheckField("/to/name", "customer.name", ""))
+	v.AddError("/to/name", CodeInvalidFormat, "second error of the same field")
+	v.AddError("/weight", CodeOutOfRange, "invalid weight")
+
+	err := v.Err()
+	if assert.Error(t, err) {
+		assert.Equal(t, Errors{
+			{Pointer: "/to/name", Code: CodeRequired, Message: "empty name"},
+			{Pointer: "/weight", Code: CodeOutOfRange, Message: "invalid weight"},
+		}, err)
+	}
+
+	assert.NoError(t, NewValidator().Err())
+}
+
+func TestMapPointers(t *testing.T) {
+	err := Errors{
+		{Pointer: "/parcels", Code: CodeRequired, Message: "empty parcels"},
+		{Pointer: "/parcels/0/weight", Code: CodeOutOfRange, Message: "invalid weight"},
+		{Pointer: "/from/name", Code: CodeRequired, Message: "empty name"},
+	}
+
+	mapped := MapPointers(err, func(pointer string) string {
+		if strings.HasPrefix(pointer, "/parcels") {
+			return "/weight"
+		}
+		return pointer
+	})
+	assert.Equal(t, Errors{
+		{Pointer: "/weight", Code: CodeRequired, Message: "empty parcels"},
+		{Pointer: "/from/name", Code: CodeRequired, Message: "empty name"},
+	}, mapped)
+
+	other := errors.New("other")
+	assert.Equal(t, other, MapPointers(other, strings.ToUpper))
+	assert.NoError(t, MapPointers(nil, strings.ToUpper))
+}
@@ -0,0 +1,115 @@
+package validation
+
+import "strings"
+
+// Codes of field errors
+const (
+	CodeRequired          = "required"
+	CodeTooLong           = "too_long"
+	CodeTooShort          = "too_short"
+	CodeInvalidCharacters = "invalid_characters"
+	CodeInvalidFormat     = "invalid_format"
+	CodeUnknownValue      = "unknown_value"
+	CodeOutOfRange        = "out_of_range"
+	CodeNotAllowed        = "not_allowed"
+	CodeDuplicate         = "duplicate"
+)
+
+// FieldError describes invalid field of validated object, field is
+// specified by JSON pointer (RFC 6901), e.g. "/from/email"
+type FieldError struct {
+	Pointer string `json:"pointer"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+// Errors contains all field errors of validated object
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fieldErr := range e {
+		messages = append(messages, fieldErr.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// MapPointers returns copy of errors with pointers replaced by fn, e.g. to
+// point fields of other JSON shape of validated object. Errors of the same
+// field after replacement are kept once. Other errors are returned as is
+func MapPointers(err error, fn func(pointer string) string) error {
+	fieldErrors, ok := err.(Errors)
+	if !ok {
+		return err
+	}
+
+	v := &Validator{}
+	for _, fieldErr := range fieldErrors {
+		v.AddError(fn(fieldErr.Pointer), fieldErr.Code, fieldErr.Message)
+	}
+	return v.Err()
+}
+
+// Validator collects errors of all fields of validated object,
+// only the first error is kept for each field
+type Validator struct {
+	rules  *Rules
+	errors Errors
+}
+
+// NewValidator returns validator using current rules
+func NewValidator() *Validator {
+	return &Validator{
+		rules: CurrentRules(),
+	}
+}
+
+// Rules returns rules used by validator
+func (v *Validator) Rules() *Rules {
+	return v.rules
+}
+
+// CheckField checks value of the field against rule with specified name,
+// reports whether value is valid
+func (v *Validator) CheckField(pointer, rule, value string) bool {
+	fieldRule, ok := v.rules.Fields[rule]
+	if !ok {
+		return true
+	}
+
+	if code, message := fieldRule.Check(ruleName(rule), value); code != "" {
+		v.AddError(pointer, code, message)
+		return false
+	}
+	return true
+}
+
+// AddError records error of the field
+func (v *Validator) AddError(pointer, code, message string) {
+	if v.HasError(pointer) {
+		return
+	}
+	v.errors = append(v.errors, FieldError{Pointer: pointer, Code: code, Message: message})
+}
+
+// HasError reports whether field already has error
+func (v *Validator) HasError(pointer string) bool {
+	for _, fieldErr := range v.errors {
+		if fieldErr.Pointer == pointer {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns collected errors or nil if there are none
+func (v *Validator) Err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return v.errors
+}